@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/angel-romero-f/rice-notes/internal/services"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const importCoursesUsage = "usage: server import-courses <catalog.csv|catalog.json>"
+
+// runImportCourses implements the "import-courses" subcommand
+func runImportCourses(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New(importCoursesUsage)
+	}
+	path := args[0]
+
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if format != services.CatalogFormatCSV && format != services.CatalogFormatJSON {
+		return fmt.Errorf("catalog file must end in .csv or .json\n%s", importCoursesUsage)
+	}
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		return errors.New("DATABASE_URL must be set to import courses")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open catalog: %w", err)
+	}
+	defer file.Close()
+
+	db, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	service := services.NewCourseService(repository.NewPostgresCourseRepository(db))
+	count, err := service.ImportCourses(ctx, file, format)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("imported %d course(s) from %s\n", count, path)
+	return nil
+}
@@ -2,11 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/angel-romero-f/rice-notes/internal/infra/jobs"
 	"github.com/angel-romero-f/rice-notes/internal/routes"
+	"github.com/angel-romero-f/rice-notes/migrations"
 	"github.com/angel-romero-f/rice-notes/pkg/logger"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
@@ -24,24 +31,66 @@ func main() {
 		log.Fatal("Failed to initialize logger:", err)
 	}
 
+	// Subcommands run against the database and exit instead of serving HTTP
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := runMigrate(context.Background(), os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "import-courses":
+			if err := runImportCourses(context.Background(), os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "reconcile":
+			if err := runReconcile(context.Background(), os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		default:
+			log.Fatalf("Unknown command %q (available: migrate, import-courses, reconcile)", os.Args[1])
+		}
+	}
+
 	// Get required environment variables
 	databaseURL := os.Getenv("DATABASE_URL")
-	s3Bucket := os.Getenv("S3_BUCKET_NAME")
-	s3Region := os.Getenv("S3_REGION")
-	useMockS3 := os.Getenv("USE_MOCK_S3") == "true"
+	autoMigrate := os.Getenv("AUTO_MIGRATE") == "true"
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
 
-	// For development, allow running without real AWS S3
-	if s3Bucket == "" && !useMockS3 {
-		log.Println("Warning: S3_BUCKET_NAME not set, using mock S3 for development")
-		useMockS3 = true
+	// Create router configuration, starting with the storage backend
+	config, err := storageConfigFromEnv(port)
+	if err != nil {
+		log.Fatal(err)
 	}
-	if s3Region == "" {
-		s3Region = "us-east-1" // Default region
+
+	// Uploads are streamed, so the limit only bounds what clients may store
+	var maxUploadSize int64
+	if value := os.Getenv("MAX_UPLOAD_SIZE_MB"); value != "" {
+		mb, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || mb <= 0 {
+			log.Fatalf("MAX_UPLOAD_SIZE_MB must be a positive number of megabytes, got %q", value)
+		}
+		maxUploadSize = mb << 20
+	}
+
+	// Background jobs run in this process unless JOB_WORKERS=0
+	jobWorkers := jobs.DefaultWorkers
+	if value := os.Getenv("JOB_WORKERS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			log.Fatalf("JOB_WORKERS must be a non-negative number, got %q", value)
+		}
+		jobWorkers = n
 	}
 
 	// Initialize database connection
 	var db *pgxpool.Pool
-	var err error
 
 	if databaseURL != "" {
 		db, err = pgxpool.New(context.Background(), databaseURL)
@@ -55,18 +104,27 @@ func main() {
 			log.Fatal("Failed to ping database:", err)
 		}
 		log.Println("Database connection established")
+
+		// Optionally bring the schema up to date before serving requests
+		if autoMigrate {
+			runner, err := migrations.NewRunner(db)
+			if err != nil {
+				log.Fatal("Failed to load migrations:", err)
+			}
+			applied, err := runner.Up(context.Background())
+			if err != nil {
+				log.Fatal("Failed to apply migrations:", err)
+			}
+			log.Printf("Applied %d pending migration(s)", len(applied))
+		}
 	} else {
 		log.Println("Warning: DATABASE_URL not set, database operations will fail")
 		// For development, you might want to create a mock database connection
 	}
 
-	// Create router configuration
-	config := &routes.RouterConfig{
-		DB:        db,
-		S3Bucket:  s3Bucket,
-		S3Region:  s3Region,
-		UseMockS3: useMockS3,
-	}
+	config.DB = db
+	config.MaxUploadSize = maxUploadSize
+	config.Jobs = jobs.NewRegistry()
 
 	// Initialize router
 	r, err := routes.NewRouter(config)
@@ -74,12 +132,90 @@ func main() {
 		log.Fatal("Failed to initialize router:", err)
 	}
 
+	// Start the job workers once every handler is registered
+	var pool *jobs.Pool
+	if db != nil && jobWorkers > 0 {
+		pool = jobs.NewPool(db, config.Jobs, jobs.Config{Workers: jobWorkers})
+		pool.Start()
+	}
+
 	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	server := &http.Server{Addr: ":" + port, Handler: r}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		log.Printf("Server starting on port %s", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down...")
+
+	// Finish in-flight requests first, since they may still queue jobs
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down HTTP server cleanly: %v", err)
+	}
+	if pool != nil {
+		if err := pool.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Job workers did not drain: %v", err)
+		}
+	}
+}
+
+// storageConfigFromEnv starts a router configuration with the storage backend
+// settings from the environment. port is where this server listens, which local
+// download links point at unless PUBLIC_URL says otherwise.
+func storageConfigFromEnv(port string) (*routes.RouterConfig, error) {
+	s3Bucket := os.Getenv("S3_BUCKET_NAME")
+	s3Region := os.Getenv("S3_REGION")
+	useMockS3 := os.Getenv("USE_MOCK_S3") == "true"
+	storageBackend := os.Getenv("STORAGE_BACKEND")
+	localStorageDir := os.Getenv("LOCAL_STORAGE_DIR")
+	publicURL := os.Getenv("PUBLIC_URL")
+
+	if publicURL == "" {
+		publicURL = "http://localhost:" + port
+	}
+	if localStorageDir == "" {
+		localStorageDir = "./data/files"
+	}
+
+	s3SSE, ok := os.LookupEnv("S3_SSE")
+	if !ok {
+		s3SSE = "AES256" // Encrypt at rest by default; set S3_SSE=none for servers that reject it
+	}
+
+	// Refuse to guess a storage backend: the mock loses every file on restart, so it
+	// must be requested explicitly (USE_MOCK_S3=true or STORAGE_BACKEND=mock)
+	if s3Bucket == "" && !useMockS3 && (storageBackend == "" || storageBackend == routes.StorageS3) {
+		return nil, errors.New("S3_BUCKET_NAME is not set. For local development, point S3_ENDPOINT at the MinIO " +
+			"container (see .local.env.example), or set STORAGE_BACKEND=local or USE_MOCK_S3=true")
+	}
+	if s3Region == "" {
+		s3Region = "us-east-1" // Default region
 	}
 
-	log.Printf("Server starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	return &routes.RouterConfig{
+		S3Bucket:  s3Bucket,
+		S3Region:  s3Region,
+		UseMockS3: useMockS3,
+
+		S3Endpoint:             os.Getenv("S3_ENDPOINT"),
+		S3UsePathStyle:         os.Getenv("S3_FORCE_PATH_STYLE") == "true",
+		S3AccessKeyID:          os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretAccessKey:      os.Getenv("S3_SECRET_ACCESS_KEY"),
+		S3CreateBucket:         os.Getenv("S3_CREATE_BUCKET") == "true",
+		S3ServerSideEncryption: s3SSE,
+
+		StorageBackend:    storageBackend,
+		LocalStorageDir:   localStorageDir,
+		PublicURL:         publicURL,
+		StorageSigningKey: os.Getenv("STORAGE_SIGNING_KEY"),
+	}, nil
 }
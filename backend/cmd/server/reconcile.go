@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/angel-romero-f/rice-notes/internal/routes"
+	"github.com/angel-romero-f/rice-notes/internal/services"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const reconcileUsage = "usage: server reconcile [-dry-run=false] [-grace 24h]"
+
+// runReconcile implements the "reconcile" subcommand. It only reports unless run
+// with -dry-run=false.
+func runReconcile(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dryRun := flags.Bool("dry-run", true, "report without deleting anything")
+	grace := flags.Duration("grace", services.DefaultReconcileGrace, "leave files and rows younger than this alone")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%v\n%s", err, reconcileUsage)
+	}
+	if flags.NArg() > 0 {
+		return errors.New(reconcileUsage)
+	}
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		return errors.New("DATABASE_URL must be set to reconcile storage")
+	}
+
+	// Listing and deleting never builds a download link, so the port is immaterial
+	config, err := storageConfigFromEnv("8080")
+	if err != nil {
+		return err
+	}
+	// The mock keeps files in memory, so there is nothing to reconcile against
+	if config.UseMockS3 || config.StorageBackend == routes.StorageMock {
+		return errors.New("reconcile needs a persistent storage backend, not the mock")
+	}
+	uploader, err := routes.NewUploader(config, os.Getenv("JWT_SECRET"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	db, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	reconciler := services.NewStorageReconciler(repository.NewPostgresFileRepository(db), uploader)
+	report, err := reconciler.Reconcile(ctx, services.ReconcileOptions{Grace: *grace, DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROBLEM\tKEY\tNOTE\tAGE")
+	for _, object := range report.OrphanedObjects {
+		fmt.Fprintf(w, "no row\t%s\t\t%s\n", object.Key, formatAge(object.LastModified))
+	}
+	for _, ref := range report.MissingFiles {
+		note := fmt.Sprintf("%s v%d", ref.NoteID, ref.Version)
+		if ref.Current {
+			note += " (current)"
+		}
+		fmt.Fprintf(w, "no file\t%s\t%s\t%s\n", ref.Key, note, formatAge(ref.CreatedAt))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("found %d file(s) without rows and %d row(s) without files\n", len(report.OrphanedObjects), len(report.MissingFiles))
+	if *dryRun {
+		if len(report.OrphanedObjects)+len(report.MissingFiles) > 0 {
+			fmt.Println("dry run, nothing deleted; rerun with -dry-run=false to delete them")
+		}
+		return nil
+	}
+
+	fmt.Printf("deleted %d\n", report.Deleted)
+	if report.Failed > 0 {
+		return fmt.Errorf("%d deletion(s) failed; see the log", report.Failed)
+	}
+	return nil
+}
+
+// formatAge formats how long ago t was, to the hour
+func formatAge(t time.Time) string {
+	return time.Since(t).Truncate(time.Hour).String()
+}
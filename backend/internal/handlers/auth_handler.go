@@ -3,18 +3,27 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/angel-romero-f/rice-notes/internal/middleware"
 	"github.com/angel-romero-f/rice-notes/internal/services"
 )
 
 // AuthService defines the business logic for authentication operations
 type AuthService interface {
-	GetGoogleAuthURL(state string) string
-	ExchangeCodeForToken(ctx context.Context, code string) (*services.AuthResult, error)
+	BeginGoogleLogin(returnTo, clientChallenge string) (*services.LoginAttempt, error)
+	CompleteGoogleLogin(ctx context.Context, code, state, stateCookie string) (*services.AuthResult, error)
+	RefreshSession(ctx context.Context, refreshToken string) (*services.AuthResult, error)
+	ExchangeAuthCode(ctx context.Context, code, codeVerifier string) (*services.AuthResult, error)
 	ValidateJWT(ctx context.Context, tokenString string) (*services.JWTClaims, error)
+	Logout(ctx context.Context, claims *services.JWTClaims, refreshToken string) error
+	LogoutEverywhere(ctx context.Context, caller services.Caller) error
 }
 
 // AuthHandler handles HTTP requests for authentication operations
@@ -27,10 +36,25 @@ func NewAuthHandler(s AuthService) *AuthHandler {
 	return &AuthHandler{authService: s}
 }
 
-// ErrorResponse represents an error response structure
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
+// TokenResponse carries a new access token and, on refresh, the refresh token that
+// replaces the one used
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// TokenRequest is the body of POST /api/auth/token: the code from the login redirect
+// and the PKCE verifier whose challenge started the login
+type TokenRequest struct {
+	Code         string `json:"code"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// RefreshRequest is the body of POST /api/auth/refresh for clients that can't use the cookie
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // UserResponse represents a user information response
@@ -40,28 +64,55 @@ type UserResponse struct {
 	Picture string `json:"picture"`
 }
 
-// GoogleLogin initiates the Google OAuth2 flow by redirecting to Google's authorization URL
+// stateCookieName is the cookie binding a Google login to the browser that started it
+const stateCookieName = "oauth_state"
+
+// defaultReturnTo is where users land after login when they didn't ask for anywhere else
+const defaultReturnTo = "/dashboard"
+
+// GoogleLogin initiates the Google OAuth2 flow by redirecting to Google's authorization URL.
+// An optional return_to names where to send the user afterwards; it must be a path on the
+// frontend or a URL on an allowed origin. A client that needs a bearer token passes an S256
+// code_challenge and is sent back with a one-time code to redeem at POST /api/auth/token.
 func (a *AuthHandler) GoogleLogin(w http.ResponseWriter, r *http.Request) {
 	slog.Info("Google login initiated", "remote_addr", r.RemoteAddr, "user_agent", r.UserAgent())
 
-	// Get state parameter from query string or generate one
-	state := r.URL.Query().Get("state")
-	if state == "" {
-		// Generate a simple state for this session
-		state = "auth_" + r.Header.Get("X-Request-ID") // Use request ID if available
-		if state == "auth_" {
-			// Fallback state generation
-			state = "auth_request"
-		}
+	returnTo, ok := safeReturnTo(r.URL.Query().Get("return_to"))
+	if !ok {
+		slog.Warn("Rejected unsafe return_to", "return_to", r.URL.Query().Get("return_to"))
+		sendJSONError(w, http.StatusBadRequest, "invalid_return_to", "return_to is not an allowed destination")
+		return
 	}
 
-	// Get Google OAuth URL from service
-	authURL := a.authService.GetGoogleAuthURL(state)
-	
-	slog.Info("Redirecting to Google OAuth", "state", state, "url_length", len(authURL))
+	query := r.URL.Query()
+	if method := query.Get("code_challenge_method"); method != "" && method != "S256" {
+		sendJSONError(w, http.StatusBadRequest, "invalid_code_challenge", "code_challenge_method must be S256")
+		return
+	}
+
+	attempt, err := a.authService.BeginGoogleLogin(returnTo, query.Get("code_challenge"))
+	if err != nil {
+		slog.Error("Failed to start Google login", "error", err)
+		sendError(w, err, "Failed to start login")
+		return
+	}
+
+	// Only the callback needs the state, and it arrives as a top-level navigation
+	// from Google, which SameSite=Lax allows
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookieName,
+		Value:    attempt.StateCookie,
+		Path:     "/api/auth/google/callback",
+		HttpOnly: true,
+		Secure:   os.Getenv("ENV") == "production",
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(services.LoginStateTTL.Seconds()),
+	})
+
+	slog.Info("Redirecting to Google OAuth", "url_length", len(attempt.AuthURL))
 
 	// Redirect to Google OAuth URL
-	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
+	http.Redirect(w, r, attempt.AuthURL, http.StatusTemporaryRedirect)
 }
 
 // GoogleCallback handles the OAuth2 callback from Google
@@ -76,62 +127,143 @@ func (a *AuthHandler) GoogleCallback(w http.ResponseWriter, r *http.Request) {
 	// Check if user denied access
 	if errorParam != "" {
 		slog.Warn("User denied OAuth access", "error", errorParam)
-		a.sendErrorResponse(w, http.StatusUnauthorized, "access_denied", "User denied access")
+		sendJSONError(w, http.StatusUnauthorized, "access_denied", "User denied access")
 		return
 	}
 
 	// Validate required parameters
 	if code == "" {
 		slog.Warn("Missing authorization code in callback")
-		a.sendErrorResponse(w, http.StatusBadRequest, "missing_code", "Authorization code is required")
+		sendJSONError(w, http.StatusBadRequest, "missing_code", "Authorization code is required")
 		return
 	}
 
 	if state == "" {
 		slog.Warn("Missing state parameter in callback")
-		a.sendErrorResponse(w, http.StatusBadRequest, "missing_state", "State parameter is required")
+		sendJSONError(w, http.StatusBadRequest, "missing_state", "State parameter is required")
 		return
 	}
 
+	// The state cookie is single-use whatever the outcome
+	var stateCookie string
+	if cookie, err := r.Cookie(stateCookieName); err == nil {
+		stateCookie = cookie.Value
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookieName,
+		Path:     "/api/auth/google/callback",
+		HttpOnly: true,
+		MaxAge:   -1,
+	})
+
 	// Exchange code for JWT token
-	authResult, err := a.authService.ExchangeCodeForToken(r.Context(), code)
+	authResult, err := a.authService.CompleteGoogleLogin(r.Context(), code, state, stateCookie)
 	if err != nil {
 		slog.Error("Code exchange failed", "error", err, "code_length", len(code))
-		
-		// Map service errors to appropriate HTTP status codes
-		errMsg := err.Error()
-		switch {
-		case errMsg == "only Rice University emails are allowed":
-			// Redirect to unauthorized page instead of sending JSON error
-			frontendURL := os.Getenv("FRONTEND_URL")
-			if frontendURL == "" {
-				frontendURL = "http://localhost:3000" // Fallback for development
-			}
-			unauthorizedURL := frontendURL + "/unauthorized"
-			http.Redirect(w, r, unauthorizedURL, http.StatusTemporaryRedirect)
-		case errMsg == "invalid authorization code":
-			a.sendErrorResponse(w, http.StatusUnauthorized, "invalid_code", "Invalid authorization code")
-		default:
-			a.sendErrorResponse(w, http.StatusInternalServerError, "auth_error", "Authentication failed")
+
+		// Send users outside Rice to the unauthorized page instead of a JSON error
+		if errors.Is(err, services.ErrNotRiceEmail) {
+			http.Redirect(w, r, frontendURL()+"/unauthorized", http.StatusTemporaryRedirect)
+			return
 		}
+		sendError(w, err, "Authentication failed")
 		return
 	}
 
-	// Set JWT in secure HttpOnly cookie (for same-origin requests)
+	// Set JWT and refresh token in secure HttpOnly cookies (for same-origin requests)
 	a.setJWTCookie(w, authResult.JWT)
+	a.setRefreshCookie(w, authResult.RefreshToken)
 
 	slog.Info("Successful authentication", "email", authResult.Email, "name", authResult.Name)
 
-	// Redirect to frontend dashboard with JWT as query parameter (for cross-origin)
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = "http://localhost:3000" // Fallback for development
+	// Redirect back to the frontend. The JWT itself never goes in the URL, where it would
+	// end up in history, logs and Referer headers; cross-origin clients get a one-time
+	// code to trade for it instead.
+	target, err := url.Parse(returnURL(authResult.ReturnTo))
+	if err != nil {
+		slog.Error("Invalid return URL", "error", err, "return_to", authResult.ReturnTo)
+		sendJSONError(w, http.StatusInternalServerError, "auth_error", "Authentication failed")
+		return
+	}
+	if authResult.Code != "" {
+		query := target.Query()
+		query.Set("code", authResult.Code)
+		target.RawQuery = query.Encode()
+	}
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	http.Redirect(w, r, target.String(), http.StatusTemporaryRedirect)
+}
+
+// frontendURL returns the base URL of the frontend
+func frontendURL() string {
+	if url := os.Getenv("FRONTEND_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
 	}
-	dashboardURL := frontendURL + "/dashboard?token=" + authResult.JWT
-	http.Redirect(w, r, dashboardURL, http.StatusTemporaryRedirect)
+	return "http://localhost:3000" // Fallback for development
 }
 
-// setJWTCookie sets a secure HttpOnly cookie with the JWT token
+// allowedReturnOrigins lists the origins login may return to: the frontend and any
+// production origins also allowed by CORS
+func allowedReturnOrigins() []string {
+	origins := []string{frontendURL()}
+	if extra := os.Getenv("ALLOWED_ORIGINS"); extra != "" {
+		for _, origin := range strings.Split(extra, ",") {
+			origins = append(origins, strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+		}
+	}
+	return origins
+}
+
+// safeReturnTo checks a requested post-login destination. Paths on the frontend are
+// accepted, as are absolute URLs on an allowed origin; anything else could be used to
+// send a freshly signed-in user to an attacker's site. An empty value is allowed.
+func safeReturnTo(returnTo string) (string, bool) {
+	if returnTo == "" {
+		return "", true
+	}
+
+	// Browsers treat backslashes like slashes, so "/\evil.com" is protocol-relative
+	if strings.ContainsAny(returnTo, "\\\r\n\t") {
+		return "", false
+	}
+
+	u, err := url.Parse(returnTo)
+	if err != nil {
+		return "", false
+	}
+
+	if u.Scheme == "" && u.Host == "" {
+		if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") {
+			return "", false
+		}
+		return returnTo, true
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" || u.User != nil {
+		return "", false
+	}
+	origin := u.Scheme + "://" + u.Host
+	for _, allowed := range allowedReturnOrigins() {
+		if strings.EqualFold(origin, allowed) {
+			return returnTo, true
+		}
+	}
+	return "", false
+}
+
+// returnURL resolves a destination accepted by safeReturnTo to an absolute URL
+func returnURL(returnTo string) string {
+	switch {
+	case returnTo == "":
+		return frontendURL() + defaultReturnTo
+	case strings.HasPrefix(returnTo, "/"):
+		return frontendURL() + returnTo
+	default:
+		return returnTo
+	}
+}
+
+// setJWTCookie sets a secure HttpOnly cookie with the JWT token, or clears it when jwt is empty
 func (a *AuthHandler) setJWTCookie(w http.ResponseWriter, jwt string) {
 	// Determine if we're in production (HTTPS) or development (HTTP)
 	isProduction := os.Getenv("ENV") == "production"
@@ -143,7 +275,7 @@ func (a *AuthHandler) setJWTCookie(w http.ResponseWriter, jwt string) {
 		HttpOnly: true,
 		Secure:   true, // Always require HTTPS for cross-origin cookies
 		SameSite: http.SameSiteNoneMode, // Required for cross-origin cookies
-		MaxAge:   24 * 60 * 60, // 24 hours (matching JWT expiration)
+		MaxAge:   int(services.AccessTokenTTL.Seconds()), // matching JWT expiration
 	}
 
 	// For development, use SameSiteLax for same-origin requests
@@ -152,27 +284,188 @@ func (a *AuthHandler) setJWTCookie(w http.ResponseWriter, jwt string) {
 		cookie.SameSite = http.SameSiteLaxMode
 	}
 
+	if jwt == "" {
+		cookie.MaxAge = -1
+	}
+
 	http.SetCookie(w, cookie)
 	slog.Info("JWT cookie set", "cookie_name", cookie.Name, "max_age", cookie.MaxAge, "secure", cookie.Secure, "samesite", cookie.SameSite)
 }
 
-// sendErrorResponse sends a JSON error response with the specified status code
-func (a *AuthHandler) sendErrorResponse(w http.ResponseWriter, statusCode int, errorCode, message string) {
+// refreshCookieName is the cookie holding the refresh token; it is only sent to the auth routes
+const refreshCookieName = "refresh_token"
+
+// setRefreshCookie sets a secure HttpOnly cookie with the refresh token, or clears it
+// when token is empty
+func (a *AuthHandler) setRefreshCookie(w http.ResponseWriter, token string) {
+	isProduction := os.Getenv("ENV") == "production"
+
+	cookie := &http.Cookie{
+		Name:     refreshCookieName,
+		Value:    token,
+		Path:     "/api/auth",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode, // Required for cross-origin cookies
+		MaxAge:   int(services.RefreshTokenTTL.Seconds()),
+	}
+
+	// For development, use SameSiteLax for same-origin requests
+	if !isProduction {
+		cookie.Secure = false
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+
+	if token == "" {
+		cookie.MaxAge = -1
+	}
+
+	http.SetCookie(w, cookie)
+}
+
+// Refresh handles POST /api/auth/refresh - exchanges a refresh token, from the cookie or
+// the JSON body, for a new access token and a new refresh token
+func (a *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var refreshToken string
+	if cookie, err := r.Cookie(refreshCookieName); err == nil {
+		refreshToken = cookie.Value
+	} else {
+		var req RefreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err == nil {
+			refreshToken = req.RefreshToken
+		}
+	}
+
+	if refreshToken == "" {
+		sendJSONError(w, http.StatusUnauthorized, "no_refresh_token", "Refresh token is required")
+		return
+	}
+
+	result, err := a.authService.RefreshSession(r.Context(), refreshToken)
+	if err != nil {
+		slog.Warn("Token refresh failed", "error", err)
+		// A refresh token that will never work again is cleared
+		if errors.Is(err, services.ErrUnauthorized) {
+			a.setRefreshCookie(w, "")
+		}
+		sendError(w, err, "Failed to refresh session")
+		return
+	}
+
+	a.setJWTCookie(w, result.JWT)
+	a.setRefreshCookie(w, result.RefreshToken)
+
+	// Tokens must never be cached by the browser or a proxy
+	w.Header().Set("Cache-Control", "no-store")
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+	response := TokenResponse{
+		AccessToken:  result.JWT,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(time.Until(result.ExpiresAt).Seconds()),
+		RefreshToken: result.RefreshToken,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Failed to encode token response", "error", err)
+		return
+	}
+
+	slog.Info("Session refreshed", "email", result.Email)
+}
+
+// Token handles POST /api/auth/token - redeems the one-time code from the login redirect
+// for a bearer token. The code only works once, for a minute, and only with the verifier
+// of the client that started the login.
+func (a *AuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	var req TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, http.StatusBadRequest, "invalid_request", "Request body must be JSON with code and code_verifier")
+		return
+	}
 
-	response := ErrorResponse{
-		Error:   errorCode,
-		Message: message,
+	result, err := a.authService.ExchangeAuthCode(r.Context(), req.Code, req.CodeVerifier)
+	if err != nil {
+		slog.Warn("Auth code exchange failed", "error", err)
+		sendError(w, err, "Failed to exchange login code")
+		return
 	}
 
+	// Tokens must never be cached by the browser or a proxy
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	response := TokenResponse{
+		AccessToken: result.JWT,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(time.Until(result.ExpiresAt).Seconds()),
+	}
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		slog.Error("Failed to encode error response", "error", err)
-		// Fallback to plain text if JSON encoding fails
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.Error("Failed to encode token response", "error", err)
+		return
+	}
+
+	slog.Info("Login code exchanged", "email", result.Email)
+}
+
+// Logout handles POST /api/auth/logout - revokes the caller's access token and session
+// and clears the auth cookies. Logging out with a token that is already invalid
+// succeeds, so clients can always call it.
+func (a *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var claims *services.JWTClaims
+	if tokenString := requestToken(r); tokenString != "" {
+		if c, err := a.authService.ValidateJWT(r.Context(), tokenString); err == nil {
+			claims = c
+		}
 	}
 
-	slog.Debug("Error response sent", "status", statusCode, "error_code", errorCode, "message", message)
+	var refreshToken string
+	if cookie, err := r.Cookie(refreshCookieName); err == nil {
+		refreshToken = cookie.Value
+	}
+
+	// Clear the cookies whatever happens below
+	a.setJWTCookie(w, "")
+	a.setRefreshCookie(w, "")
+
+	if err := a.authService.Logout(r.Context(), claims, refreshToken); err != nil {
+		slog.Error("Failed to log out", "error", err)
+		sendError(w, err, "Failed to log out")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutEverywhere handles POST /api/auth/logout-all - revokes every token the user
+// holds, on every device
+func (a *AuthHandler) LogoutEverywhere(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	if err := a.authService.LogoutEverywhere(r.Context(), user.Caller()); err != nil {
+		slog.Error("Failed to log out everywhere", "error", err, "email", user.Email)
+		sendError(w, err, "Failed to log out")
+		return
+	}
+
+	a.setJWTCookie(w, "")
+	a.setRefreshCookie(w, "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestToken extracts the JWT from the Authorization header (cross-origin) or the
+// jwt cookie (same-origin), returning "" if there is neither
+func requestToken(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		return authHeader[len("Bearer "):]
+	}
+	if cookie, err := r.Cookie("jwt"); err == nil {
+		return cookie.Value
+	}
+	return ""
 }
 
 // Me returns the current user's information from the JWT token
@@ -190,7 +483,7 @@ func (a *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 		cookie, err := r.Cookie("jwt")
 		if err != nil {
 			slog.Warn("No JWT found in Authorization header or cookie")
-			a.sendErrorResponse(w, http.StatusUnauthorized, "no_token", "Authentication required")
+			sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
 			return
 		}
 		tokenString = cookie.Value
@@ -201,7 +494,7 @@ func (a *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 	claims, err := a.authService.ValidateJWT(r.Context(), tokenString)
 	if err != nil {
 		slog.Warn("Invalid JWT token", "error", err)
-		a.sendErrorResponse(w, http.StatusUnauthorized, "invalid_token", "Invalid or expired token")
+		sendJSONError(w, http.StatusUnauthorized, "invalid_token", "Invalid or expired token")
 		return
 	}
 
@@ -217,7 +510,6 @@ func (a *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		slog.Error("Failed to encode user response", "error", err)
-		a.sendErrorResponse(w, http.StatusInternalServerError, "encoding_error", "Failed to encode response")
 		return
 	}
 
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -20,13 +21,36 @@ type mockAuthService struct {
 	validateResult      *services.JWTClaims
 	validateError       error
 	shouldFailValidation bool
+	loggedOut           bool
+	logoutClaims        *services.JWTClaims
+	logoutRefreshToken  string
+	exchangeCode        string
+	exchangeVerifier    string
 }
 
-func (m *mockAuthService) GetGoogleAuthURL(state string) string {
-	return m.authURL
+func (m *mockAuthService) BeginGoogleLogin(returnTo, clientChallenge string) (*services.LoginAttempt, error) {
+	if clientChallenge == "bad" {
+		return nil, services.ErrInvalidClientChallenge
+	}
+	return &services.LoginAttempt{AuthURL: m.authURL, StateCookie: "signed-state"}, nil
+}
+
+func (m *mockAuthService) CompleteGoogleLogin(ctx context.Context, code, state, stateCookie string) (*services.AuthResult, error) {
+	if m.authError != nil {
+		return nil, m.authError
+	}
+	return m.authResult, nil
+}
+
+func (m *mockAuthService) RefreshSession(ctx context.Context, refreshToken string) (*services.AuthResult, error) {
+	if m.authError != nil {
+		return nil, m.authError
+	}
+	return m.authResult, nil
 }
 
-func (m *mockAuthService) ExchangeCodeForToken(ctx context.Context, code string) (*services.AuthResult, error) {
+func (m *mockAuthService) ExchangeAuthCode(ctx context.Context, code, codeVerifier string) (*services.AuthResult, error) {
+	m.exchangeCode, m.exchangeVerifier = code, codeVerifier
 	if m.authError != nil {
 		return nil, m.authError
 	}
@@ -40,28 +64,62 @@ func (m *mockAuthService) ValidateJWT(ctx context.Context, tokenString string) (
 	return m.validateResult, nil
 }
 
+func (m *mockAuthService) Logout(ctx context.Context, claims *services.JWTClaims, refreshToken string) error {
+	m.loggedOut = true
+	m.logoutClaims = claims
+	m.logoutRefreshToken = refreshToken
+	return nil
+}
+
+func (m *mockAuthService) LogoutEverywhere(ctx context.Context, caller services.Caller) error {
+	m.loggedOut = true
+	return nil
+}
+
 func TestAuthHandler_GoogleLogin(t *testing.T) {
 	tests := []struct {
 		name           string
-		state          string
+		returnTo       string
+		query          url.Values
 		expectedURL    string
 		expectedStatus int
 		expectLocation bool
 	}{
 		{
-			name:           "successful redirect with custom state",
-			state:          "custom-state-123",
-			expectedURL:    "https://accounts.google.com/oauth/authorize?client_id=test&state=custom-state-123",
+			name:           "successful redirect",
+			expectedURL:    "https://accounts.google.com/oauth/authorize?client_id=test&state=auto-generated",
 			expectedStatus: http.StatusTemporaryRedirect,
 			expectLocation: true,
 		},
 		{
-			name:           "successful redirect with auto-generated state",
-			state:          "",
+			name:           "successful redirect with return_to path",
+			returnTo:       "/notes/123",
+			expectedURL:    "https://accounts.google.com/oauth/authorize?client_id=test&state=auto-generated",
+			expectedStatus: http.StatusTemporaryRedirect,
+			expectLocation: true,
+		},
+		{
+			name:           "rejects return_to on another site",
+			returnTo:       "https://evil.example.com/phish",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "successful redirect with code_challenge",
+			query:          url.Values{"code_challenge": {"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"}, "code_challenge_method": {"S256"}},
 			expectedURL:    "https://accounts.google.com/oauth/authorize?client_id=test&state=auto-generated",
 			expectedStatus: http.StatusTemporaryRedirect,
 			expectLocation: true,
 		},
+		{
+			name:           "rejects plain code_challenge_method",
+			query:          url.Values{"code_challenge": {"verifier"}, "code_challenge_method": {"plain"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "rejects malformed code_challenge",
+			query:          url.Values{"code_challenge": {"bad"}},
+			expectedStatus: http.StatusBadRequest,
+		},
 	}
 
 	for _, tt := range tests {
@@ -75,11 +133,14 @@ func TestAuthHandler_GoogleLogin(t *testing.T) {
 
 			// Create request
 			req := httptest.NewRequest(http.MethodGet, "/api/auth/google", nil)
-			if tt.state != "" {
-				q := req.URL.Query()
-				q.Add("state", tt.state)
-				req.URL.RawQuery = q.Encode()
+			q := url.Values{}
+			for key, values := range tt.query {
+				q[key] = values
 			}
+			if tt.returnTo != "" {
+				q.Add("return_to", tt.returnTo)
+			}
+			req.URL.RawQuery = q.Encode()
 
 			rr := httptest.NewRecorder()
 
@@ -91,7 +152,7 @@ func TestAuthHandler_GoogleLogin(t *testing.T) {
 				t.Errorf("GoogleLogin() status = %v, want %v", status, tt.expectedStatus)
 			}
 
-			// Assert Location header is set
+			// Assert Location header and state cookie are set
 			if tt.expectLocation {
 				location := rr.Header().Get("Location")
 				if location == "" {
@@ -100,11 +161,50 @@ func TestAuthHandler_GoogleLogin(t *testing.T) {
 				if !strings.Contains(location, "accounts.google.com") {
 					t.Errorf("Expected Location to contain Google OAuth URL, got %v", location)
 				}
+
+				var stateCookie *http.Cookie
+				for _, cookie := range rr.Result().Cookies() {
+					if cookie.Name == stateCookieName {
+						stateCookie = cookie
+					}
+				}
+				if stateCookie == nil || stateCookie.Value != "signed-state" || !stateCookie.HttpOnly {
+					t.Errorf("Expected HttpOnly state cookie, got %+v", stateCookie)
+				}
 			}
 		})
 	}
 }
 
+func TestSafeReturnTo(t *testing.T) {
+	t.Setenv("FRONTEND_URL", "https://notes.rice.edu")
+	t.Setenv("ALLOWED_ORIGINS", "https://staging.notes.rice.edu")
+
+	tests := []struct {
+		returnTo string
+		ok       bool
+	}{
+		{"", true},
+		{"/dashboard", true},
+		{"/notes/123?tab=versions", true},
+		{"https://notes.rice.edu/notes/123", true},
+		{"https://staging.notes.rice.edu/dashboard", true},
+		{"//evil.example.com", false},
+		{"/\\evil.example.com", false},
+		{"https://evil.example.com/dashboard", false},
+		{"https://notes.rice.edu.evil.example.com/", false},
+		{"https://user@notes.rice.edu/", false},
+		{"javascript:alert(1)", false},
+		{"dashboard", false},
+	}
+
+	for _, tt := range tests {
+		if _, ok := safeReturnTo(tt.returnTo); ok != tt.ok {
+			t.Errorf("safeReturnTo(%q) ok = %v, want %v", tt.returnTo, ok, tt.ok)
+		}
+	}
+}
+
 func TestAuthHandler_GoogleCallback(t *testing.T) {
 	tests := []struct {
 		name               string
@@ -149,17 +249,17 @@ func TestAuthHandler_GoogleCallback(t *testing.T) {
 			name:           "invalid authorization code",
 			code:           "invalid-code",
 			state:          "valid-state",
-			authError:      errors.New("invalid authorization code"),
+			authError:      services.ErrInvalidGoogleCode,
 			expectedStatus: http.StatusUnauthorized,
 			expectError:    true,
 		},
 		{
-			name:           "non-rice email",
-			code:           "valid-code",
-			state:          "valid-state",
-			authError:      errors.New("only Rice University emails are allowed"),
-			expectedStatus: http.StatusForbidden,
-			expectError:    true,
+			name:               "non-rice email",
+			code:               "valid-code",
+			state:              "valid-state",
+			authError:          services.ErrNotRiceEmail,
+			expectedStatus:     http.StatusTemporaryRedirect,
+			expectedRedirectTo: "http://localhost:3000/unauthorized",
 		},
 		{
 			name:           "service error",
@@ -216,11 +316,12 @@ func TestAuthHandler_GoogleCallback(t *testing.T) {
 						if !cookie.HttpOnly {
 							t.Error("Expected JWT cookie to be HttpOnly")
 						}
-						if cookie.Secure != true {
-							t.Error("Expected JWT cookie to be Secure")
+						// Outside production the cookie is for same-origin HTTP development
+						if cookie.Secure {
+							t.Error("Expected JWT cookie not to be Secure outside production")
 						}
-						if cookie.SameSite != http.SameSiteStrictMode {
-							t.Error("Expected JWT cookie to have SameSite=Strict")
+						if cookie.SameSite != http.SameSiteLaxMode {
+							t.Error("Expected JWT cookie to have SameSite=Lax outside production")
 						}
 						if cookie.Path != "/" {
 							t.Error("Expected JWT cookie path to be '/'")
@@ -271,60 +372,76 @@ func TestAuthHandler_GoogleCallback_StateValidation(t *testing.T) {
 }
 
 func TestAuthHandler_CookieSettings(t *testing.T) {
-	t.Run("sets secure cookie properties", func(t *testing.T) {
-		mockService := &mockAuthService{
-			authResult: &services.AuthResult{
-				Email:   "test@rice.edu",
-				Name:    "Test User",
-				Picture: "https://example.com/pic.jpg",
-				JWT:     "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...",
-			},
-		}
+	tests := []struct {
+		name         string
+		env          string
+		wantSecure   bool
+		wantSameSite http.SameSite
+	}{
+		// The frontend is served from another origin in production, so the cookie
+		// must be sent cross-site, which browsers only allow for Secure cookies
+		{name: "production", env: "production", wantSecure: true, wantSameSite: http.SameSiteNoneMode},
+		{name: "development", env: "", wantSecure: false, wantSameSite: http.SameSiteLaxMode},
+	}
 
-		handler := NewAuthHandler(mockService)
-		req := httptest.NewRequest(http.MethodGet, "/api/auth/google/callback?code=test&state=test", nil)
-		rr := httptest.NewRecorder()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("ENV", tc.env)
 
-		handler.GoogleCallback(rr, req)
+			mockService := &mockAuthService{
+				authResult: &services.AuthResult{
+					Email:   "test@rice.edu",
+					Name:    "Test User",
+					Picture: "https://example.com/pic.jpg",
+					JWT:     "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...",
+				},
+			}
 
-		if rr.Code != http.StatusTemporaryRedirect {
-			t.Fatalf("Expected successful redirect, got %d", rr.Code)
-		}
+			handler := NewAuthHandler(mockService)
+			req := httptest.NewRequest(http.MethodGet, "/api/auth/google/callback?code=test&state=test", nil)
+			rr := httptest.NewRecorder()
 
-		cookies := rr.Result().Cookies()
-		var jwtCookie *http.Cookie
-		for _, cookie := range cookies {
-			if cookie.Name == "jwt" {
-				jwtCookie = cookie
-				break
+			handler.GoogleCallback(rr, req)
+
+			if rr.Code != http.StatusTemporaryRedirect {
+				t.Fatalf("Expected successful redirect, got %d", rr.Code)
 			}
-		}
 
-		if jwtCookie == nil {
-			t.Fatal("JWT cookie not found")
-		}
+			cookies := rr.Result().Cookies()
+			var jwtCookie *http.Cookie
+			for _, cookie := range cookies {
+				if cookie.Name == "jwt" {
+					jwtCookie = cookie
+					break
+				}
+			}
 
-		// Verify all security properties
-		tests := []struct {
-			name     string
-			got      interface{}
-			expected interface{}
-		}{
-			{"HttpOnly", jwtCookie.HttpOnly, true},
-			{"Secure", jwtCookie.Secure, true},
-			{"SameSite", jwtCookie.SameSite, http.SameSiteStrictMode},
-			{"Path", jwtCookie.Path, "/"},
-			{"Value", jwtCookie.Value, "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."},
-		}
+			if jwtCookie == nil {
+				t.Fatal("JWT cookie not found")
+			}
 
-		for _, tt := range tests {
-			t.Run(tt.name, func(t *testing.T) {
-				if tt.got != tt.expected {
-					t.Errorf("Cookie %s = %v, want %v", tt.name, tt.got, tt.expected)
-				}
-			})
-		}
-	})
+			// Verify all security properties
+			tests := []struct {
+				name     string
+				got      interface{}
+				expected interface{}
+			}{
+				{"HttpOnly", jwtCookie.HttpOnly, true},
+				{"Secure", jwtCookie.Secure, tc.wantSecure},
+				{"SameSite", jwtCookie.SameSite, tc.wantSameSite},
+				{"Path", jwtCookie.Path, "/"},
+				{"Value", jwtCookie.Value, "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					if tt.got != tt.expected {
+						t.Errorf("Cookie %s = %v, want %v", tt.name, tt.got, tt.expected)
+					}
+				})
+			}
+		})
+	}
 }
 
 func TestNewAuthHandler(t *testing.T) {
@@ -338,4 +455,146 @@ func TestNewAuthHandler(t *testing.T) {
 	if handler.authService != mockService {
 		t.Error("NewAuthHandler() did not set authService correctly")
 	}
-}
\ No newline at end of file
+}
+func TestAuthHandler_Logout(t *testing.T) {
+	tests := []struct {
+		name       string
+		validToken bool
+	}{
+		{name: "valid token is revoked", validToken: true},
+		{name: "invalid token still logs out", validToken: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &services.JWTClaims{Email: "student@rice.edu"}
+			mockService := &mockAuthService{validateResult: claims}
+			if !tt.validToken {
+				mockService.validateError = errors.New("token has been revoked")
+			}
+			handler := NewAuthHandler(mockService)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/logout", nil)
+			req.Header.Set("Authorization", "Bearer some-token")
+			req.AddCookie(&http.Cookie{Name: "refresh_token", Value: "some-refresh-token"})
+			w := httptest.NewRecorder()
+
+			handler.Logout(w, req)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+			}
+			if !mockService.loggedOut {
+				t.Fatal("Expected Logout to be called")
+			}
+			if tt.validToken && mockService.logoutClaims != claims {
+				t.Error("Expected the token's claims to be revoked")
+			}
+			if !tt.validToken && mockService.logoutClaims != nil {
+				t.Error("Expected no claims for an invalid token")
+			}
+			if mockService.logoutRefreshToken != "some-refresh-token" {
+				t.Errorf("Expected refresh token to be revoked, got %q", mockService.logoutRefreshToken)
+			}
+
+			cleared := map[string]bool{}
+			for _, cookie := range w.Result().Cookies() {
+				if cookie.MaxAge < 0 {
+					cleared[cookie.Name] = true
+				}
+			}
+			if !cleared["jwt"] || !cleared["refresh_token"] {
+				t.Errorf("Expected jwt and refresh_token cookies to be cleared, got %v", cleared)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_GoogleCallback_AuthCode(t *testing.T) {
+	mockService := &mockAuthService{
+		authResult: &services.AuthResult{
+			Email: "test@rice.edu",
+			JWT:   "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...",
+			Code:  "one-time-code",
+		},
+	}
+	handler := NewAuthHandler(mockService)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/google/callback?code=test&state=test", nil)
+	rr := httptest.NewRecorder()
+
+	handler.GoogleCallback(rr, req)
+
+	if rr.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected redirect, got %d", rr.Code)
+	}
+	location := rr.Header().Get("Location")
+	if location != "http://localhost:3000/dashboard?code=one-time-code" {
+		t.Errorf("Expected redirect with one-time code, got %v", location)
+	}
+	if strings.Contains(location, "eyJ") {
+		t.Error("JWT must not appear in the redirect URL")
+	}
+}
+
+func TestAuthHandler_Token(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		authError      error
+		expectedStatus int
+	}{
+		{
+			name:           "exchanges code",
+			body:           `{"code":"one-time-code","code_verifier":"verifier"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid code",
+			body:           `{"code":"used-code","code_verifier":"verifier"}`,
+			authError:      services.ErrInvalidAuthCode,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "malformed body",
+			body:           `code=one-time-code`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockAuthService{
+				authResult: &services.AuthResult{Email: "test@rice.edu", JWT: "access-token"},
+				authError:  tt.authError,
+			}
+			handler := NewAuthHandler(mockService)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/token", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			handler.Token(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Token() status = %v, want %v", rr.Code, tt.expectedStatus)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			if mockService.exchangeCode != "one-time-code" || mockService.exchangeVerifier != "verifier" {
+				t.Errorf("ExchangeAuthCode() got code %q verifier %q", mockService.exchangeCode, mockService.exchangeVerifier)
+			}
+			if rr.Header().Get("Cache-Control") != "no-store" {
+				t.Error("Expected token response to be uncacheable")
+			}
+			var response TokenResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.AccessToken != "access-token" || response.TokenType != "Bearer" {
+				t.Errorf("Token() response = %+v", response)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+)
+
+// CourseService defines the business logic for course catalog lookups
+type CourseService interface {
+	SearchCourses(ctx context.Context, query string, limit int) ([]*models.Course, error)
+}
+
+// CourseHandler handles HTTP requests for the course catalog
+type CourseHandler struct {
+	service CourseService
+}
+
+// NewCourseHandler returns a new CourseHandler instance with the provided CourseService
+func NewCourseHandler(s CourseService) *CourseHandler {
+	return &CourseHandler{service: s}
+}
+
+// SearchCourses handles GET /api/courses?q= - autocompletes course codes and titles
+func (h *CourseHandler) SearchCourses(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	courses, err := h.service.SearchCourses(r.Context(), query, limit)
+	if err != nil {
+		slog.Error("Failed to search courses", "error", err, "query", query)
+		sendError(w, err, "Failed to search courses")
+		return
+	}
+
+	// Always return an array, even when nothing matches
+	if courses == nil {
+		courses = []*models.Course{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(courses); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+		return
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/angel-romero-f/rice-notes/internal/infra/pdf"
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/services"
+)
+
+// ErrorResponse is the body of every error response. Error is a stable code clients
+// can act on, Message is safe to show to the user and Field names the request field
+// at fault, if any.
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message,omitempty"`
+	Field   string `json:"field,omitempty"`
+}
+
+// errorKinds maps each error kind to its HTTP status, and to the code reported for
+// errors of that kind that don't carry their own
+var errorKinds = []struct {
+	kind   error
+	status int
+	code   string
+}{
+	{services.ErrValidation, http.StatusBadRequest, "invalid_request"},
+	{services.ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+	{services.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{services.ErrNotFound, http.StatusNotFound, "not_found"},
+	{services.ErrConflict, http.StatusConflict, "conflict"},
+	{services.ErrPreconditionFailed, http.StatusPreconditionFailed, "precondition_failed"},
+	{services.ErrUpstream, http.StatusBadGateway, "upstream_unavailable"},
+}
+
+// sendError writes a service error as an ErrorResponse with the status for its kind.
+// Errors of no kind are unexpected: they become a 500 with the fallback message, so
+// their details never reach the client.
+func sendError(w http.ResponseWriter, err error, fallback string) {
+	// Rejected files carry a specific code the client can act on
+	var pdfErr *pdf.Error
+	if errors.As(err, &pdfErr) {
+		sendJSONError(w, http.StatusBadRequest, pdfErr.Code, pdfErr.Message)
+		return
+	}
+
+	for _, k := range errorKinds {
+		if !errors.Is(err, k.kind) {
+			continue
+		}
+
+		response := ErrorResponse{Error: k.code, Message: k.kind.Error()}
+		var domainErr *models.Error
+		if errors.As(err, &domainErr) {
+			response = ErrorResponse{Error: domainErr.Code, Message: domainErr.Message, Field: domainErr.Field}
+		}
+		writeErrorResponse(w, k.status, response)
+		return
+	}
+
+	sendJSONError(w, http.StatusInternalServerError, "internal_error", fallback)
+}
+
+// sendInvalid sends a 400 for a request field the handler itself rejected
+func sendInvalid(w http.ResponseWriter, field, message string) {
+	sendError(w, models.Invalid(field, message), "")
+}
+
+// sendJSONError sends an ErrorResponse with the specified status code
+func sendJSONError(w http.ResponseWriter, statusCode int, errorCode, message string) {
+	writeErrorResponse(w, statusCode, ErrorResponse{Error: errorCode, Message: message})
+}
+
+// writeErrorResponse writes response as the JSON body of an error
+func writeErrorResponse(w http.ResponseWriter, statusCode int, response ErrorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Failed to encode error response", "error", err)
+	}
+	slog.Debug("Error response sent", "status", statusCode, "error_code", response.Error, "message", response.Message)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/angel-romero-f/rice-notes/internal/infra/pdf"
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/services"
+)
+
+func TestSendError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expected       ErrorResponse
+	}{
+		{
+			name:           "validation error with field",
+			err:            models.Invalid("title", "title is required"),
+			expectedStatus: http.StatusBadRequest,
+			expected:       ErrorResponse{Error: "invalid_request", Message: "title is required", Field: "title"},
+		},
+		{
+			name:           "wrapped not found",
+			err:            fmt.Errorf("failed to delete note: %w", services.ErrNoteNotFound),
+			expectedStatus: http.StatusNotFound,
+			expected:       ErrorResponse{Error: "note_not_found", Message: "note not found"},
+		},
+		{
+			name:           "bare kind",
+			err:            services.ErrForbidden,
+			expectedStatus: http.StatusForbidden,
+			expected:       ErrorResponse{Error: "forbidden", Message: services.ErrForbidden.Error()},
+		},
+		{
+			name:           "precondition failed",
+			err:            services.ErrNoteModified,
+			expectedStatus: http.StatusPreconditionFailed,
+			expected:       ErrorResponse{Error: "precondition_failed", Message: "note has been modified since it was retrieved"},
+		},
+		{
+			name:           "coded validation error",
+			err:            services.ErrInvalidCursor,
+			expectedStatus: http.StatusBadRequest,
+			expected:       ErrorResponse{Error: "invalid_cursor", Message: "cursor is invalid"},
+		},
+		{
+			name:           "unauthorized",
+			err:            fmt.Errorf("%w: token expired", services.ErrInvalidToken),
+			expectedStatus: http.StatusUnauthorized,
+			expected:       ErrorResponse{Error: "invalid_token", Message: "invalid or expired token"},
+		},
+		{
+			name:           "rejected file",
+			err:            fmt.Errorf("invalid file: %w", &pdf.Error{Code: pdf.CodeEncrypted, Message: "encrypted PDFs are not supported"}),
+			expectedStatus: http.StatusBadRequest,
+			expected:       ErrorResponse{Error: pdf.CodeEncrypted, Message: "encrypted PDFs are not supported"},
+		},
+		{
+			name:           "unexpected error hides details",
+			err:            errors.New("connection refused to 10.0.0.5:5432"),
+			expectedStatus: http.StatusInternalServerError,
+			expected:       ErrorResponse{Error: "internal_error", Message: "Failed to do the thing"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			sendError(rr, tt.err, "Failed to do the thing")
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("sendError() status = %d, want %d", rr.Code, tt.expectedStatus)
+			}
+			if contentType := rr.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("sendError() Content-Type = %q, want application/json", contentType)
+			}
+
+			var got ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+				t.Fatalf("Failed to decode error response: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("sendError() body = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+)
+
+// noteETag derives a strong entity tag from a note's updated_at, which Postgres
+// stores with microsecond precision
+func noteETag(note *models.Note) string {
+	return `"` + strconv.FormatInt(note.UpdatedAt.UnixMicro(), 10) + `"`
+}
+
+// parseIfMatch reads an If-Match header produced from noteETag. It returns nil when
+// the header is absent or "*", meaning any version may be updated. ok is false when
+// the header cannot match any version of the note, such as a weak or foreign tag.
+func parseIfMatch(header string) (updatedAt *time.Time, ok bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, true
+	}
+
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return nil, false
+	}
+
+	micros, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	t := time.UnixMicro(micros)
+	return &t, true
+}
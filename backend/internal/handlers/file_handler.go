@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/angel-romero-f/rice-notes/internal/infra/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+// SignedFileStore defines the storage operations needed to serve signed file URLs
+type SignedFileStore interface {
+	OpenSigned(key, fileName string, expires int64, signature string) (*os.File, error)
+	StoreSigned(ctx context.Context, key, contentType string, size int64, checksum string, expires int64, signature string, body io.Reader) error
+}
+
+// FileHandler serves files from local storage through signed, expiring URLs
+type FileHandler struct {
+	store SignedFileStore
+}
+
+// NewFileHandler creates a new file handler instance
+func NewFileHandler(store SignedFileStore) *FileHandler {
+	return &FileHandler{
+		store: store,
+	}
+}
+
+// ServeFile handles GET /files/* - streams a stored file, supporting range requests
+func (h *FileHandler) ServeFile(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+	query := r.URL.Query()
+	fileName := query.Get("filename")
+	signature := query.Get("signature")
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || signature == "" {
+		sendJSONError(w, http.StatusBadRequest, "invalid_file_url", "Invalid file URL")
+		return
+	}
+
+	file, err := h.store.OpenSigned(key, fileName, expires, signature)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrInvalidSignature):
+			slog.Warn("Rejected file request with bad signature", "key", key)
+			sendJSONError(w, http.StatusForbidden, "invalid_file_url", "Invalid file URL")
+		case errors.Is(err, storage.ErrURLExpired):
+			sendJSONError(w, http.StatusForbidden, "file_url_expired", "File URL expired")
+		case errors.Is(err, os.ErrNotExist):
+			sendJSONError(w, http.StatusNotFound, "file_not_found", "File not found")
+		default:
+			slog.Error("Failed to open file", "error", err, "key", key)
+			sendJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to open file")
+		}
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		slog.Error("Failed to stat file", "error", err, "key", key)
+		sendJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to open file")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", storage.ContentDisposition(fileName))
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	// ServeContent handles Range, If-Range and If-Modified-Since for us
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
+
+// UploadFile handles PUT /files/* - stores a file uploaded to a signed upload URL
+func (h *FileHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+	query := r.URL.Query()
+	checksum := query.Get("checksum")
+	signature := query.Get("signature")
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || signature == "" {
+		sendJSONError(w, http.StatusBadRequest, "invalid_file_url", "Invalid file URL")
+		return
+	}
+	size, err := strconv.ParseInt(query.Get("size"), 10, 64)
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, "invalid_file_url", "Invalid file URL")
+		return
+	}
+
+	err = h.store.StoreSigned(r.Context(), key, r.Header.Get("Content-Type"), size, checksum, expires, signature, r.Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrInvalidSignature):
+			slog.Warn("Rejected file upload with bad signature", "key", key)
+			sendJSONError(w, http.StatusForbidden, "invalid_file_url", "Invalid file URL")
+		case errors.Is(err, storage.ErrURLExpired):
+			sendJSONError(w, http.StatusForbidden, "file_url_expired", "File URL expired")
+		case errors.Is(err, storage.ErrUploadMismatch):
+			sendJSONError(w, http.StatusBadRequest, "upload_mismatch", "File does not match the upload URL")
+		default:
+			slog.Error("Failed to store file", "error", err, "key", key)
+			sendJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to store file")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -3,23 +3,45 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
-	"mime/multipart"
 	"net/http"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/angel-romero-f/rice-notes/internal/middleware"
 	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/services"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
 // NoteService defines the business logic interface for note operations
 type NoteService interface {
-	CreateNote(ctx context.Context, userEmail, title, courseID string, file multipart.File, header *multipart.FileHeader) (*models.NoteResponse, error)
-	GetNoteByID(ctx context.Context, noteID uuid.UUID, userEmail string) (*models.Note, error)
-	GetUserNotes(ctx context.Context, userEmail, courseID string, limit, offset int) ([]*models.Note, error)
-	DeleteNote(ctx context.Context, noteID uuid.UUID, userEmail string) error
+	CreateNote(ctx context.Context, caller services.Caller, req *models.CreateNoteRequest, upload *services.Upload) (*models.NoteResponse, error)
+	GetNoteByID(ctx context.Context, noteID uuid.UUID, caller services.Caller) (*models.Note, error)
+	GetNoteDownloadURL(ctx context.Context, noteID uuid.UUID, caller services.Caller) (*models.DownloadResponse, error)
+	GetUserNotes(ctx context.Context, caller services.Caller, filter *models.NoteFilter, opts *models.NoteListOptions) (*models.NotesPage, error)
+	SearchNotes(ctx context.Context, caller services.Caller, query, courseID string, limit, offset int) ([]*models.SearchResult, error)
+	UpdateNote(ctx context.Context, noteID uuid.UUID, caller services.Caller, req *models.UpdateNoteRequest, ifUnmodifiedAt *time.Time) (*models.Note, error)
+	DeleteNote(ctx context.Context, noteID uuid.UUID, caller services.Caller) error
+	ShareNote(ctx context.Context, noteID uuid.UUID, caller services.Caller, req *models.ShareNoteRequest) (*models.NoteShare, error)
+	GetNoteShares(ctx context.Context, noteID uuid.UUID, caller services.Caller) ([]*models.NoteShare, error)
+	RevokeShare(ctx context.Context, noteID uuid.UUID, caller services.Caller, granteeEmail string) error
+	GetSharedNotes(ctx context.Context, caller services.Caller, limit, offset int) ([]*models.SharedNote, error)
+	ReplaceNoteFile(ctx context.Context, noteID uuid.UUID, caller services.Caller, upload *services.Upload) (*models.Note, error)
+	GetNoteVersions(ctx context.Context, noteID uuid.UUID, caller services.Caller) ([]*models.NoteVersion, error)
+	GetNoteVersionDownloadURL(ctx context.Context, noteID uuid.UUID, caller services.Caller, version int) (*models.DownloadResponse, error)
+	RestoreNoteVersion(ctx context.Context, noteID uuid.UUID, caller services.Caller, version int) (*models.Note, error)
+	BrowseCourseNotes(ctx context.Context, caller services.Caller, courseID, cursor string, limit int) (*models.CourseNotesPage, error)
+	AddNoteTags(ctx context.Context, noteID uuid.UUID, caller services.Caller, req *models.AddTagsRequest) ([]string, error)
+	RemoveNoteTag(ctx context.Context, noteID uuid.UUID, caller services.Caller, tag string) error
+	GetUserTags(ctx context.Context, caller services.Caller) ([]*models.TagCount, error)
+	GetNoteStatus(ctx context.Context, noteID uuid.UUID, caller services.Caller, wait time.Duration) (*models.NoteStatus, error)
+	CreateUpload(ctx context.Context, caller services.Caller, req *models.CreateUploadRequest) (*models.UploadResponse, error)
+	CompleteUpload(ctx context.Context, caller services.Caller, uploadID uuid.UUID) (*models.NoteResponse, error)
+	MaxFileSize() int64
 }
 
 // NoteHandler handles HTTP requests for note operations
@@ -40,40 +62,39 @@ func (h *NoteHandler) CreateNote(w http.ResponseWriter, r *http.Request) {
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
 		slog.Error("User not found in context")
-		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
 		return
 	}
 
-	// Parse multipart form (32MB max memory)
-	if err := r.ParseMultipartForm(32 << 20); err != nil {
-		slog.Error("Failed to parse multipart form", "error", err)
-		http.Error(w, "Invalid form data", http.StatusBadRequest)
+	// Read the form fields; the file is streamed to the service unread
+	form, err := readUploadForm(w, r, h.service.MaxFileSize())
+	if err != nil {
+		sendError(w, err, "Failed to read upload")
 		return
 	}
 
 	// Extract form fields
-	title := r.FormValue("title")
-	courseID := r.FormValue("course_id")
+	req := &models.CreateNoteRequest{
+		Title:      form.fields["title"],
+		CourseID:   form.fields["course_id"],
+		Visibility: form.fields["visibility"],
+	}
 
-	if title == "" || courseID == "" {
-		http.Error(w, "Title and course_id are required", http.StatusBadRequest)
+	if req.Title == "" || req.CourseID == "" {
+		sendJSONError(w, http.StatusBadRequest, "invalid_request", "Title and course_id are required before the file")
 		return
 	}
 
-	// Get uploaded file
-	file, header, err := r.FormFile("file")
-	if err != nil {
-		slog.Error("Failed to get uploaded file", "error", err)
-		http.Error(w, "File is required", http.StatusBadRequest)
+	if form.file == nil {
+		sendInvalid(w, "file", "File is required")
 		return
 	}
-	defer file.Close()
 
 	// Create note
-	response, err := h.service.CreateNote(r.Context(), user.Email, title, courseID, file, header)
+	response, err := h.service.CreateNote(r.Context(), user.Caller(), req, form.file)
 	if err != nil {
 		slog.Error("Failed to create note", "error", err, "userEmail", user.Email)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		sendError(w, uploadError(err, h.service.MaxFileSize()), "Failed to create note")
 		return
 	}
 
@@ -83,7 +104,6 @@ func (h *NoteHandler) CreateNote(w http.ResponseWriter, r *http.Request) {
 	
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		slog.Error("Failed to encode response", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
@@ -96,57 +116,136 @@ func (h *NoteHandler) GetNotes(w http.ResponseWriter, r *http.Request) {
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
 		slog.Error("User not found in context")
-		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
 		return
 	}
 
-	// Parse query parameters
-	courseID := r.URL.Query().Get("course_id")
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-
-	// Default pagination values
-	limit := 50
-	offset := 0
+	// Parse query parameters; ?tag=a&tag=b matches notes with both tags, or either
+	// one with tag_mode=any
+	filter := &models.NoteFilter{
+		CourseID:     r.URL.Query().Get("course_id"),
+		Tags:         r.URL.Query()["tag"],
+		MatchAllTags: r.URL.Query().Get("tag_mode") != "any",
+		Statuses:     r.URL.Query()["status"],
+	}
+	if mode := r.URL.Query().Get("tag_mode"); mode != "" && mode != "all" && mode != "any" {
+		sendInvalid(w, "tag_mode", "tag_mode must be all or any")
+		return
+	}
 
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
+	// Only ready notes are listed unless ?status= asks for others; status=all lists every note
+	if slices.Contains(filter.Statuses, "all") {
+		filter.Statuses = models.NoteStatuses
 	}
 
-	if offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
+	// Pages are ordered by sort and order and continue from the previous page's cursor
+	opts := &models.NoteListOptions{
+		Sort:   r.URL.Query().Get("sort"),
+		Order:  r.URL.Query().Get("order"),
+		Cursor: r.URL.Query().Get("cursor"),
+	}
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			sendInvalid(w, "limit", "limit must be a positive integer")
+			return
 		}
+		opts.Limit = limit
 	}
 
 	// Get notes
-	notes, err := h.service.GetUserNotes(r.Context(), user.Email, courseID, limit, offset)
+	page, err := h.service.GetUserNotes(r.Context(), user.Caller(), filter, opts)
 	if err != nil {
 		slog.Error("Failed to get notes", "error", err, "userEmail", user.Email)
-		http.Error(w, "Failed to get notes", http.StatusInternalServerError)
+		sendError(w, err, "Failed to get notes")
 		return
 	}
 
 	// Return notes
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(notes); err != nil {
+	if err := json.NewEncoder(w).Encode(page); err != nil {
 		slog.Error("Failed to encode response", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	slog.Debug("Notes retrieved", "userEmail", user.Email, "count", len(notes))
+	slog.Debug("Notes retrieved", "userEmail", user.Email, "count", len(page.Items), "total", page.Total)
 }
 
-// GetNote handles GET /api/notes/{id} - retrieves a specific note (returns 302 redirect to S3)
+// SearchNotes handles GET /api/notes/search - full-text search over the notes the user can view
+func (h *NoteHandler) SearchNotes(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	// Parse query parameters
+	query := r.URL.Query().Get("q")
+	courseID := r.URL.Query().Get("course_id")
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	results, err := h.service.SearchNotes(r.Context(), user.Caller(), query, courseID, limit, offset)
+	if err != nil {
+		slog.Error("Failed to search notes", "error", err, "userEmail", user.Email)
+		sendError(w, err, "Failed to search notes")
+		return
+	}
+
+	// Always return an array, even when nothing matches
+	if results == nil {
+		results = []*models.SearchResult{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+		return
+	}
+
+	slog.Debug("Notes searched", "userEmail", user.Email, "count", len(results))
+}
+
+// BrowseCourseNotes handles GET /api/courses/{courseID}/notes - lists notes other students
+// have published for a course, paginated with ?cursor= from the previous page's next_cursor
+func (h *NoteHandler) BrowseCourseNotes(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	courseID := chi.URLParam(r, "courseID")
+	cursor := r.URL.Query().Get("cursor")
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	page, err := h.service.BrowseCourseNotes(r.Context(), user.Caller(), courseID, cursor, limit)
+	if err != nil {
+		slog.Error("Failed to browse course notes", "error", err, "courseID", courseID, "userEmail", user.Email)
+		sendError(w, err, "Failed to get course notes")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+		return
+	}
+
+	slog.Debug("Course notes browsed", "courseID", courseID, "userEmail", user.Email, "count", len(page.Items))
+}
+
+// GetNote handles GET /api/notes/{id} - retrieves a specific note's metadata
 func (h *NoteHandler) GetNote(w http.ResponseWriter, r *http.Request) {
 	// Get user from JWT context
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
 		slog.Error("User not found in context")
-		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
 		return
 	}
 
@@ -155,36 +254,190 @@ func (h *NoteHandler) GetNote(w http.ResponseWriter, r *http.Request) {
 	noteID, err := uuid.Parse(noteIDStr)
 	if err != nil {
 		slog.Error("Invalid note ID", "noteID", noteIDStr, "error", err)
-		http.Error(w, "Invalid note ID", http.StatusBadRequest)
+		sendInvalid(w, "id", "Invalid note ID")
 		return
 	}
 
 	// Get note
-	note, err := h.service.GetNoteByID(r.Context(), noteID, user.Email)
+	note, err := h.service.GetNoteByID(r.Context(), noteID, user.Caller())
 	if err != nil {
 		slog.Error("Failed to get note", "error", err, "noteID", noteID, "userEmail", user.Email)
-		http.Error(w, "Note not found", http.StatusNotFound)
+		sendError(w, err, "Failed to get note")
 		return
 	}
 
-	// For now, return note metadata. In the future, this could redirect to a presigned S3 URL
+	// Return note metadata; the file itself is served through DownloadNote
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", noteETag(note))
 	if err := json.NewEncoder(w).Encode(note); err != nil {
 		slog.Error("Failed to encode response", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
 	slog.Debug("Note retrieved", "noteID", noteID, "userEmail", user.Email)
 }
 
+// DownloadNote handles GET /api/notes/{id}/download - redirects to a presigned URL for the
+// note's file, or returns the URL as JSON when called with ?mode=json
+func (h *NoteHandler) DownloadNote(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	// Parse note ID from URL
+	noteIDStr := chi.URLParam(r, "id")
+	noteID, err := uuid.Parse(noteIDStr)
+	if err != nil {
+		slog.Error("Invalid note ID", "noteID", noteIDStr, "error", err)
+		sendInvalid(w, "id", "Invalid note ID")
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode != "" && mode != "json" && mode != "redirect" {
+		sendInvalid(w, "mode", "mode must be json or redirect")
+		return
+	}
+
+	// Get presigned download URL (checks ownership)
+	download, err := h.service.GetNoteDownloadURL(r.Context(), noteID, user.Caller())
+	if err != nil {
+		slog.Error("Failed to get download URL", "error", err, "noteID", noteID, "userEmail", user.Email)
+		sendError(w, err, "Failed to get download URL")
+		return
+	}
+
+	writeDownload(w, r, download, mode)
+	slog.Debug("Note download served", "noteID", noteID, "userEmail", user.Email, "mode", mode)
+}
+
+// GetNoteStatus handles GET /api/notes/{id}/status - reports where a note is in
+// processing. With ?wait=N it long-polls, answering once the note is no longer
+// uploading or processing or after N seconds, whichever comes first.
+func (h *NoteHandler) GetNoteStatus(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	// Parse note ID from URL
+	noteIDStr := chi.URLParam(r, "id")
+	noteID, err := uuid.Parse(noteIDStr)
+	if err != nil {
+		slog.Error("Invalid note ID", "noteID", noteIDStr, "error", err)
+		sendInvalid(w, "id", "Invalid note ID")
+		return
+	}
+
+	var wait time.Duration
+	if waitStr := r.URL.Query().Get("wait"); waitStr != "" {
+		seconds, err := strconv.Atoi(waitStr)
+		if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > services.MaxNoteStatusWait {
+			sendInvalid(w, "wait", fmt.Sprintf("wait must be a number of seconds from 0 to %d", int(services.MaxNoteStatusWait.Seconds())))
+			return
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+
+	status, err := h.service.GetNoteStatus(r.Context(), noteID, user.Caller(), wait)
+	if err != nil {
+		if r.Context().Err() != nil {
+			// The client gave up waiting
+			return
+		}
+		slog.Error("Failed to get note status", "error", err, "noteID", noteID, "userEmail", user.Email)
+		sendError(w, err, "Failed to get note status")
+		return
+	}
+
+	// Statuses change as processing runs, so they must never be cached
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+		return
+	}
+}
+
+// writeDownload redirects to a presigned download URL, or returns it as JSON in json mode
+func writeDownload(w http.ResponseWriter, r *http.Request, download *models.DownloadResponse, mode string) {
+	// Presigned URLs are short-lived and per-user, so they must never be cached
+	w.Header().Set("Cache-Control", "no-store")
+
+	if mode == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(download); err != nil {
+			slog.Error("Failed to encode response", "error", err)
+			return
+		}
+	} else {
+		http.Redirect(w, r, download.URL, http.StatusFound)
+	}
+}
+
+// UpdateNote handles PATCH /api/notes/{id} - updates a note's title, course or visibility.
+// Send the ETag from GET /api/notes/{id} as If-Match to avoid overwriting someone else's edit.
+func (h *NoteHandler) UpdateNote(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	// Parse note ID from URL
+	noteIDStr := chi.URLParam(r, "id")
+	noteID, err := uuid.Parse(noteIDStr)
+	if err != nil {
+		slog.Error("Invalid note ID", "noteID", noteIDStr, "error", err)
+		sendInvalid(w, "id", "Invalid note ID")
+		return
+	}
+
+	ifUnmodifiedAt, ok := parseIfMatch(r.Header.Get("If-Match"))
+	if !ok {
+		sendJSONError(w, http.StatusPreconditionFailed, "precondition_failed", "If-Match does not match the current version of the note")
+		return
+	}
+
+	var req models.UpdateNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, http.StatusBadRequest, "invalid_request", "Request body must be a JSON object")
+		return
+	}
+
+	note, err := h.service.UpdateNote(r.Context(), noteID, user.Caller(), &req, ifUnmodifiedAt)
+	if err != nil {
+		slog.Error("Failed to update note", "error", err, "noteID", noteID, "userEmail", user.Email)
+		sendError(w, err, "Failed to update note")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", noteETag(note))
+	if err := json.NewEncoder(w).Encode(note); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+		return
+	}
+
+	slog.Info("Note updated", "noteID", noteID, "userEmail", user.Email)
+}
+
 // DeleteNote handles DELETE /api/notes/{id} - deletes a note
 func (h *NoteHandler) DeleteNote(w http.ResponseWriter, r *http.Request) {
 	// Get user from JWT context
 	user, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
 		slog.Error("User not found in context")
-		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
 		return
 	}
 
@@ -193,14 +446,14 @@ func (h *NoteHandler) DeleteNote(w http.ResponseWriter, r *http.Request) {
 	noteID, err := uuid.Parse(noteIDStr)
 	if err != nil {
 		slog.Error("Invalid note ID", "noteID", noteIDStr, "error", err)
-		http.Error(w, "Invalid note ID", http.StatusBadRequest)
+		sendInvalid(w, "id", "Invalid note ID")
 		return
 	}
 
 	// Delete note
-	if err := h.service.DeleteNote(r.Context(), noteID, user.Email); err != nil {
+	if err := h.service.DeleteNote(r.Context(), noteID, user.Caller()); err != nil {
 		slog.Error("Failed to delete note", "error", err, "noteID", noteID, "userEmail", user.Email)
-		http.Error(w, "Failed to delete note", http.StatusInternalServerError)
+		sendError(w, err, "Failed to delete note")
 		return
 	}
 
@@ -219,7 +472,6 @@ func (h *NoteHandler) Welcome(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		slog.Error("Failed to encode welcome response", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 }
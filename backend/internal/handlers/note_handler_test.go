@@ -0,0 +1,904 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/middleware"
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// mockNoteService implements the NoteService interface for testing
+type mockNoteService struct {
+	download       *models.DownloadResponse
+	downloadError  error
+	share          *models.NoteShare
+	shareError     error
+	updated        *models.Note
+	updateError    error
+	gotIfMatch     *time.Time
+	gotFilter      *models.NoteFilter
+	gotListOptions *models.NoteListOptions
+	notesError     error
+	tagError       error
+	maxFileSize    int64
+	gotCreate      *models.CreateNoteRequest
+	gotFileName    string
+	gotFile        []byte
+	uploadError    error
+	status         *models.NoteStatus
+	statusError    error
+	gotWait        time.Duration
+}
+
+func (m *mockNoteService) CreateNote(ctx context.Context, caller services.Caller, req *models.CreateNoteRequest, upload *services.Upload) (*models.NoteResponse, error) {
+	m.gotCreate, m.gotFileName = req, upload.FileName
+	data, err := io.ReadAll(upload.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	m.gotFile = data
+	return &models.NoteResponse{ID: uuid.New(), Title: req.Title, CourseID: req.CourseID, FileName: upload.FileName}, nil
+}
+
+func (m *mockNoteService) GetNoteByID(ctx context.Context, noteID uuid.UUID, caller services.Caller) (*models.Note, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockNoteService) GetNoteDownloadURL(ctx context.Context, noteID uuid.UUID, caller services.Caller) (*models.DownloadResponse, error) {
+	if m.downloadError != nil {
+		return nil, m.downloadError
+	}
+	return m.download, nil
+}
+
+func (m *mockNoteService) GetUserNotes(ctx context.Context, caller services.Caller, filter *models.NoteFilter, opts *models.NoteListOptions) (*models.NotesPage, error) {
+	m.gotFilter, m.gotListOptions = filter, opts
+	if m.notesError != nil {
+		return nil, m.notesError
+	}
+	return &models.NotesPage{Items: []*models.Note{}}, nil
+}
+
+func (m *mockNoteService) SearchNotes(ctx context.Context, caller services.Caller, query, courseID string, limit, offset int) ([]*models.SearchResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockNoteService) UpdateNote(ctx context.Context, noteID uuid.UUID, caller services.Caller, req *models.UpdateNoteRequest, ifUnmodifiedAt *time.Time) (*models.Note, error) {
+	m.gotIfMatch = ifUnmodifiedAt
+	if m.updateError != nil {
+		return nil, m.updateError
+	}
+	return m.updated, nil
+}
+
+func (m *mockNoteService) DeleteNote(ctx context.Context, noteID uuid.UUID, caller services.Caller) error {
+	return errors.New("not implemented")
+}
+
+func (m *mockNoteService) ShareNote(ctx context.Context, noteID uuid.UUID, caller services.Caller, req *models.ShareNoteRequest) (*models.NoteShare, error) {
+	if m.shareError != nil {
+		return nil, m.shareError
+	}
+	return m.share, nil
+}
+
+func (m *mockNoteService) GetNoteShares(ctx context.Context, noteID uuid.UUID, caller services.Caller) ([]*models.NoteShare, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockNoteService) RevokeShare(ctx context.Context, noteID uuid.UUID, caller services.Caller, granteeEmail string) error {
+	return m.shareError
+}
+
+func (m *mockNoteService) GetSharedNotes(ctx context.Context, caller services.Caller, limit, offset int) ([]*models.SharedNote, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockNoteService) BrowseCourseNotes(ctx context.Context, caller services.Caller, courseID, cursor string, limit int) (*models.CourseNotesPage, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockNoteService) ReplaceNoteFile(ctx context.Context, noteID uuid.UUID, caller services.Caller, upload *services.Upload) (*models.Note, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockNoteService) GetNoteVersions(ctx context.Context, noteID uuid.UUID, caller services.Caller) ([]*models.NoteVersion, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockNoteService) GetNoteVersionDownloadURL(ctx context.Context, noteID uuid.UUID, caller services.Caller, version int) (*models.DownloadResponse, error) {
+	if m.downloadError != nil {
+		return nil, m.downloadError
+	}
+	return m.download, nil
+}
+
+func (m *mockNoteService) RestoreNoteVersion(ctx context.Context, noteID uuid.UUID, caller services.Caller, version int) (*models.Note, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockNoteService) AddNoteTags(ctx context.Context, noteID uuid.UUID, caller services.Caller, req *models.AddTagsRequest) ([]string, error) {
+	if m.tagError != nil {
+		return nil, m.tagError
+	}
+	return req.Tags, nil
+}
+
+func (m *mockNoteService) RemoveNoteTag(ctx context.Context, noteID uuid.UUID, caller services.Caller, tag string) error {
+	return m.tagError
+}
+
+func (m *mockNoteService) GetUserTags(ctx context.Context, caller services.Caller) ([]*models.TagCount, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockNoteService) CreateUpload(ctx context.Context, caller services.Caller, req *models.CreateUploadRequest) (*models.UploadResponse, error) {
+	if m.uploadError != nil {
+		return nil, m.uploadError
+	}
+	return &models.UploadResponse{UploadID: uuid.New(), URL: "https://storage.example.com/upload", Method: http.MethodPut}, nil
+}
+
+func (m *mockNoteService) CompleteUpload(ctx context.Context, caller services.Caller, uploadID uuid.UUID) (*models.NoteResponse, error) {
+	if m.uploadError != nil {
+		return nil, m.uploadError
+	}
+	return &models.NoteResponse{ID: uploadID}, nil
+}
+
+func (m *mockNoteService) GetNoteStatus(ctx context.Context, noteID uuid.UUID, caller services.Caller, wait time.Duration) (*models.NoteStatus, error) {
+	m.gotWait = wait
+	if m.statusError != nil {
+		return nil, m.statusError
+	}
+	return m.status, nil
+}
+
+func (m *mockNoteService) MaxFileSize() int64 {
+	if m.maxFileSize == 0 {
+		return services.DefaultMaxFileSize
+	}
+	return m.maxFileSize
+}
+
+// serveNoteRoute runs a note handler behind a chi router with an authenticated user
+func serveNoteRoute(pattern string, handler http.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.Use(middleware.JWTMiddleware(&mockAuthService{
+		validateResult: &services.JWTClaims{Email: "test@rice.edu"},
+	}))
+	r.Method(req.Method, pattern, handler)
+
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+// uploadBody builds a multipart upload with fields written in order; a field named
+// "file" is written as a file part
+func uploadBody(t *testing.T, fields ...[2]string) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, field := range fields {
+		var w io.Writer
+		var err error
+		if field[0] == "file" {
+			w, err = mw.CreateFormFile("file", "lecture1.pdf")
+		} else {
+			w, err = mw.CreateFormField(field[0])
+		}
+		if err != nil {
+			t.Fatalf("Failed to create form part: %v", err)
+		}
+		io.WriteString(w, field[1])
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("Failed to close form: %v", err)
+	}
+	return &body, mw.FormDataContentType()
+}
+
+func TestNoteHandler_CreateNote(t *testing.T) {
+	pdf := "%PDF-1.7\n" + strings.Repeat("x", 1000)
+	large := strings.Repeat("x", uploadFormOverhead+2048)
+
+	tests := []struct {
+		name           string
+		fields         [][2]string
+		contentType    string
+		maxFileSize    int64
+		expectedStatus int
+		expectedField  string
+	}{
+		{
+			name:           "streams the file after the fields",
+			fields:         [][2]string{{"title", "Lecture 1"}, {"course_id", "COMP 182"}, {"file", pdf}},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "fields after the file are not seen",
+			fields:         [][2]string{{"file", pdf}, {"title", "Lecture 1"}, {"course_id", "COMP 182"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing file",
+			fields:         [][2]string{{"title", "Lecture 1"}, {"course_id", "COMP 182"}},
+			expectedStatus: http.StatusBadRequest,
+			expectedField:  "file",
+		},
+		{
+			name:           "oversized field",
+			fields:         [][2]string{{"title", strings.Repeat("t", maxFormFieldSize+1)}, {"course_id", "COMP 182"}, {"file", pdf}},
+			expectedStatus: http.StatusBadRequest,
+			expectedField:  "title",
+		},
+		{
+			name:           "body over the size cap",
+			fields:         [][2]string{{"title", "Lecture 1"}, {"course_id", "COMP 182"}, {"file", large}},
+			maxFileSize:    1024,
+			expectedStatus: http.StatusBadRequest,
+			expectedField:  "file",
+		},
+		{
+			name:           "not multipart",
+			contentType:    "application/json",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &mockNoteService{maxFileSize: tt.maxFileSize}
+			handler := NewNoteHandler(service)
+
+			body, contentType := uploadBody(t, tt.fields...)
+			if tt.contentType != "" {
+				contentType = tt.contentType
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/notes", body)
+			req.Header.Set("Content-Type", contentType)
+			rr := serveNoteRoute("/api/notes", handler.CreateNote, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("CreateNote() status = %v, want %v: %s", rr.Code, tt.expectedStatus, rr.Body.String())
+			}
+
+			if tt.expectedStatus != http.StatusCreated {
+				var got ErrorResponse
+				if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+					t.Fatalf("Failed to decode error response: %v", err)
+				}
+				if got.Field != tt.expectedField {
+					t.Errorf("CreateNote() error field = %q, want %q", got.Field, tt.expectedField)
+				}
+				return
+			}
+
+			if service.gotCreate.Title != "Lecture 1" || service.gotCreate.CourseID != "COMP 182" {
+				t.Errorf("CreateNote() passed request %+v", service.gotCreate)
+			}
+			if service.gotFileName != "lecture1.pdf" || string(service.gotFile) != pdf {
+				t.Errorf("CreateNote() streamed %q (%d bytes), want lecture1.pdf (%d bytes)", service.gotFileName, len(service.gotFile), len(pdf))
+			}
+		})
+	}
+}
+
+func TestNoteHandler_DownloadNote(t *testing.T) {
+	noteID := uuid.New()
+	download := &models.DownloadResponse{
+		URL:       "https://bucket.s3.amazonaws.com/notes/key.pdf?X-Amz-Signature=abc",
+		FileName:  "lecture1.pdf",
+		ExpiresAt: time.Now().Add(5 * time.Minute),
+	}
+
+	tests := []struct {
+		name             string
+		path             string
+		downloadError    error
+		expectedStatus   int
+		expectedLocation string
+		expectJSON       bool
+	}{
+		{
+			name:             "redirects to presigned URL by default",
+			path:             "/api/notes/" + noteID.String() + "/download",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: download.URL,
+		},
+		{
+			name:           "returns URL as JSON",
+			path:           "/api/notes/" + noteID.String() + "/download?mode=json",
+			expectedStatus: http.StatusOK,
+			expectJSON:     true,
+		},
+		{
+			name:           "rejects unknown mode",
+			path:           "/api/notes/" + noteID.String() + "/download?mode=inline",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid note ID",
+			path:           "/api/notes/not-a-uuid/download",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "note not owned by user",
+			path:           "/api/notes/" + noteID.String() + "/download",
+			downloadError:  services.ErrNoteNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewNoteHandler(&mockNoteService{
+				download:      download,
+				downloadError: tt.downloadError,
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rr := serveNoteRoute("/api/notes/{id}/download", handler.DownloadNote, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("DownloadNote() status = %v, want %v", rr.Code, tt.expectedStatus)
+			}
+
+			if tt.expectedLocation != "" {
+				if location := rr.Header().Get("Location"); location != tt.expectedLocation {
+					t.Errorf("Expected redirect to %v, got %v", tt.expectedLocation, location)
+				}
+			}
+
+			if tt.expectJSON {
+				var got models.DownloadResponse
+				if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if got.URL != download.URL || got.FileName != download.FileName {
+					t.Errorf("DownloadNote() = %+v, want %+v", got, download)
+				}
+			}
+
+			if rr.Code < 400 && rr.Header().Get("Cache-Control") != "no-store" {
+				t.Error("Expected Cache-Control: no-store on download response")
+			}
+		})
+	}
+}
+
+func TestNoteHandler_ShareNote(t *testing.T) {
+	noteID := uuid.New()
+	share := &models.NoteShare{
+		NoteID:       noteID,
+		GranteeEmail: "friend@rice.edu",
+		Role:         models.ShareRoleViewer,
+		GrantedBy:    "test@rice.edu",
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		shareError     error
+		expectedStatus int
+	}{
+		{
+			name:           "grants share",
+			body:           `{"email":"friend@rice.edu","role":"viewer"}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "invalid body",
+			body:           `not json`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "validation error",
+			body:           `{"email":"someone@gmail.com","role":"viewer"}`,
+			shareError:     models.Invalid("email", "notes can only be shared with Rice University emails"),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "note not visible to user",
+			body:           `{"email":"friend@rice.edu","role":"viewer"}`,
+			shareError:     services.ErrNoteNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "editor cannot share",
+			body:           `{"email":"friend@rice.edu","role":"editor"}`,
+			shareError:     services.ErrForbidden,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewNoteHandler(&mockNoteService{
+				share:      share,
+				shareError: tt.shareError,
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/notes/"+noteID.String()+"/shares", strings.NewReader(tt.body))
+			rr := serveNoteRoute("/api/notes/{id}/shares", handler.ShareNote, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("ShareNote() status = %v, want %v", rr.Code, tt.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestNoteHandler_CreateUpload(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		uploadError    error
+		expectedStatus int
+	}{
+		{
+			name:           "returns upload URL",
+			body:           `{"title":"Lecture 1","course_id":"COMP 182","file_name":"lecture1.pdf","file_size":1024,"sha256":"` + strings.Repeat("a", 64) + `"}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "invalid body",
+			body:           `not json`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "validation error",
+			body:           `{"title":"Lecture 1","course_id":"COMP 182","file_name":"lecture1.pdf","file_size":0}`,
+			uploadError:    models.Invalid("file_size", "file cannot be empty"),
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewNoteHandler(&mockNoteService{uploadError: tt.uploadError})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/notes/uploads", strings.NewReader(tt.body))
+			rr := serveNoteRoute("/api/notes/uploads", handler.CreateUpload, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("CreateUpload() status = %v, want %v", rr.Code, tt.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestNoteHandler_CompleteUpload(t *testing.T) {
+	tests := []struct {
+		name           string
+		uploadID       string
+		uploadError    error
+		expectedStatus int
+	}{
+		{
+			name:           "creates note",
+			uploadID:       uuid.New().String(),
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "invalid upload ID",
+			uploadID:       "not-a-uuid",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "upload expired",
+			uploadID:       uuid.New().String(),
+			uploadError:    services.ErrUploadNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "file not uploaded yet",
+			uploadID:       uuid.New().String(),
+			uploadError:    services.ErrUploadIncomplete,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "file does not match",
+			uploadID:       uuid.New().String(),
+			uploadError:    services.ErrUploadMismatch,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewNoteHandler(&mockNoteService{uploadError: tt.uploadError})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/notes/uploads/"+tt.uploadID+"/complete", nil)
+			rr := serveNoteRoute("/api/notes/uploads/{id}/complete", handler.CompleteUpload, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("CompleteUpload() status = %v, want %v", rr.Code, tt.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestNoteHandler_RevokeShare(t *testing.T) {
+	noteID := uuid.New()
+
+	tests := []struct {
+		name           string
+		shareError     error
+		expectedStatus int
+	}{
+		{
+			name:           "revokes share",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "share does not exist",
+			shareError:     services.ErrShareNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "not the owner",
+			shareError:     services.ErrForbidden,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewNoteHandler(&mockNoteService{shareError: tt.shareError})
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/notes/"+noteID.String()+"/shares/friend@rice.edu", nil)
+			rr := serveNoteRoute("/api/notes/{id}/shares/{email}", handler.RevokeShare, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("RevokeShare() status = %v, want %v", rr.Code, tt.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestNoteHandler_UpdateNote(t *testing.T) {
+	noteID := uuid.New()
+	updatedAt := time.Date(2024, 9, 3, 14, 5, 6, 123456000, time.UTC)
+	updated := &models.Note{ID: noteID, Title: "Lecture 1 (fixed)", UpdatedAt: updatedAt.Add(time.Second)}
+	currentETag := noteETag(&models.Note{UpdatedAt: updatedAt})
+
+	tests := []struct {
+		name           string
+		ifMatch        string
+		body           string
+		updateError    error
+		expectedStatus int
+		expectIfMatch  bool
+	}{
+		{
+			name:           "updates without precondition",
+			body:           `{"title":"Lecture 1 (fixed)"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "passes If-Match to the service",
+			ifMatch:        currentETag,
+			body:           `{"title":"Lecture 1 (fixed)"}`,
+			expectedStatus: http.StatusOK,
+			expectIfMatch:  true,
+		},
+		{
+			name:           "stale If-Match",
+			ifMatch:        currentETag,
+			body:           `{"title":"Lecture 1 (fixed)"}`,
+			updateError:    services.ErrNoteModified,
+			expectedStatus: http.StatusPreconditionFailed,
+			expectIfMatch:  true,
+		},
+		{
+			name:           "weak If-Match never matches",
+			ifMatch:        "W/" + currentETag,
+			body:           `{"title":"Lecture 1 (fixed)"}`,
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "validation error",
+			body:           `{"title":""}`,
+			updateError:    models.Invalid("", "title is required"),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "viewer cannot edit",
+			body:           `{"title":"Mine now"}`,
+			updateError:    services.ErrForbidden,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &mockNoteService{updated: updated, updateError: tt.updateError}
+			handler := NewNoteHandler(service)
+
+			req := httptest.NewRequest(http.MethodPatch, "/api/notes/"+noteID.String(), strings.NewReader(tt.body))
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rr := serveNoteRoute("/api/notes/{id}", handler.UpdateNote, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("UpdateNote() status = %v, want %v", rr.Code, tt.expectedStatus)
+			}
+
+			if tt.expectIfMatch && (service.gotIfMatch == nil || !service.gotIfMatch.Equal(updatedAt)) {
+				t.Errorf("UpdateNote() passed If-Match %v, want %v", service.gotIfMatch, updatedAt)
+			}
+
+			if rr.Code == http.StatusOK && rr.Header().Get("ETag") != noteETag(updated) {
+				t.Errorf("ETag = %v, want %v", rr.Header().Get("ETag"), noteETag(updated))
+			}
+		})
+	}
+}
+
+func TestNoteHandler_DownloadNoteVersion(t *testing.T) {
+	noteID := uuid.New()
+	download := &models.DownloadResponse{
+		URL:       "https://bucket.s3.amazonaws.com/notes/versions/key.pdf?X-Amz-Signature=abc",
+		FileName:  "lecture1.pdf",
+		ExpiresAt: time.Now().Add(5 * time.Minute),
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		downloadError  error
+		expectedStatus int
+	}{
+		{
+			name:           "redirects to revision",
+			path:           "/api/notes/" + noteID.String() + "/versions/2/download",
+			expectedStatus: http.StatusFound,
+		},
+		{
+			name:           "invalid version",
+			path:           "/api/notes/" + noteID.String() + "/versions/0/download",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown version",
+			path:           "/api/notes/" + noteID.String() + "/versions/9/download",
+			downloadError:  services.ErrVersionNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewNoteHandler(&mockNoteService{
+				download:      download,
+				downloadError: tt.downloadError,
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rr := serveNoteRoute("/api/notes/{id}/versions/{version}/download", handler.DownloadNoteVersion, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("DownloadNoteVersion() status = %v, want %v", rr.Code, tt.expectedStatus)
+			}
+			if rr.Code == http.StatusFound && rr.Header().Get("Location") != download.URL {
+				t.Errorf("Expected redirect to %v, got %v", download.URL, rr.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestNoteHandler_GetNoteStatus(t *testing.T) {
+	noteID := uuid.New()
+	status := &models.NoteStatus{ID: noteID, Status: models.NoteStatusProcessing}
+
+	tests := []struct {
+		name           string
+		query          string
+		statusError    error
+		expectedStatus int
+		wantWait       time.Duration
+	}{
+		{
+			name:           "returns status",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "long polls",
+			query:          "?wait=30",
+			expectedStatus: http.StatusOK,
+			wantWait:       30 * time.Second,
+		},
+		{
+			name:           "wait too long",
+			query:          "?wait=600",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid wait",
+			query:          "?wait=soon",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "note not visible to user",
+			statusError:    services.ErrNoteNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &mockNoteService{status: status, statusError: tt.statusError}
+			handler := NewNoteHandler(service)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/notes/"+noteID.String()+"/status"+tt.query, nil)
+			rr := serveNoteRoute("/api/notes/{id}/status", handler.GetNoteStatus, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("GetNoteStatus() status = %v, want %v", rr.Code, tt.expectedStatus)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+			if service.gotWait != tt.wantWait {
+				t.Errorf("GetNoteStatus() wait = %v, want %v", service.gotWait, tt.wantWait)
+			}
+			var got models.NoteStatus
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil || got != *status {
+				t.Errorf("GetNoteStatus() body = %s, want %+v", rr.Body.String(), *status)
+			}
+		})
+	}
+}
+
+func TestNoteHandler_GetNotes(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		notesError     error
+		expectedStatus int
+		wantFilter     *models.NoteFilter
+		wantOptions    *models.NoteListOptions
+	}{
+		{
+			name:           "all tags by default",
+			query:          "?tag=midterm&tag=lecture-12",
+			expectedStatus: http.StatusOK,
+			wantFilter:     &models.NoteFilter{Tags: []string{"midterm", "lecture-12"}, MatchAllTags: true},
+		},
+		{
+			name:           "any tag",
+			query:          "?tag=midterm&tag=final&tag_mode=any&course_id=COMP+140",
+			expectedStatus: http.StatusOK,
+			wantFilter:     &models.NoteFilter{CourseID: "COMP 140", Tags: []string{"midterm", "final"}},
+		},
+		{
+			name:           "sorted page",
+			query:          "?sort=file_size&order=desc&cursor=abc&limit=25",
+			expectedStatus: http.StatusOK,
+			wantOptions:    &models.NoteListOptions{Sort: "file_size", Order: "desc", Cursor: "abc", Limit: 25},
+		},
+		{
+			name:           "other statuses",
+			query:          "?status=processing&status=failed",
+			expectedStatus: http.StatusOK,
+			wantFilter:     &models.NoteFilter{Statuses: []string{"processing", "failed"}, MatchAllTags: true},
+		},
+		{
+			name:           "every status",
+			query:          "?status=all",
+			expectedStatus: http.StatusOK,
+			wantFilter:     &models.NoteFilter{Statuses: models.NoteStatuses, MatchAllTags: true},
+		},
+		{
+			name:           "unknown tag mode",
+			query:          "?tag=midterm&tag_mode=some",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid limit",
+			query:          "?limit=lots",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid cursor",
+			query:          "?cursor=stale",
+			notesError:     services.ErrInvalidCursor,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid tag",
+			query:          "?tag=%21%21",
+			notesError:     models.Invalid("", "invalid tag"),
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &mockNoteService{notesError: tt.notesError}
+			handler := NewNoteHandler(service)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/notes"+tt.query, nil)
+			rr := serveNoteRoute("/api/notes", handler.GetNotes, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("GetNotes() status = %v, want %v", rr.Code, tt.expectedStatus)
+			}
+			if tt.wantFilter != nil && !reflect.DeepEqual(service.gotFilter, tt.wantFilter) {
+				t.Errorf("GetNotes() filter = %+v, want %+v", service.gotFilter, tt.wantFilter)
+			}
+			if tt.wantOptions != nil && !reflect.DeepEqual(service.gotListOptions, tt.wantOptions) {
+				t.Errorf("GetNotes() options = %+v, want %+v", service.gotListOptions, tt.wantOptions)
+			}
+			if rr.Code == http.StatusOK {
+				var page map[string]json.RawMessage
+				if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+					t.Fatalf("GetNotes() response is not a JSON object: %v", err)
+				}
+				if _, ok := page["items"]; !ok {
+					t.Error("GetNotes() response has no items")
+				}
+				if _, ok := page["total"]; !ok {
+					t.Error("GetNotes() response has no total")
+				}
+			}
+		})
+	}
+}
+
+func TestNoteHandler_RemoveNoteTag(t *testing.T) {
+	noteID := uuid.New()
+
+	tests := []struct {
+		name           string
+		tagError       error
+		expectedStatus int
+	}{
+		{
+			name:           "removes tag",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "tag not on note",
+			tagError:       services.ErrTagNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "not the owner",
+			tagError:       services.ErrForbidden,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewNoteHandler(&mockNoteService{tagError: tt.tagError})
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/notes/"+noteID.String()+"/tags/midterm", nil)
+			rr := serveNoteRoute("/api/notes/{id}/tags/{tag}", handler.RemoveNoteTag, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("RemoveNoteTag() status = %v, want %v", rr.Code, tt.expectedStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/angel-romero-f/rice-notes/internal/middleware"
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// ShareNote handles POST /api/notes/{id}/shares - grants a Rice user access to a note
+func (h *NoteHandler) ShareNote(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	// Parse note ID from URL
+	noteIDStr := chi.URLParam(r, "id")
+	noteID, err := uuid.Parse(noteIDStr)
+	if err != nil {
+		slog.Error("Invalid note ID", "noteID", noteIDStr, "error", err)
+		sendInvalid(w, "id", "Invalid note ID")
+		return
+	}
+
+	var req models.ShareNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, http.StatusBadRequest, "invalid_request", "Request body must be JSON with email and role")
+		return
+	}
+
+	share, err := h.service.ShareNote(r.Context(), noteID, user.Caller(), &req)
+	if err != nil {
+		slog.Error("Failed to share note", "error", err, "noteID", noteID, "userEmail", user.Email)
+		sendError(w, err, "Failed to share note")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(share); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+		return
+	}
+
+	slog.Info("Note shared", "noteID", noteID, "userEmail", user.Email, "grantee", share.GranteeEmail)
+}
+
+// GetNoteShares handles GET /api/notes/{id}/shares - lists who a note is shared with
+func (h *NoteHandler) GetNoteShares(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	// Parse note ID from URL
+	noteIDStr := chi.URLParam(r, "id")
+	noteID, err := uuid.Parse(noteIDStr)
+	if err != nil {
+		slog.Error("Invalid note ID", "noteID", noteIDStr, "error", err)
+		sendInvalid(w, "id", "Invalid note ID")
+		return
+	}
+
+	shares, err := h.service.GetNoteShares(r.Context(), noteID, user.Caller())
+	if err != nil {
+		slog.Error("Failed to get note shares", "error", err, "noteID", noteID, "userEmail", user.Email)
+		sendError(w, err, "Failed to get note shares")
+		return
+	}
+
+	// Always return an array, even when the note isn't shared
+	if shares == nil {
+		shares = []*models.NoteShare{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(shares); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+		return
+	}
+}
+
+// RevokeShare handles DELETE /api/notes/{id}/shares/{email} - revokes a user's access
+func (h *NoteHandler) RevokeShare(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	// Parse note ID from URL
+	noteIDStr := chi.URLParam(r, "id")
+	noteID, err := uuid.Parse(noteIDStr)
+	if err != nil {
+		slog.Error("Invalid note ID", "noteID", noteIDStr, "error", err)
+		sendInvalid(w, "id", "Invalid note ID")
+		return
+	}
+
+	grantee, err := url.PathUnescape(chi.URLParam(r, "email"))
+	if err != nil || grantee == "" {
+		sendInvalid(w, "email", "Invalid email")
+		return
+	}
+
+	if err := h.service.RevokeShare(r.Context(), noteID, user.Caller(), grantee); err != nil {
+		slog.Error("Failed to revoke share", "error", err, "noteID", noteID, "userEmail", user.Email)
+		sendError(w, err, "Failed to revoke share")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	slog.Info("Share revoked", "noteID", noteID, "userEmail", user.Email, "grantee", grantee)
+}
+
+// GetSharedNotes handles GET /api/notes/shared-with-me - lists notes shared with the user
+func (h *NoteHandler) GetSharedNotes(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	notes, err := h.service.GetSharedNotes(r.Context(), user.Caller(), limit, offset)
+	if err != nil {
+		slog.Error("Failed to get shared notes", "error", err, "userEmail", user.Email)
+		sendError(w, err, "Failed to get shared notes")
+		return
+	}
+
+	// Always return an array, even when nothing is shared
+	if notes == nil {
+		notes = []*models.SharedNote{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(notes); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+		return
+	}
+
+	slog.Debug("Shared notes retrieved", "userEmail", user.Email, "count", len(notes))
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/angel-romero-f/rice-notes/internal/middleware"
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// AddNoteTags handles POST /api/notes/{id}/tags - tags one of the user's notes
+func (h *NoteHandler) AddNoteTags(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	// Parse note ID from URL
+	noteIDStr := chi.URLParam(r, "id")
+	noteID, err := uuid.Parse(noteIDStr)
+	if err != nil {
+		slog.Error("Invalid note ID", "noteID", noteIDStr, "error", err)
+		sendInvalid(w, "id", "Invalid note ID")
+		return
+	}
+
+	var req models.AddTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendInvalid(w, "tags", "Request body must be JSON with a tags array")
+		return
+	}
+
+	tags, err := h.service.AddNoteTags(r.Context(), noteID, user.Caller(), &req)
+	if err != nil {
+		slog.Error("Failed to tag note", "error", err, "noteID", noteID, "userEmail", user.Email)
+		sendError(w, err, "Failed to tag note")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tags); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+		return
+	}
+}
+
+// RemoveNoteTag handles DELETE /api/notes/{id}/tags/{tag} - removes a tag from a note
+func (h *NoteHandler) RemoveNoteTag(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	// Parse note ID from URL
+	noteIDStr := chi.URLParam(r, "id")
+	noteID, err := uuid.Parse(noteIDStr)
+	if err != nil {
+		slog.Error("Invalid note ID", "noteID", noteIDStr, "error", err)
+		sendInvalid(w, "id", "Invalid note ID")
+		return
+	}
+
+	tag, err := url.PathUnescape(chi.URLParam(r, "tag"))
+	if err != nil || tag == "" {
+		sendInvalid(w, "tag", "Invalid tag")
+		return
+	}
+
+	if err := h.service.RemoveNoteTag(r.Context(), noteID, user.Caller(), tag); err != nil {
+		slog.Error("Failed to remove note tag", "error", err, "noteID", noteID, "userEmail", user.Email)
+		sendError(w, err, "Failed to remove note tag")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetTags handles GET /api/notes/tags - lists the user's tags with note counts
+func (h *NoteHandler) GetTags(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	tags, err := h.service.GetUserTags(r.Context(), user.Caller())
+	if err != nil {
+		slog.Error("Failed to get tags", "error", err, "userEmail", user.Email)
+		sendError(w, err, "Failed to get tags")
+		return
+	}
+
+	// Always return an array, even when the user has no tags
+	if tags == nil {
+		tags = []*models.TagCount{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tags); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+		return
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/angel-romero-f/rice-notes/internal/middleware"
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// CreateUpload handles POST /api/notes/uploads - starts an upload straight to storage,
+// returning the presigned URL to send the file to
+func (h *NoteHandler) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	var req models.CreateUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, http.StatusBadRequest, "invalid_request", "Request body must be JSON with title, course_id, file_name, file_size and sha256")
+		return
+	}
+
+	upload, err := h.service.CreateUpload(r.Context(), user.Caller(), &req)
+	if err != nil {
+		slog.Error("Failed to create upload", "error", err, "userEmail", user.Email)
+		sendError(w, err, "Failed to create upload")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(upload); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+		return
+	}
+
+	slog.Info("Upload created", "uploadID", upload.UploadID, "userEmail", user.Email)
+}
+
+// CompleteUpload handles POST /api/notes/uploads/{id}/complete - creates the note once
+// its file is in storage
+func (h *NoteHandler) CompleteUpload(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	// Parse upload ID from URL
+	uploadIDStr := chi.URLParam(r, "id")
+	uploadID, err := uuid.Parse(uploadIDStr)
+	if err != nil {
+		slog.Error("Invalid upload ID", "uploadID", uploadIDStr, "error", err)
+		sendInvalid(w, "id", "Invalid upload ID")
+		return
+	}
+
+	note, err := h.service.CompleteUpload(r.Context(), user.Caller(), uploadID)
+	if err != nil {
+		slog.Error("Failed to complete upload", "error", err, "uploadID", uploadID, "userEmail", user.Email)
+		sendError(w, err, "Failed to complete upload")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(note); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+		return
+	}
+
+	slog.Info("Note created from upload", "noteID", note.ID, "userEmail", user.Email)
+}
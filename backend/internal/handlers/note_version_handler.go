@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/angel-romero-f/rice-notes/internal/middleware"
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// ReplaceNoteFile handles PUT /api/notes/{id}/file - uploads a new revision of a note's PDF
+func (h *NoteHandler) ReplaceNoteFile(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	// Parse note ID from URL
+	noteIDStr := chi.URLParam(r, "id")
+	noteID, err := uuid.Parse(noteIDStr)
+	if err != nil {
+		slog.Error("Invalid note ID", "noteID", noteIDStr, "error", err)
+		sendInvalid(w, "id", "Invalid note ID")
+		return
+	}
+
+	// The file is streamed to the service unread
+	form, err := readUploadForm(w, r, h.service.MaxFileSize())
+	if err != nil {
+		sendError(w, err, "Failed to read upload")
+		return
+	}
+	if form.file == nil {
+		sendInvalid(w, "file", "File is required")
+		return
+	}
+
+	note, err := h.service.ReplaceNoteFile(r.Context(), noteID, user.Caller(), form.file)
+	if err != nil {
+		slog.Error("Failed to replace note file", "error", err, "noteID", noteID, "userEmail", user.Email)
+		sendError(w, uploadError(err, h.service.MaxFileSize()), "Failed to replace note file")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", noteETag(note))
+	if err := json.NewEncoder(w).Encode(note); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+		return
+	}
+
+	slog.Info("Note file replaced", "noteID", noteID, "version", note.Version, "userEmail", user.Email)
+}
+
+// GetNoteVersions handles GET /api/notes/{id}/versions - lists a note's revisions, newest first
+func (h *NoteHandler) GetNoteVersions(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	// Parse note ID from URL
+	noteIDStr := chi.URLParam(r, "id")
+	noteID, err := uuid.Parse(noteIDStr)
+	if err != nil {
+		slog.Error("Invalid note ID", "noteID", noteIDStr, "error", err)
+		sendInvalid(w, "id", "Invalid note ID")
+		return
+	}
+
+	versions, err := h.service.GetNoteVersions(r.Context(), noteID, user.Caller())
+	if err != nil {
+		slog.Error("Failed to get note versions", "error", err, "noteID", noteID, "userEmail", user.Email)
+		sendError(w, err, "Failed to get note versions")
+		return
+	}
+
+	if versions == nil {
+		versions = []*models.NoteVersion{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(versions); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+		return
+	}
+}
+
+// DownloadNoteVersion handles GET /api/notes/{id}/versions/{version}/download - like
+// DownloadNote, but for a specific revision
+func (h *NoteHandler) DownloadNoteVersion(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	noteID, version, ok := parseVersionParams(w, r)
+	if !ok {
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode != "" && mode != "json" && mode != "redirect" {
+		sendInvalid(w, "mode", "mode must be json or redirect")
+		return
+	}
+
+	download, err := h.service.GetNoteVersionDownloadURL(r.Context(), noteID, user.Caller(), version)
+	if err != nil {
+		slog.Error("Failed to get version download URL", "error", err, "noteID", noteID, "version", version, "userEmail", user.Email)
+		sendError(w, err, "Failed to get download URL")
+		return
+	}
+
+	writeDownload(w, r, download, mode)
+	slog.Debug("Note version download served", "noteID", noteID, "version", version, "userEmail", user.Email, "mode", mode)
+}
+
+// RestoreNoteVersion handles POST /api/notes/{id}/versions/{version}/restore - makes an
+// older revision current again
+func (h *NoteHandler) RestoreNoteVersion(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	noteID, version, ok := parseVersionParams(w, r)
+	if !ok {
+		return
+	}
+
+	note, err := h.service.RestoreNoteVersion(r.Context(), noteID, user.Caller(), version)
+	if err != nil {
+		slog.Error("Failed to restore note version", "error", err, "noteID", noteID, "version", version, "userEmail", user.Email)
+		sendError(w, err, "Failed to restore note version")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", noteETag(note))
+	if err := json.NewEncoder(w).Encode(note); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+		return
+	}
+
+	slog.Info("Note version restored", "noteID", noteID, "from", version, "userEmail", user.Email)
+}
+
+// parseVersionParams reads the note ID and revision number from the URL, writing a
+// 400 response and returning false if either is invalid
+func parseVersionParams(w http.ResponseWriter, r *http.Request) (uuid.UUID, int, bool) {
+	noteIDStr := chi.URLParam(r, "id")
+	noteID, err := uuid.Parse(noteIDStr)
+	if err != nil {
+		slog.Error("Invalid note ID", "noteID", noteIDStr, "error", err)
+		sendInvalid(w, "id", "Invalid note ID")
+		return uuid.Nil, 0, false
+	}
+
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil || version < 1 {
+		sendInvalid(w, "version", "Invalid version")
+		return uuid.Nil, 0, false
+	}
+
+	return noteID, version, true
+}
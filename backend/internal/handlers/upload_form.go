@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/services"
+)
+
+const (
+	// maxFormFieldSize bounds each text field of an upload form
+	maxFormFieldSize = 4 << 10
+	// uploadFormOverhead is allowed on top of the file size for the text fields and
+	// the multipart boundaries and headers
+	uploadFormOverhead = 64 << 10
+)
+
+// uploadForm is a multipart upload read up to its file part. The file is left
+// unread so it can be streamed straight into storage.
+type uploadForm struct {
+	fields map[string]string
+	file   *services.Upload
+}
+
+// readUploadForm reads the text fields of a multipart upload until it reaches the
+// "file" part. Fields sent after the file are never seen, so clients must send the
+// file last. The whole body is capped at maxFileSize plus a little for the form.
+func readUploadForm(w http.ResponseWriter, r *http.Request, maxFileSize int64) (*uploadForm, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFileSize+uploadFormOverhead)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, models.Invalid("", "Request body must be multipart form data")
+	}
+
+	form := &uploadForm{fields: make(map[string]string)}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			return nil, uploadReadError(err, maxFileSize)
+		}
+
+		if part.FormName() == "file" {
+			form.file = &services.Upload{FileName: part.FileName(), Body: part}
+			return form, nil
+		}
+
+		value, err := readFormField(part)
+		if err != nil {
+			return nil, uploadReadError(err, maxFileSize)
+		}
+		form.fields[part.FormName()] = value
+	}
+}
+
+// readFormField reads a text field, rejecting values longer than maxFormFieldSize
+func readFormField(part *multipart.Part) (string, error) {
+	value, err := io.ReadAll(io.LimitReader(part, maxFormFieldSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(value) > maxFormFieldSize {
+		return "", models.Invalid(part.FormName(), fmt.Sprintf("%s is too long", part.FormName()))
+	}
+	return string(value), nil
+}
+
+// uploadReadError explains a failure to read an upload form. Bodies that aren't
+// valid multipart data are reported against the request as a whole.
+func uploadReadError(err error, maxFileSize int64) error {
+	err = uploadError(err, maxFileSize)
+	if errors.Is(err, services.ErrValidation) {
+		return err
+	}
+	slog.Warn("Failed to read multipart form", "error", err)
+	return models.Invalid("", "Request body is not valid multipart form data")
+}
+
+// uploadError reports an upload that failed because the body passed the size cap
+// against the file; other errors are returned unchanged
+func uploadError(err error, maxFileSize int64) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return models.Invalid("file", fmt.Sprintf("file size must be at most %d bytes", maxFileSize))
+	}
+	return err
+}
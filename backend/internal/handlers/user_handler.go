@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/angel-romero-f/rice-notes/internal/middleware"
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/services"
+)
+
+// UserService defines the business logic for user profile operations
+type UserService interface {
+	GetCurrentUser(ctx context.Context, caller services.Caller) (*models.User, error)
+	UpdateCurrentUser(ctx context.Context, caller services.Caller, req *models.UpdateUserRequest) (*models.User, error)
+}
+
+// UserHandler handles HTTP requests for user profiles
+type UserHandler struct {
+	service UserService
+}
+
+// NewUserHandler returns a new UserHandler instance with the provided UserService
+func NewUserHandler(s UserService) *UserHandler {
+	return &UserHandler{service: s}
+}
+
+// GetMe handles GET /api/users/me - returns the signed-in user's profile
+func (h *UserHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	user, err := h.service.GetCurrentUser(r.Context(), claims.Caller())
+	if err != nil {
+		slog.Error("Failed to get user", "error", err, "userEmail", claims.Email)
+		sendError(w, err, "Failed to get user")
+		return
+	}
+
+	writeUser(w, user)
+}
+
+// UpdateMe handles PATCH /api/users/me - changes the signed-in user's profile fields
+func (h *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	// Get user from JWT context
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		slog.Error("User not found in context")
+		sendJSONError(w, http.StatusUnauthorized, "no_token", "Authentication required")
+		return
+	}
+
+	var req models.UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, http.StatusBadRequest, "invalid_request", "Request body must be JSON")
+		return
+	}
+
+	user, err := h.service.UpdateCurrentUser(r.Context(), claims.Caller(), &req)
+	if err != nil {
+		slog.Error("Failed to update user", "error", err, "userEmail", claims.Email)
+		sendError(w, err, "Failed to update user")
+		return
+	}
+
+	writeUser(w, user)
+	slog.Info("User profile updated", "userEmail", claims.Email)
+}
+
+// writeUser writes a user as the JSON response
+func writeUser(w http.ResponseWriter, user *models.User) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+		return
+	}
+}
@@ -0,0 +1,163 @@
+// Package jobs is a durable background job queue stored in PostgreSQL. Jobs are
+// enqueued with Enqueue, often inside the transaction that creates the data they
+// act on, and run by a worker Pool through handlers registered by job type.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Job states. Jobs that succeed are deleted rather than kept.
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDead    = "dead"
+)
+
+// Defaults for handlers registered without options
+const (
+	DefaultTimeout     = 5 * time.Minute
+	DefaultMaxAttempts = 5
+)
+
+// Job is a unit of background work
+type Job struct {
+	ID        uuid.UUID
+	Type      string
+	Payload   json.RawMessage
+	Attempts  int
+	LastError string
+	RunAt     time.Time
+	CreatedAt time.Time
+}
+
+// Decode unmarshals the job's payload into v
+func (j *Job) Decode(v any) error {
+	if err := json.Unmarshal(j.Payload, v); err != nil {
+		return Permanent(fmt.Errorf("invalid %s job payload: %w", j.Type, err))
+	}
+	return nil
+}
+
+// Handler runs a job. Returning an error retries the job later, unless it is
+// wrapped with Permanent or the job is out of attempts.
+type Handler func(ctx context.Context, job *Job) error
+
+// Options tune how jobs of one type are run. Zero fields take the defaults.
+type Options struct {
+	// Timeout bounds a single attempt
+	Timeout time.Duration
+	// MaxAttempts is how often a job is tried before it is left dead
+	MaxAttempts int
+	// OnDead, if set, is called with the last error once a job is left dead, so
+	// whatever the job was for can be marked as failed
+	OnDead func(ctx context.Context, job *Job, err error)
+}
+
+type registration struct {
+	handler Handler
+	opts    Options
+}
+
+// Registry maps job types to the handlers that run them
+type Registry struct {
+	handlers map[string]registration
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]registration)}
+}
+
+// Register sets the handler for jobType. Registering a type twice is a programming
+// error and panics.
+func (r *Registry) Register(jobType string, handler Handler, opts Options) {
+	if _, ok := r.handlers[jobType]; ok {
+		panic(fmt.Sprintf("jobs: handler for %q registered twice", jobType))
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	r.handlers[jobType] = registration{handler: handler, opts: opts}
+}
+
+// Types lists the registered job types
+func (r *Registry) Types() []string {
+	types := make([]string, 0, len(r.handlers))
+	for jobType := range r.handlers {
+		types = append(types, jobType)
+	}
+	return types
+}
+
+func (r *Registry) lookup(jobType string) (registration, bool) {
+	reg, ok := r.handlers[jobType]
+	return reg, ok
+}
+
+// Execer runs a statement; both *pgxpool.Pool and pgx.Tx satisfy it
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// Enqueue adds a job of jobType whose payload is v marshalled to JSON. When db is a
+// transaction the job only becomes visible to workers if the transaction commits.
+func Enqueue(ctx context.Context, db Execer, jobType string, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s job payload: %w", jobType, err)
+	}
+
+	_, err = db.Exec(ctx, `INSERT INTO jobs (type, payload) VALUES ($1, $2)`, jobType, payload)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue %s job: %w", jobType, err)
+	}
+	return nil
+}
+
+// permanentError marks a failure retrying won't fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job fails without further attempts
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+// Backoff delays between attempts: 10s after the first failure, doubling up to an hour
+const (
+	minBackoff = 10 * time.Second
+	maxBackoff = time.Hour
+)
+
+// Backoff returns how long to wait before retrying a job that has failed attempts times
+func Backoff(attempts int) time.Duration {
+	delay := minBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 10 * time.Second},
+		{attempts: 2, want: 20 * time.Second},
+		{attempts: 4, want: 80 * time.Second},
+		{attempts: 9, want: 2560 * time.Second},
+		{attempts: 10, want: time.Hour},
+		{attempts: 100, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("a", func(ctx context.Context, job *Job) error { return nil }, Options{})
+	registry.Register("b", func(ctx context.Context, job *Job) error { return nil }, Options{Timeout: time.Second, MaxAttempts: 2})
+
+	a, _ := registry.lookup("a")
+	if a.opts.Timeout != DefaultTimeout || a.opts.MaxAttempts != DefaultMaxAttempts {
+		t.Errorf("Register() options = %+v, want defaults", a.opts)
+	}
+	b, _ := registry.lookup("b")
+	if b.opts.Timeout != time.Second || b.opts.MaxAttempts != 2 {
+		t.Errorf("Register() options = %+v, want {1s 2}", b.opts)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Register() twice did not panic")
+		}
+	}()
+	registry.Register("a", func(ctx context.Context, job *Job) error { return nil }, Options{})
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name          string
+		handler       Handler
+		wantErr       bool
+		wantPermanent bool
+		wantDeadline  bool
+	}{
+		{
+			name:    "succeeds",
+			handler: func(ctx context.Context, job *Job) error { return nil },
+		},
+		{
+			name:    "fails",
+			handler: func(ctx context.Context, job *Job) error { return errors.New("boom") },
+			wantErr: true,
+		},
+		{
+			name: "fails permanently",
+			handler: func(ctx context.Context, job *Job) error {
+				return fmt.Errorf("processing: %w", Permanent(errors.New("bad input")))
+			},
+			wantErr:       true,
+			wantPermanent: true,
+		},
+		{
+			name:    "panics",
+			handler: func(ctx context.Context, job *Job) error { panic("boom") },
+			wantErr: true,
+		},
+		{
+			name: "times out",
+			handler: func(ctx context.Context, job *Job) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			wantErr:      true,
+			wantDeadline: true,
+		},
+		{
+			name: "invalid payload",
+			handler: func(ctx context.Context, job *Job) error {
+				var payload struct{ ID string }
+				return job.Decode(&payload)
+			},
+			wantErr:       true,
+			wantPermanent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := registration{handler: tt.handler, opts: Options{Timeout: 20 * time.Millisecond}}
+			job := &Job{Type: "test", Payload: []byte(`not json`)}
+
+			err := execute(context.Background(), reg, job)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if IsPermanent(err) != tt.wantPermanent {
+				t.Errorf("execute() permanent = %v, want %v", IsPermanent(err), tt.wantPermanent)
+			}
+			if errors.Is(err, context.DeadlineExceeded) != tt.wantDeadline {
+				t.Errorf("execute() error = %v, want deadline exceeded %v", err, tt.wantDeadline)
+			}
+		})
+	}
+}
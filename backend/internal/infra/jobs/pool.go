@@ -0,0 +1,248 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Defaults for a Pool configured with zero values
+const (
+	DefaultWorkers      = 4
+	DefaultPollInterval = time.Second
+)
+
+// leaseGrace is how long past its timeout a claimed job stays leased to its worker
+// before another worker may assume the first one died
+const leaseGrace = time.Minute
+
+// recordTimeout bounds writing a job's outcome, which happens even while shutting down
+const recordTimeout = 10 * time.Second
+
+// Config tunes a worker Pool. Zero fields take the defaults.
+type Config struct {
+	Workers      int
+	PollInterval time.Duration
+}
+
+// Pool runs queued jobs on a fixed number of workers
+type Pool struct {
+	db           *pgxpool.Pool
+	registry     *Registry
+	workers      int
+	pollInterval time.Duration
+	// lease is how long a claimed job is reserved for its worker
+	lease time.Duration
+
+	stop   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPool creates a worker pool for the job types in registry. Register every
+// handler before calling NewPool.
+func NewPool(db *pgxpool.Pool, registry *Registry, cfg Config) *Pool {
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultWorkers
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+
+	var longest time.Duration
+	for _, reg := range registry.handlers {
+		longest = max(longest, reg.opts.Timeout)
+	}
+
+	return &Pool{
+		db:           db,
+		registry:     registry,
+		workers:      cfg.Workers,
+		pollInterval: cfg.PollInterval,
+		lease:        longest + leaseGrace,
+		stop:         make(chan struct{}),
+	}
+}
+
+// Start launches the workers. They run until Shutdown is called.
+func (p *Pool) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.work(ctx)
+	}
+
+	slog.Info("Job workers started", "workers", p.workers, "types", p.registry.Types())
+}
+
+// Shutdown stops claiming jobs and waits for running ones to finish. If ctx ends
+// first, running jobs are cancelled and handed back to the queue untried.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	close(p.stop)
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		slog.Info("Job workers drained")
+		return nil
+	case <-ctx.Done():
+		slog.Warn("Job workers did not drain in time, cancelling running jobs")
+		p.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// work claims and runs jobs until the pool stops, waiting a poll interval whenever
+// the queue is empty
+func (p *Pool) work(ctx context.Context) {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		job, err := p.claim(ctx)
+		if err != nil {
+			slog.Error("Failed to claim job", "error", err)
+		}
+		if job == nil {
+			select {
+			case <-p.stop:
+				return
+			case <-time.After(p.pollInterval):
+			}
+			continue
+		}
+
+		p.run(ctx, job)
+	}
+}
+
+// claim leases the next due job, including any whose worker's lease lapsed. Workers
+// skip rows other workers hold locks on, so they never wait on each other.
+func (p *Pool) claim(ctx context.Context) (*Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1,
+			locked_until = NOW() + $2 * INTERVAL '1 second', updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE type = ANY($1)
+				AND ((status = 'pending' AND run_at <= NOW())
+					OR (status = 'running' AND locked_until < NOW()))
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, type, payload, attempts, last_error, run_at, created_at`
+
+	job := &Job{}
+	err := p.db.QueryRow(ctx, query, p.registry.Types(), int64(p.lease/time.Second)).Scan(
+		&job.ID,
+		&job.Type,
+		&job.Payload,
+		&job.Attempts,
+		&job.LastError,
+		&job.RunAt,
+		&job.CreatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+	return job, nil
+}
+
+// run runs a claimed job and records the outcome: success deletes it, failure
+// schedules a retry or, once attempts run out, leaves it dead
+func (p *Pool) run(ctx context.Context, job *Job) {
+	reg, _ := p.registry.lookup(job.Type)
+
+	var err error
+	if job.Attempts > reg.opts.MaxAttempts {
+		// Only a job whose worker died mid-attempt gets here
+		err = fmt.Errorf("abandoned after %d attempts", reg.opts.MaxAttempts)
+	} else {
+		start := time.Now()
+		err = execute(ctx, reg, job)
+		slog.Debug("Job ran", "jobID", job.ID, "type", job.Type, "attempt", job.Attempts, "duration", time.Since(start))
+	}
+
+	// Record the outcome even if the pool is shutting down
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+
+	var recordErr error
+	switch {
+	case err == nil:
+		recordErr = p.finish(recordCtx, job, `DELETE FROM jobs WHERE id = $1 AND attempts = $2`)
+	case ctx.Err() != nil:
+		slog.Info("Job interrupted by shutdown, requeueing", "jobID", job.ID, "type", job.Type)
+		recordErr = p.finish(recordCtx, job, `
+			UPDATE jobs
+			SET status = 'pending', attempts = attempts - 1, locked_until = NULL, run_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND attempts = $2`)
+	case IsPermanent(err) || job.Attempts >= reg.opts.MaxAttempts:
+		slog.Error("Job failed permanently", "error", err, "jobID", job.ID, "type", job.Type, "attempts", job.Attempts)
+		recordErr = p.finish(recordCtx, job, `
+			UPDATE jobs
+			SET status = 'dead', last_error = $3, locked_until = NULL, updated_at = NOW()
+			WHERE id = $1 AND attempts = $2`, err.Error())
+		if reg.opts.OnDead != nil {
+			reg.opts.OnDead(recordCtx, job, err)
+		}
+	default:
+		delay := Backoff(job.Attempts)
+		slog.Warn("Job failed, retrying", "error", err, "jobID", job.ID, "type", job.Type, "attempt", job.Attempts, "retryIn", delay)
+		recordErr = p.finish(recordCtx, job, `
+			UPDATE jobs
+			SET status = 'pending', last_error = $3, locked_until = NULL,
+				run_at = NOW() + $4 * INTERVAL '1 second', updated_at = NOW()
+			WHERE id = $1 AND attempts = $2`, err.Error(), int64(delay/time.Second))
+	}
+
+	if recordErr != nil {
+		// The lease will lapse and the job run again
+		slog.Error("Failed to record job outcome", "error", recordErr, "jobID", job.ID, "type", job.Type)
+	}
+}
+
+// finish records a job's outcome with query. Matching on the attempt count leaves the
+// row alone if the lease lapsed and another worker has claimed the job since.
+func (p *Pool) finish(ctx context.Context, job *Job, query string, args ...any) error {
+	_, err := p.db.Exec(ctx, query, append([]any{job.ID, job.Attempts}, args...)...)
+	return err
+}
+
+// execute runs a job's handler under its timeout, turning a panic into an error
+func execute(ctx context.Context, reg registration, job *Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, reg.opts.Timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return reg.handler(ctx, job)
+}
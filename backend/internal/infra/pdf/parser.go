@@ -0,0 +1,457 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// maxNesting bounds how deeply arrays and dictionaries may nest
+const maxNesting = 64
+
+const (
+	// initialWindowSize is how much of the file is read to start parsing an object
+	initialWindowSize = 4 << 10
+	// maxWindowSize bounds how much of the file a single object may span
+	maxWindowSize = 16 << 20
+)
+
+// name is a PDF name object such as /Type
+type name string
+
+// ref is an indirect object reference such as "12 0 R"
+type ref struct {
+	num int
+	gen int
+}
+
+// dict is a PDF dictionary keyed by name
+type dict map[string]any
+
+// name returns the value of key if it is a name, or ""
+func (d dict) name(key string) name {
+	n, _ := d[key].(name)
+	return n
+}
+
+// source is the file a document is parsed from
+type source struct {
+	r    io.ReaderAt
+	size int64
+	// err is the first read error; the parser sees a failed read as the end of the file
+	err error
+}
+
+// readAt reads into buf from off and returns how many bytes were read
+func (s *source) readAt(buf []byte, off int64) int {
+	n, err := s.r.ReadAt(buf, off)
+	if err != nil && err != io.EOF && s.err == nil {
+		s.err = err
+	}
+	return n
+}
+
+// parser is a minimal PDF object parser, just enough to walk the trailer, the
+// page tree and the operands of content stream operators. It parses either an
+// in-memory buffer or, when src is set, a window onto the file from offset base
+// that is extended as parsing runs past its end.
+type parser struct {
+	data  []byte
+	pos   int
+	depth int
+	src   *source
+	base  int64
+}
+
+// more reports whether n more bytes are available from the current position,
+// reading further into the file if the window is short
+func (p *parser) more(n int) bool {
+	if p.pos+n <= len(p.data) {
+		return true
+	}
+	p.grow(p.pos + n)
+	return p.pos+n <= len(p.data)
+}
+
+// grow extends the window to at least need bytes, or as far as the file and
+// maxWindowSize allow. It at least doubles the window to keep reads few.
+func (p *parser) grow(need int) {
+	if p.src == nil {
+		return
+	}
+	limit := min(p.src.size-p.base, maxWindowSize)
+	target := min(int64(max(need, 2*len(p.data), initialWindowSize)), limit)
+	if target <= int64(len(p.data)) {
+		return
+	}
+
+	buf := make([]byte, target)
+	copy(buf, p.data)
+	n := p.src.readAt(buf[len(p.data):], p.base+int64(len(p.data)))
+	p.data = buf[:len(p.data)+n]
+}
+
+// size is how many bytes the parser can reach, whether or not they are read yet
+func (p *parser) size() int {
+	if p.src == nil {
+		return len(p.data)
+	}
+	return int(min(p.src.size-p.base, maxWindowSize))
+}
+
+// indexOf returns the position of sep relative to the current position, reading
+// further into the file until it is found, or -1
+func (p *parser) indexOf(sep []byte) int {
+	from := p.pos
+	for {
+		if i := bytes.Index(p.data[from:], sep); i >= 0 {
+			return from + i - p.pos
+		}
+		// Keep a partial match at the end of the window in the next search
+		from = max(p.pos, len(p.data)-len(sep)+1)
+		if !p.more(len(p.data) - p.pos + 1) {
+			return -1
+		}
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// skipSpace skips whitespace and comments
+func (p *parser) skipSpace() {
+	for p.more(1) {
+		c := p.data[p.pos]
+		switch {
+		case isSpace(c):
+			p.pos++
+		case c == '%':
+			for p.more(1) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// hasKeyword reports whether kw starts at the current position as a whole token
+func (p *parser) hasKeyword(kw string) bool {
+	if !p.more(len(kw)) || !bytes.HasPrefix(p.data[p.pos:], []byte(kw)) {
+		return false
+	}
+	end := p.pos + len(kw)
+	return !p.more(len(kw)+1) || isSpace(p.data[end]) || isDelimiter(p.data[end])
+}
+
+// readToken reads a run of regular (non-space, non-delimiter) characters
+func (p *parser) readToken() string {
+	start := p.pos
+	for p.more(1) && !isSpace(p.data[p.pos]) && !isDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+// skipXrefTable skips the subsections of a classic cross-reference table,
+// checking each entry has the "offset generation n|f" shape
+func (p *parser) skipXrefTable() error {
+	for {
+		p.skipSpace()
+		if !p.more(1) || !isDigit(p.data[p.pos]) {
+			return nil
+		}
+
+		if _, err := strconv.Atoi(p.readToken()); err != nil {
+			return newError(CodeMalformed, "PDF cross-reference table is invalid")
+		}
+		p.skipSpace()
+		count, err := strconv.Atoi(p.readToken())
+		if err != nil || count < 0 || count > p.size()/20 {
+			return newError(CodeMalformed, "PDF cross-reference table is invalid")
+		}
+
+		for i := 0; i < count; i++ {
+			p.skipSpace()
+			offset := p.readToken()
+			p.skipSpace()
+			gen := p.readToken()
+			p.skipSpace()
+			kind := p.readToken()
+			if len(offset) != 10 || len(gen) != 5 || (kind != "n" && kind != "f") {
+				return newError(CodeMalformed, "PDF cross-reference entry %d is invalid", i)
+			}
+		}
+	}
+}
+
+// parseIndirectObject parses "N G obj <object>" and returns the object
+func (p *parser) parseIndirectObject() (any, error) {
+	p.skipSpace()
+	if _, err := strconv.Atoi(p.readToken()); err != nil {
+		return nil, errors.New("expected object number")
+	}
+	p.skipSpace()
+	if _, err := strconv.Atoi(p.readToken()); err != nil {
+		return nil, errors.New("expected generation number")
+	}
+	p.skipSpace()
+	if !p.hasKeyword("obj") {
+		return nil, errors.New("expected obj keyword")
+	}
+	p.pos += len("obj")
+	return p.parseObject()
+}
+
+// readStream returns the raw bytes of the stream following a stream dictionary
+func (p *parser) readStream(header dict) ([]byte, error) {
+	p.skipSpace()
+	if !p.hasKeyword("stream") {
+		return nil, errors.New("expected stream keyword")
+	}
+	p.pos += len("stream")
+
+	// The keyword is followed by CRLF or LF before the data
+	if p.more(1) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.more(1) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+
+	if length, ok := header["Length"].(int64); ok && length >= 0 && length <= maxWindowSize && p.more(int(length)) {
+		return p.data[p.pos : p.pos+int(length)], nil
+	}
+
+	// Indirect or bogus length: fall back to scanning for the end marker
+	end := p.indexOf([]byte("endstream"))
+	if end < 0 {
+		return nil, errors.New("unterminated stream")
+	}
+	return bytes.TrimRight(p.data[p.pos:p.pos+end], "\r\n"), nil
+}
+
+// parseObject parses a single direct object or indirect reference
+func (p *parser) parseObject() (any, error) {
+	p.skipSpace()
+	if !p.more(1) {
+		return nil, errors.New("unexpected end of data")
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '<' && p.more(2) && p.data[p.pos+1] == '<':
+		return p.parseDict()
+	case c == '<':
+		return p.parseHexString()
+	case c == '[':
+		return p.parseArray()
+	case c == '(':
+		return p.parseLiteralString()
+	case c == '/':
+		p.pos++
+		return name(p.readToken()), nil
+	case isDigit(c) || c == '+' || c == '-' || c == '.':
+		return p.parseNumberOrRef()
+	default:
+		switch token := p.readToken(); token {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("unexpected token %q at offset %d", token, p.pos)
+		}
+	}
+}
+
+func (p *parser) nest() error {
+	p.depth++
+	if p.depth > maxNesting {
+		return errors.New("objects nested too deeply")
+	}
+	return nil
+}
+
+func (p *parser) parseDict() (any, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	p.pos += 2
+	d := dict{}
+	for {
+		p.skipSpace()
+		if p.more(2) && p.data[p.pos] == '>' && p.data[p.pos+1] == '>' {
+			p.pos += 2
+			return d, nil
+		}
+
+		key, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		keyName, ok := key.(name)
+		if !ok {
+			return nil, fmt.Errorf("dictionary key is not a name at offset %d", p.pos)
+		}
+
+		value, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		d[string(keyName)] = value
+	}
+}
+
+func (p *parser) parseArray() (any, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	p.pos++
+	var items []any
+	for {
+		p.skipSpace()
+		if p.more(1) && p.data[p.pos] == ']' {
+			p.pos++
+			return items, nil
+		}
+
+		item, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+// parseHexString parses <...> and returns the decoded bytes
+func (p *parser) parseHexString() (any, error) {
+	end := p.indexOf([]byte(">"))
+	if end < 0 {
+		return nil, errors.New("unterminated hex string")
+	}
+	raw := p.data[p.pos+1 : p.pos+end]
+	p.pos += end + 1
+
+	digits := make([]byte, 0, len(raw)+1)
+	for _, c := range raw {
+		if !isSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	// An odd final digit is treated as if followed by 0
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	decoded := make([]byte, len(digits)/2)
+	if _, err := hex.Decode(decoded, digits); err != nil {
+		return nil, fmt.Errorf("invalid hex string: %w", err)
+	}
+	return string(decoded), nil
+}
+
+// parseLiteralString parses (...) and returns the unescaped bytes
+func (p *parser) parseLiteralString() (any, error) {
+	p.pos++
+	var out []byte
+	depth := 1
+	for p.more(1) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return string(out), nil
+			}
+		case '\\':
+			if !p.more(1) {
+				continue
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// Line continuation; swallow an optional LF too
+				if p.more(1) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					// Up to three octal digits
+					v := int(e - '0')
+					for i := 0; i < 2 && p.more(1) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return nil, errors.New("unterminated string")
+}
+
+// parseNumberOrRef parses a number, treating "N G R" as an indirect reference
+func (p *parser) parseNumberOrRef() (any, error) {
+	token := p.readToken()
+	num, err := strconv.ParseInt(token, 10, 64)
+	if err != nil {
+		f, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", token)
+		}
+		return f, nil
+	}
+
+	// Look ahead for "G R" without consuming anything if it isn't there
+	save := p.pos
+	p.skipSpace()
+	if p.more(1) && isDigit(p.data[p.pos]) {
+		gen, err := strconv.Atoi(p.readToken())
+		p.skipSpace()
+		if err == nil && p.hasKeyword("R") {
+			p.pos++
+			return ref{num: int(num), gen: gen}, nil
+		}
+	}
+	p.pos = save
+
+	return num, nil
+}
@@ -0,0 +1,402 @@
+// Package pdf inspects uploaded files to confirm they are well-formed PDF documents
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// Error codes reported for rejected files
+const (
+	CodeNotPDF    = "not_pdf"
+	CodeMalformed = "malformed_pdf"
+	CodeEncrypted = "encrypted_pdf"
+	CodePolyglot  = "polyglot_file"
+)
+
+// HeaderSearchWindow is how far into a file PDF readers look for the header;
+// a header found later than offset 0 but within it means something is prepended
+const HeaderSearchWindow = 1024
+
+// trailerSearchWindow is how far from the end we look for startxref
+const trailerSearchWindow = 2048
+
+// Error describes why a file was rejected
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(code, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Info describes a validated PDF document
+type Info struct {
+	Version   string
+	PageCount int
+}
+
+var (
+	headerPattern    = regexp.MustCompile(`^%PDF-([12]\.\d)`)
+	objHeaderPattern = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	startxrefPattern = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF`)
+)
+
+// Inspect reads size bytes from r and validates the PDF structure: the header,
+// the cross-reference section and trailer, and the page tree. Encrypted PDFs
+// and files with another format prepended or appended are rejected.
+func Inspect(r io.ReaderAt, size int64) (*Info, error) {
+	doc, trailer, version, err := open(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	pageCount, err := doc.pageCount(trailer)
+	if err := doc.check(err); err != nil {
+		return nil, err
+	}
+
+	return &Info{
+		Version:   version,
+		PageCount: pageCount,
+	}, nil
+}
+
+// CheckHeader checks that start, the first HeaderSearchWindow bytes of a file or
+// the whole file if it is shorter, begins with a PDF header. It lets a file being
+// streamed be rejected before the rest of it arrives.
+func CheckHeader(start []byte) error {
+	if headerPattern.Match(start) {
+		return nil
+	}
+	if bytes.Contains(start[:min(len(start), HeaderSearchWindow)], []byte("%PDF-")) {
+		return newError(CodePolyglot, "file has data before the PDF header")
+	}
+	return newError(CodeNotPDF, "file is not a PDF document")
+}
+
+// open checks the header and trailer and rejects encryption, reading only the
+// ends of the file. It returns the document, its trailer dictionary and the
+// header version.
+func open(r io.ReaderAt, size int64) (*document, dict, string, error) {
+	doc := &document{src: &source{r: r, size: size}}
+
+	start := make([]byte, min(size, HeaderSearchWindow))
+	start = start[:doc.src.readAt(start, 0)]
+	if err := doc.check(CheckHeader(start)); err != nil {
+		return nil, nil, "", err
+	}
+	match := headerPattern.FindSubmatch(start)
+
+	xrefOffset, err := doc.findStartXref()
+	if err := doc.check(err); err != nil {
+		return nil, nil, "", err
+	}
+
+	trailer, err := doc.readTrailer(xrefOffset)
+	if err := doc.check(err); err != nil {
+		return nil, nil, "", err
+	}
+
+	if _, ok := trailer["Encrypt"]; ok {
+		return nil, nil, "", newError(CodeEncrypted, "encrypted PDF files are not allowed")
+	}
+
+	return doc, trailer, string(match[1]), nil
+}
+
+// findStartXref locates the final startxref offset and checks nothing but
+// whitespace follows the last %%EOF marker
+func (d *document) findStartXref() (int, error) {
+	from := max(0, d.src.size-trailerSearchWindow)
+	tail := make([]byte, d.src.size-from)
+	tail = tail[:d.src.readAt(tail, from)]
+
+	matches := startxrefPattern.FindAllSubmatchIndex(tail, -1)
+	if matches == nil {
+		return 0, newError(CodeMalformed, "PDF is missing its startxref trailer")
+	}
+	last := matches[len(matches)-1]
+
+	if rest := bytes.Trim(tail[last[1]:], " \t\r\n\x00"); len(rest) > 0 {
+		return 0, newError(CodePolyglot, "file has data after the end of the PDF")
+	}
+
+	offset, err := strconv.ParseInt(string(tail[last[2]:last[3]]), 10, 64)
+	if err != nil || offset <= 0 || offset >= d.src.size {
+		return 0, newError(CodeMalformed, "PDF startxref offset is out of range")
+	}
+
+	return int(offset), nil
+}
+
+// document provides object lookup over a PDF file, reading objects from it as
+// they are needed rather than holding the whole file
+type document struct {
+	src           *source
+	offsets       map[int]int
+	objStms       []int
+	objectStreams map[int]*objectStream
+}
+
+// parserAt returns a parser for the file starting at offset
+func (d *document) parserAt(offset int) *parser {
+	return &parser{src: d.src, base: int64(offset)}
+}
+
+// check returns the error from reading the file in place of err if there was
+// one, since the parser sees a failed read as a truncated, malformed file
+func (d *document) check(err error) error {
+	if d.src.err != nil {
+		return fmt.Errorf("failed to read file: %w", d.src.err)
+	}
+	return err
+}
+
+// readTrailer parses the cross-reference section at offset and returns the
+// trailer dictionary, supporting both classic tables and xref streams
+func (d *document) readTrailer(offset int) (dict, error) {
+	p := d.parserAt(offset)
+	p.skipSpace()
+
+	if p.hasKeyword("xref") {
+		p.pos += len("xref")
+		if err := p.skipXrefTable(); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.hasKeyword("trailer") {
+			return nil, newError(CodeMalformed, "PDF cross-reference table has no trailer")
+		}
+		p.pos += len("trailer")
+		value, err := p.parseObject()
+		if err != nil {
+			return nil, newError(CodeMalformed, "PDF trailer is invalid: %v", err)
+		}
+		trailer, ok := value.(dict)
+		if !ok {
+			return nil, newError(CodeMalformed, "PDF trailer is not a dictionary")
+		}
+		return trailer, d.checkRoot(trailer)
+	}
+
+	// PDF 1.5+ may use a cross-reference stream, whose dictionary is the trailer
+	value, err := p.parseIndirectObject()
+	if err != nil {
+		return nil, newError(CodeMalformed, "PDF cross-reference section is invalid: %v", err)
+	}
+	trailer, ok := value.(dict)
+	if !ok || trailer.name("Type") != "XRef" {
+		return nil, newError(CodeMalformed, "PDF cross-reference section is invalid")
+	}
+	return trailer, d.checkRoot(trailer)
+}
+
+func (d *document) checkRoot(trailer dict) error {
+	if _, ok := trailer["Root"].(ref); !ok {
+		return newError(CodeMalformed, "PDF trailer has no document catalog")
+	}
+	return nil
+}
+
+// pageCount follows the catalog to the page tree root and reads its /Count
+func (d *document) pageCount(trailer dict) (int, error) {
+	catalog, ok := d.resolve(trailer["Root"]).(dict)
+	if !ok || catalog.name("Type") != "Catalog" {
+		return 0, newError(CodeMalformed, "PDF document catalog is missing")
+	}
+
+	pages, ok := d.resolve(catalog["Pages"]).(dict)
+	if !ok {
+		return 0, newError(CodeMalformed, "PDF page tree is missing")
+	}
+
+	count, ok := d.resolve(pages["Count"]).(int64)
+	if !ok || count < 1 {
+		return 0, newError(CodeMalformed, "PDF has no pages")
+	}
+
+	return int(count), nil
+}
+
+// resolve follows an indirect reference; other values are returned unchanged
+func (d *document) resolve(value any) any {
+	r, ok := value.(ref)
+	if !ok {
+		return value
+	}
+
+	d.index()
+
+	if offset, ok := d.offsets[r.num]; ok {
+		p := d.parserAt(offset)
+		obj, err := p.parseIndirectObject()
+		if err == nil {
+			return obj
+		}
+	}
+
+	// Objects may be compressed inside object streams
+	for _, offset := range d.objStms {
+		if obj, ok := d.fromObjectStream(offset, r.num); ok {
+			return obj
+		}
+	}
+
+	return nil
+}
+
+// indexChunkSize is how much of the file index scans at a time
+const indexChunkSize = 1 << 20
+
+// indexOverlap is how far chunks are extended on either side so an object
+// header that straddles a chunk boundary is still found whole
+const indexOverlap = 64
+
+// index records where each "N G obj" header starts. Later definitions win,
+// matching how incremental updates override earlier objects.
+func (d *document) index() {
+	if d.offsets != nil {
+		return
+	}
+
+	d.offsets = make(map[int]int)
+	buf := make([]byte, indexOverlap+indexChunkSize+indexOverlap)
+	for start := int64(0); start < d.src.size; start += indexChunkSize {
+		from := max(0, start-indexOverlap)
+		chunk := buf[:min(int64(len(buf)), d.src.size-from)]
+		chunk = chunk[:d.src.readAt(chunk, from)]
+
+		for _, loc := range objHeaderPattern.FindAllSubmatchIndex(chunk, -1) {
+			// Headers in the overlap belong to the neighbouring chunk
+			offset := from + int64(loc[0])
+			if offset < start || offset >= start+indexChunkSize {
+				continue
+			}
+			num, err := strconv.Atoi(string(chunk[loc[2]:loc[3]]))
+			if err != nil {
+				continue
+			}
+			d.offsets[num] = int(offset)
+
+			// Start from the bytes already read; the parser reads on if the object runs past them
+			p := &parser{data: chunk[loc[1]:], src: d.src, base: from + int64(loc[1])}
+			if obj, err := p.parseObject(); err == nil {
+				if stm, ok := obj.(dict); ok && stm.name("Type") == "ObjStm" {
+					d.objStms = append(d.objStms, int(offset))
+				}
+			}
+		}
+	}
+}
+
+// objectStream is a decoded object stream and the offsets of the objects in it
+type objectStream struct {
+	content []byte
+	offsets map[int]int
+}
+
+// fromObjectStream looks up object num inside the object stream at offset
+func (d *document) fromObjectStream(offset, num int) (any, bool) {
+	stm, ok := d.objectStreams[offset]
+	if !ok {
+		stm = d.loadObjectStream(offset)
+		if d.objectStreams == nil {
+			d.objectStreams = make(map[int]*objectStream)
+		}
+		// Cache failures too so a broken stream is only decoded once
+		d.objectStreams[offset] = stm
+	}
+	if stm == nil {
+		return nil, false
+	}
+
+	objOffset, ok := stm.offsets[num]
+	if !ok {
+		return nil, false
+	}
+
+	vp := &parser{data: stm.content, pos: objOffset}
+	value, err := vp.parseObject()
+	return value, err == nil
+}
+
+// loadObjectStream decodes the object stream at offset, or returns nil
+func (d *document) loadObjectStream(offset int) *objectStream {
+	p := d.parserAt(offset)
+	obj, err := p.parseIndirectObject()
+	if err != nil {
+		return nil
+	}
+	header, _ := obj.(dict)
+
+	content, err := p.readDecodedStream(header)
+	if err != nil {
+		return nil
+	}
+
+	n, _ := header["N"].(int64)
+	first, _ := header["First"].(int64)
+	if n <= 0 || first <= 0 || int(first) > len(content) {
+		return nil
+	}
+
+	stm := &objectStream{content: content, offsets: make(map[int]int, n)}
+	hp := &parser{data: content[:first]}
+	for i := int64(0); i < n; i++ {
+		objNum, err1 := hp.parseObject()
+		objOffset, err2 := hp.parseObject()
+		num, ok1 := objNum.(int64)
+		off, ok2 := objOffset.(int64)
+		if err1 != nil || err2 != nil || !ok1 || !ok2 || first+off >= int64(len(content)) {
+			return nil
+		}
+		stm.offsets[int(num)] = int(first + off)
+	}
+
+	return stm
+}
+
+// readDecodedStream reads the stream after header and applies its filter. Only
+// unfiltered and FlateDecode streams are supported.
+func (p *parser) readDecodedStream(header dict) ([]byte, error) {
+	raw, err := p.readStream(header)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := header["Filter"]
+	if filters, ok := filter.([]any); ok && len(filters) == 1 {
+		filter = filters[0]
+	}
+
+	switch filter {
+	case nil:
+		return raw, nil
+	case name("FlateDecode"):
+		return inflate(raw)
+	default:
+		return nil, fmt.Errorf("unsupported stream filter %v", filter)
+	}
+}
+
+// maxInflatedSize bounds decompressed object streams to guard against zip bombs
+const maxInflatedSize = 16 << 20
+
+func inflate(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(io.LimitReader(zr, maxInflatedSize))
+}
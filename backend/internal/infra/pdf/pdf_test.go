@@ -0,0 +1,275 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF assembles a PDF with a correct classic cross-reference table.
+// objects are the bodies of objects 1..n.
+func buildPDF(version string, objects []string, trailerExtra string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%%PDF-%s\n%%\xe2\xe3\xcf\xd3\n", version)
+
+	offsets := make([]int, len(objects))
+	for i, body := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R %s>>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailerExtra, xref)
+	return buf.Bytes()
+}
+
+func simplePDF(pages int) []byte {
+	kids := make([]string, pages)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+	}
+	for i := range kids {
+		kids[i] = fmt.Sprintf("%d 0 R", i+3)
+		objects = append(objects, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>")
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), pages)
+	return buildPDF("1.7", objects, "")
+}
+
+// xrefStreamPDF builds a PDF 1.5 file whose catalog and page tree live inside a
+// compressed object stream, indexed by an xref stream
+func xrefStreamPDF() []byte {
+	objects := "<< /Type /Catalog /Pages 2 0 R >> << /Type /Pages /Kids [3 0 R] /Count 1 >> << /Type /Page /Parent 2 0 R >>"
+	// Offsets in the header are relative to /First
+	header := fmt.Sprintf("1 0 2 %d 3 %d ", strings.Index(objects, "<< /Type /Pages"), strings.Index(objects, "<< /Type /Page /Parent"))
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte(header + objects))
+	zw.Close()
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n")
+	fmt.Fprintf(&buf, "4 0 obj\n<< /Type /ObjStm /N 3 /First %d /Filter /FlateDecode /Length %d >>\nstream\n",
+		len(header), compressed.Len())
+	buf.Write(compressed.Bytes())
+	buf.WriteString("\nendstream\nendobj\n")
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "5 0 obj\n<< /Type /XRef /Size 6 /Root 1 0 R /W [1 2 1] /Length 0 >>\nstream\n\nendstream\nendobj\n")
+	fmt.Fprintf(&buf, "startxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		wantCode  string
+		wantPages int
+		wantVer   string
+	}{
+		{
+			name:      "valid single page",
+			data:      simplePDF(1),
+			wantPages: 1,
+			wantVer:   "1.7",
+		},
+		{
+			name:      "valid multi page",
+			data:      simplePDF(12),
+			wantPages: 12,
+			wantVer:   "1.7",
+		},
+		{
+			name:      "xref stream with object stream",
+			data:      xrefStreamPDF(),
+			wantPages: 1,
+			wantVer:   "1.5",
+		},
+		{
+			name:     "renamed docx",
+			data:     []byte("PK\x03\x04\x14\x00\x06\x00word/document.xml"),
+			wantCode: CodeNotPDF,
+		},
+		{
+			name:     "empty",
+			data:     []byte{},
+			wantCode: CodeNotPDF,
+		},
+		{
+			name:     "prepended data",
+			data:     append([]byte("GIF89a\x01\x00\x01\x00"), simplePDF(1)...),
+			wantCode: CodePolyglot,
+		},
+		{
+			name:     "appended zip",
+			data:     append(simplePDF(1), []byte("PK\x03\x04 hidden archive")...),
+			wantCode: CodePolyglot,
+		},
+		{
+			name:     "truncated",
+			data:     simplePDF(1)[:200],
+			wantCode: CodeMalformed,
+		},
+		{
+			name:     "header only",
+			data:     []byte("%PDF-1.4\nnot really a pdf\n"),
+			wantCode: CodeMalformed,
+		},
+		{
+			name:     "bad xref offset",
+			data:     []byte("%PDF-1.4\n1 0 obj\n<< >>\nendobj\nstartxref\n9\n%%EOF\n"),
+			wantCode: CodeMalformed,
+		},
+		{
+			name: "encrypted",
+			data: buildPDF("1.6", []string{
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [] /Count 1 >>",
+				"<< /Filter /Standard /V 2 /R 3 /O (owner) /U (user) /P -4 >>",
+			}, "/Encrypt 3 0 R /ID [<abc> <abc>] "),
+			wantCode: CodeEncrypted,
+		},
+		{
+			name: "no pages",
+			data: buildPDF("1.4", []string{
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [] /Count 0 >>",
+			}, ""),
+			wantCode: CodeMalformed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Inspect(bytes.NewReader(tt.data), int64(len(tt.data)))
+
+			if tt.wantCode != "" {
+				var pdfErr *Error
+				if !errors.As(err, &pdfErr) {
+					t.Fatalf("Inspect() error = %v, want code %s", err, tt.wantCode)
+				}
+				if pdfErr.Code != tt.wantCode {
+					t.Fatalf("Inspect() code = %s (%v), want %s", pdfErr.Code, err, tt.wantCode)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Inspect() error = %v", err)
+			}
+			if info.PageCount != tt.wantPages {
+				t.Errorf("PageCount = %d, want %d", info.PageCount, tt.wantPages)
+			}
+			if info.Version != tt.wantVer {
+				t.Errorf("Version = %s, want %s", info.Version, tt.wantVer)
+			}
+		})
+	}
+}
+
+func TestInspect_DeepNesting(t *testing.T) {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R /Junk " + strings.Repeat("[", 10000) + " >>",
+		"<< /Type /Pages /Kids [] /Count 1 >>",
+	}
+	data := buildPDF("1.4", objects, "")
+
+	if _, err := Inspect(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Fatal("Inspect() should reject deeply nested objects")
+	}
+}
+
+func TestCheckHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    []byte
+		wantCode string
+	}{
+		{name: "pdf", start: simplePDF(1)},
+		{name: "short pdf", start: []byte("%PDF-1.4\n")},
+		{name: "truncated header", start: []byte("%PDF"), wantCode: CodeNotPDF},
+		{name: "not a pdf", start: []byte("PK\x03\x04\x14\x00"), wantCode: CodeNotPDF},
+		{name: "prepended data", start: append([]byte("GIF89a\x01\x00"), []byte("%PDF-1.4\n")...), wantCode: CodePolyglot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckHeader(tt.start)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("CheckHeader() error = %v", err)
+				}
+				return
+			}
+			var pdfErr *Error
+			if !errors.As(err, &pdfErr) || pdfErr.Code != tt.wantCode {
+				t.Errorf("CheckHeader() error = %v, want code %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+// recordingReader tracks the largest single read made from it and can fail
+// reads past an offset
+type recordingReader struct {
+	data    []byte
+	largest int
+	failAt  int64
+}
+
+var errDisk = errors.New("disk error")
+
+func (r *recordingReader) ReadAt(p []byte, off int64) (int, error) {
+	r.largest = max(r.largest, len(p))
+	if r.failAt > 0 && off+int64(len(p)) > r.failAt {
+		return 0, errDisk
+	}
+	return bytes.NewReader(r.data).ReadAt(p, off)
+}
+
+// largePDF puts a padding stream of size bytes between the catalog and the page tree
+func largePDF(size int) []byte {
+	return buildPDF("1.7", []string{
+		"<< /Type /Catalog /Pages 3 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", size, strings.Repeat("x", size)),
+		"<< /Type /Pages /Kids [4 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 3 0 R >>",
+	}, "")
+}
+
+func TestInspect_ReadsInWindows(t *testing.T) {
+	data := largePDF(3 * indexChunkSize)
+	r := &recordingReader{data: data}
+
+	info, err := Inspect(r, int64(len(data)))
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if info.PageCount != 1 {
+		t.Errorf("PageCount = %d, want 1", info.PageCount)
+	}
+	if limit := indexChunkSize + 2*indexOverlap; r.largest > limit {
+		t.Errorf("largest read = %d bytes, want at most %d", r.largest, limit)
+	}
+}
+
+func TestInspect_ReadError(t *testing.T) {
+	data := largePDF(2 * indexChunkSize)
+	r := &recordingReader{data: data, failAt: int64(indexChunkSize)}
+
+	_, err := Inspect(r, int64(len(data)))
+	var pdfErr *Error
+	if !errors.Is(err, errDisk) || errors.As(err, &pdfErr) {
+		t.Errorf("Inspect() error = %v, want the read error", err)
+	}
+}
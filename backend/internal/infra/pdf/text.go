@@ -0,0 +1,585 @@
+package pdf
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// MaxTextSize caps the extracted text so it stays well under Postgres' 1MB
+// tsvector limit
+const MaxTextSize = 256 << 10
+
+// maxFormDepth bounds recursion into nested form XObjects
+const maxFormDepth = 5
+
+// maxPages bounds how many pages are walked for text
+const maxPages = 5000
+
+// ExtractText returns the text of a PDF's pages in reading order, as far as it
+// can be recovered. Fonts without a usable encoding contribute nothing, so the
+// result may be empty for scanned documents.
+func ExtractText(r io.ReaderAt, size int64) (string, error) {
+	doc, trailer, _, err := open(r, size)
+	if err != nil {
+		return "", err
+	}
+
+	catalog, ok := doc.resolve(trailer["Root"]).(dict)
+	if !ok {
+		return "", doc.check(newError(CodeMalformed, "PDF document catalog is missing"))
+	}
+
+	ex := &extractor{doc: doc, fonts: make(map[ref]*fontDecoder)}
+	ex.walkPages(catalog["Pages"])
+	if err := doc.check(nil); err != nil {
+		return "", err
+	}
+
+	return ex.text(), nil
+}
+
+// extractor accumulates text while walking a document's pages
+type extractor struct {
+	doc   *document
+	fonts map[ref]*fontDecoder
+	out   strings.Builder
+	pages int
+}
+
+func (ex *extractor) full() bool {
+	return ex.out.Len() >= MaxTextSize
+}
+
+// walkPages visits the page tree depth-first, passing inherited resources down
+func (ex *extractor) walkPages(root any) {
+	type frame struct {
+		node      any
+		resources any
+	}
+
+	visited := make(map[ref]bool)
+	stack := []frame{{node: root}}
+
+	for len(stack) > 0 && !ex.full() && ex.pages < maxPages {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if r, ok := top.node.(ref); ok {
+			if visited[r] {
+				continue
+			}
+			visited[r] = true
+		}
+
+		node, ok := ex.doc.resolve(top.node).(dict)
+		if !ok {
+			continue
+		}
+
+		resources := top.resources
+		if own, ok := node["Resources"]; ok {
+			resources = own
+		}
+
+		switch node.name("Type") {
+		case "Pages":
+			kids, _ := ex.doc.resolve(node["Kids"]).([]any)
+			// Push in reverse so pages come off the stack in order
+			for i := len(kids) - 1; i >= 0; i-- {
+				stack = append(stack, frame{node: kids[i], resources: resources})
+			}
+		case "Page":
+			ex.pages++
+			ex.page(node, resources)
+		}
+	}
+}
+
+// page extracts the text of one page's content streams
+func (ex *extractor) page(page dict, resources any) {
+	var content []byte
+
+	contents := ex.doc.resolve(page["Contents"])
+	parts, isArray := contents.([]any)
+	if !isArray {
+		parts = []any{page["Contents"]}
+	}
+
+	for _, part := range parts {
+		data, err := ex.doc.stream(part)
+		if err != nil {
+			continue
+		}
+		content = append(content, data...)
+		content = append(content, '\n')
+	}
+
+	res, _ := ex.doc.resolve(resources).(dict)
+	ex.run(content, res, 0)
+	ex.out.WriteByte('\n')
+}
+
+// run interprets the text operators of a content stream
+func (ex *extractor) run(content []byte, resources dict, depth int) {
+	fonts, _ := ex.doc.resolve(resources["Font"]).(dict)
+	xobjects, _ := ex.doc.resolve(resources["XObject"]).(dict)
+
+	var font *fontDecoder
+	var operands []any
+	p := &parser{data: content}
+
+	for !ex.full() {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return
+		}
+
+		c := p.data[p.pos]
+		if c == '/' || c == '(' || c == '<' || c == '[' || isDigit(c) || c == '-' || c == '+' || c == '.' {
+			value, err := p.parseObject()
+			if err != nil {
+				// Skip the offending byte and resynchronize on the next token
+				p.pos++
+				operands = operands[:0]
+				continue
+			}
+			operands = append(operands, value)
+			continue
+		}
+
+		op := p.readToken()
+		if op == "" {
+			p.pos++
+			continue
+		}
+
+		switch op {
+		case "Tf":
+			if len(operands) >= 1 {
+				if fontName, ok := operands[0].(name); ok {
+					font = ex.font(fonts[string(fontName)])
+				}
+			}
+		case "Tj":
+			ex.show(font, lastString(operands))
+		case "'", "\"":
+			ex.newline()
+			ex.show(font, lastString(operands))
+		case "TJ":
+			if len(operands) > 0 {
+				items, _ := operands[len(operands)-1].([]any)
+				for _, item := range items {
+					switch v := item.(type) {
+					case string:
+						ex.show(font, v)
+					case int64:
+						// Large negative adjustments are how many generators encode spaces
+						if v < -250 {
+							ex.space()
+						}
+					case float64:
+						if v < -250 {
+							ex.space()
+						}
+					}
+				}
+			}
+		case "Td", "TD":
+			if len(operands) == 2 && !isZero(operands[1]) {
+				ex.newline()
+			} else {
+				ex.space()
+			}
+		case "T*", "ET":
+			ex.newline()
+		case "Tm":
+			ex.space()
+		case "BI":
+			skipInlineImage(p)
+		case "Do":
+			if depth < maxFormDepth && len(operands) == 1 {
+				if xName, ok := operands[0].(name); ok {
+					ex.form(xobjects[string(xName)], resources, depth)
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// form runs the content of a form XObject
+func (ex *extractor) form(value any, parentResources dict, depth int) {
+	header, data, err := ex.doc.streamWithHeader(value)
+	if err != nil || header.name("Subtype") != "Form" {
+		return
+	}
+
+	resources, ok := ex.doc.resolve(header["Resources"]).(dict)
+	if !ok {
+		resources = parentResources
+	}
+	ex.run(data, resources, depth+1)
+}
+
+func (ex *extractor) show(font *fontDecoder, s string) {
+	if s == "" {
+		return
+	}
+	if font == nil {
+		font = &fontDecoder{}
+	}
+	ex.out.WriteString(font.decode(s))
+}
+
+func (ex *extractor) space() {
+	ex.out.WriteByte(' ')
+}
+
+func (ex *extractor) newline() {
+	ex.out.WriteByte('\n')
+}
+
+// text returns the accumulated text with control characters removed and
+// whitespace collapsed
+func (ex *extractor) text() string {
+	raw := strings.ToValidUTF8(ex.out.String(), "")
+
+	var b strings.Builder
+	b.Grow(len(raw))
+	pendingSpace, pendingNewline := false, false
+	for _, r := range raw {
+		switch {
+		case r == '\n':
+			pendingNewline = true
+		case unicode.IsSpace(r):
+			pendingSpace = true
+		case unicode.IsControl(r) || r == utf8.RuneError:
+			// Dropped
+		default:
+			if b.Len() > 0 {
+				if pendingNewline {
+					b.WriteByte('\n')
+				} else if pendingSpace {
+					b.WriteByte(' ')
+				}
+			}
+			pendingSpace, pendingNewline = false, false
+			b.WriteRune(r)
+		}
+		if b.Len() >= MaxTextSize {
+			break
+		}
+	}
+
+	return b.String()
+}
+
+// font returns the decoder for a font resource, building it on first use
+func (ex *extractor) font(value any) *fontDecoder {
+	r, isRef := value.(ref)
+	if isRef {
+		if cached, ok := ex.fonts[r]; ok {
+			return cached
+		}
+	}
+
+	decoder := &fontDecoder{}
+	if font, ok := ex.doc.resolve(value).(dict); ok {
+		decoder = ex.doc.newFontDecoder(font)
+	}
+
+	if isRef {
+		ex.fonts[r] = decoder
+	}
+	return decoder
+}
+
+// stream returns the decoded data of the stream object referenced by value
+func (d *document) stream(value any) ([]byte, error) {
+	_, data, err := d.streamWithHeader(value)
+	return data, err
+}
+
+// streamWithHeader returns a stream's dictionary and decoded data
+func (d *document) streamWithHeader(value any) (dict, []byte, error) {
+	r, ok := value.(ref)
+	if !ok {
+		return nil, nil, errors.New("stream must be an indirect object")
+	}
+
+	d.index()
+	offset, ok := d.offsets[r.num]
+	if !ok {
+		return nil, nil, errors.New("stream object not found")
+	}
+
+	p := d.parserAt(offset)
+	obj, err := p.parseIndirectObject()
+	if err != nil {
+		return nil, nil, err
+	}
+	header, ok := obj.(dict)
+	if !ok {
+		return nil, nil, errors.New("stream has no dictionary")
+	}
+
+	// Resolve an indirect /Length so readStream can use it
+	if length, ok := d.resolve(header["Length"]).(int64); ok {
+		header["Length"] = length
+	}
+
+	data, err := p.readDecodedStream(header)
+	return header, data, err
+}
+
+// fontDecoder maps the bytes of shown strings to Unicode text
+type fontDecoder struct {
+	// width is the number of bytes per character code
+	width int
+	// unicode maps character codes to text, from a ToUnicode CMap or /Differences
+	unicode map[uint32]string
+	// composite fonts without a ToUnicode map can't be decoded
+	opaque bool
+}
+
+// newFontDecoder builds a decoder from a font dictionary
+func (d *document) newFontDecoder(font dict) *fontDecoder {
+	decoder := &fontDecoder{width: 1}
+
+	if cmap, err := d.stream(font["ToUnicode"]); err == nil {
+		parseToUnicode(cmap, decoder)
+		return decoder
+	}
+
+	if font.name("Subtype") == "Type0" {
+		decoder.opaque = true
+		return decoder
+	}
+
+	// Simple fonts: apply /Differences over a Latin-1 base
+	if encoding, ok := d.resolve(font["Encoding"]).(dict); ok {
+		differences, _ := d.resolve(encoding["Differences"]).([]any)
+		code := int64(-1)
+		for _, item := range differences {
+			switch v := item.(type) {
+			case int64:
+				code = v
+			case name:
+				if code >= 0 {
+					if text, ok := glyphText(string(v)); ok {
+						if decoder.unicode == nil {
+							decoder.unicode = make(map[uint32]string)
+						}
+						decoder.unicode[uint32(code)] = text
+					}
+					code++
+				}
+			}
+		}
+	}
+
+	return decoder
+}
+
+func (f *fontDecoder) decode(s string) string {
+	if f.opaque {
+		return ""
+	}
+
+	width := f.width
+	if width <= 0 {
+		width = 1
+	}
+
+	var b strings.Builder
+	for i := 0; i+width <= len(s); i += width {
+		var code uint32
+		for j := 0; j < width; j++ {
+			code = code<<8 | uint32(s[i+j])
+		}
+
+		if text, ok := f.unicode[code]; ok {
+			b.WriteString(text)
+		} else if width == 1 && code >= 0x20 {
+			// Close enough to WinAnsi/PDFDocEncoding for searchable text
+			b.WriteRune(rune(code))
+		}
+	}
+	return b.String()
+}
+
+// parseToUnicode reads the codespace, bfchar and bfrange sections of a CMap
+func parseToUnicode(cmap []byte, decoder *fontDecoder) {
+	decoder.unicode = make(map[uint32]string)
+	var operands []any
+	p := &parser{data: cmap}
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return
+		}
+
+		c := p.data[p.pos]
+		if c == '/' || c == '(' || c == '<' || c == '[' || isDigit(c) || c == '-' {
+			value, err := p.parseObject()
+			if err != nil {
+				p.pos++
+				operands = operands[:0]
+				continue
+			}
+			operands = append(operands, value)
+			continue
+		}
+
+		token := p.readToken()
+		if token == "" {
+			p.pos++
+			continue
+		}
+
+		switch token {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				if lo, ok := operands[i].(string); ok && len(lo) > decoder.width {
+					decoder.width = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(string)
+				dst, ok2 := operands[i+1].(string)
+				if ok1 && ok2 {
+					decoder.unicode[codeOf(src)] = utf16BE(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(string)
+				hi, ok2 := operands[i+1].(string)
+				if !ok1 || !ok2 {
+					continue
+				}
+				start, end := codeOf(lo), codeOf(hi)
+				if end < start || end-start > 0xFFFF {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case string:
+					// Consecutive codes map to consecutive values of the last UTF-16 unit
+					base := []rune(utf16BE(dst))
+					if len(base) == 0 {
+						continue
+					}
+					for code := start; code <= end; code++ {
+						out := append([]rune{}, base...)
+						out[len(out)-1] += rune(code - start)
+						decoder.unicode[code] = string(out)
+					}
+				case []any:
+					for j, item := range dst {
+						if s, ok := item.(string); ok && start+uint32(j) <= end {
+							decoder.unicode[start+uint32(j)] = utf16BE(s)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+func codeOf(s string) uint32 {
+	var code uint32
+	for i := 0; i < len(s) && i < 4; i++ {
+		code = code<<8 | uint32(s[i])
+	}
+	return code
+}
+
+func utf16BE(s string) string {
+	units := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// glyphNames covers the common Adobe glyph names that aren't a single letter
+var glyphNames = map[string]string{
+	"space": " ", "exclam": "!", "quotedbl": "\"", "numbersign": "#", "dollar": "$",
+	"percent": "%", "ampersand": "&", "quotesingle": "'", "quoteright": "’",
+	"quoteleft": "‘", "quotedblleft": "“", "quotedblright": "”",
+	"parenleft": "(", "parenright": ")", "asterisk": "*", "plus": "+", "comma": ",",
+	"hyphen": "-", "minus": "-", "period": ".", "slash": "/", "colon": ":",
+	"semicolon": ";", "less": "<", "equal": "=", "greater": ">", "question": "?",
+	"at": "@", "bracketleft": "[", "backslash": "\\", "bracketright": "]",
+	"underscore": "_", "braceleft": "{", "bar": "|", "braceright": "}",
+	"endash": "–", "emdash": "—", "bullet": "•",
+	"fi": "fi", "fl": "fl", "ff": "ff", "ffi": "ffi", "ffl": "ffl",
+	"zero": "0", "one": "1", "two": "2", "three": "3", "four": "4",
+	"five": "5", "six": "6", "seven": "7", "eight": "8", "nine": "9",
+}
+
+// glyphText maps a glyph name from an encoding's /Differences to text
+func glyphText(glyph string) (string, bool) {
+	if len(glyph) == 1 {
+		return glyph, true
+	}
+	if text, ok := glyphNames[glyph]; ok {
+		return text, true
+	}
+	if strings.HasPrefix(glyph, "uni") && len(glyph) == 7 {
+		if v, err := strconv.ParseUint(glyph[3:], 16, 32); err == nil {
+			return string(rune(v)), true
+		}
+	}
+	return "", false
+}
+
+func lastString(operands []any) string {
+	if len(operands) == 0 {
+		return ""
+	}
+	s, _ := operands[len(operands)-1].(string)
+	return s
+}
+
+func isZero(value any) bool {
+	switch v := value.(type) {
+	case int64:
+		return v == 0
+	case float64:
+		return v == 0
+	}
+	return false
+}
+
+// skipInlineImage skips the binary data between ID and EI
+func skipInlineImage(p *parser) {
+	for p.pos < len(p.data) {
+		p.skipSpace()
+		if p.hasKeyword("ID") {
+			p.pos += len("ID") + 1
+			break
+		}
+		if _, err := p.parseObject(); err != nil {
+			p.pos++
+		}
+	}
+
+	for p.pos+2 < len(p.data) {
+		if isSpace(p.data[p.pos]) && p.data[p.pos+1] == 'E' && p.data[p.pos+2] == 'I' &&
+			(p.pos+3 == len(p.data) || isSpace(p.data[p.pos+3])) {
+			p.pos += 3
+			return
+		}
+		p.pos++
+	}
+	p.pos = len(p.data)
+}
@@ -0,0 +1,115 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// textPDF builds a one-page PDF with the given content stream and font
+// resource. The font is object 5; extra objects are numbered from 6.
+func textPDF(content []byte, compress bool, font string, extra ...string) []byte {
+	stream := fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content)
+	if compress {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(content)
+		zw.Close()
+		stream = fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", buf.Len(), buf.Bytes())
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /Resources << /Font << /F1 5 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		stream,
+		font,
+	}
+	objects = append(objects, extra...)
+	return buildPDF("1.4", objects, "")
+}
+
+func TestExtractText(t *testing.T) {
+	toUnicode := "/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n" +
+		"2 beginbfchar\n<0001> <0045>\n<0002> <0069>\nendbfchar\n" +
+		"1 beginbfrange\n<0003> <0005> <0067>\nendbfrange\nendcmap\nend end\n"
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "simple font literal strings",
+			data: textPDF([]byte("BT /F1 12 Tf 72 720 Td (Lecture 12: Eigenvalues) Tj 0 -14 Td (and eigenvectors) Tj ET"),
+				false, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"),
+			want: []string{"Lecture 12: Eigenvalues\nand eigenvectors"},
+		},
+		{
+			name: "compressed stream with kerning and escapes",
+			data: textPDF([]byte(`BT /F1 10 Tf [(Linear)-333(Alg)10(ebra) -500 (\(review\))] TJ ET`),
+				true, "<< /Type /Font /Subtype /Type1 >>"),
+			want: []string{"Linear Algebra (review)"},
+		},
+		{
+			name: "differences encoding",
+			data: textPDF([]byte("BT /F1 10 Tf (\x01nite) Tj ET"),
+				false, "<< /Type /Font /Subtype /Type1 /Encoding 6 0 R >>",
+				"<< /Type /Encoding /Differences [1 /fi] >>"),
+			want: []string{"finite"},
+		},
+		{
+			name: "composite font with ToUnicode",
+			data: textPDF([]byte("BT /F1 10 Tf <00010002000300040005> Tj ET"),
+				false, "<< /Type /Font /Subtype /Type0 /ToUnicode 6 0 R >>",
+				fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(toUnicode), toUnicode)),
+			want: []string{"Eighi"},
+		},
+		{
+			name: "composite font without ToUnicode is skipped",
+			data: textPDF([]byte("BT /F1 10 Tf <00010002> Tj ET"),
+				false, "<< /Type /Font /Subtype /Type0 >>"),
+			notWant: []string{"\x00"},
+		},
+		{
+			name: "inline image is skipped",
+			data: textPDF([]byte("BI /W 2 /H 1 /BPC 8 /CS /G ID \x00(\xff EI Q BT /F1 9 Tf (after) Tj ET"),
+				false, "<< /Type /Font /Subtype /Type1 >>"),
+			want: []string{"after"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := ExtractText(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("ExtractText() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("ExtractText() = %q, want it to contain %q", text, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(text, notWant) {
+					t.Errorf("ExtractText() = %q, should not contain %q", text, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractText_RejectsEncrypted(t *testing.T) {
+	data := buildPDF("1.6", []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [] /Count 1 >>",
+	}, "/Encrypt << /Filter /Standard >> ")
+
+	if _, err := ExtractText(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Fatal("ExtractText() should fail for encrypted PDFs")
+	}
+}
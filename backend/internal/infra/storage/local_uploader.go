@@ -0,0 +1,396 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidSignature is returned when a signed URL has been tampered with
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrURLExpired is returned when a signed URL is past its expiry time
+	ErrURLExpired = errors.New("signed URL expired")
+	// ErrUploadMismatch is returned when a signed upload's body doesn't match the
+	// size or checksum it was signed for
+	ErrUploadMismatch = errors.New("upload does not match its signed size or checksum")
+)
+
+// LocalUploader implements Uploader interface on the local filesystem. Files are
+// served back through HMAC-signed, expiring URLs under baseURL.
+type LocalUploader struct {
+	root    string
+	baseURL string
+	secret  []byte
+}
+
+// NewLocalUploader creates a new local filesystem uploader rooted at root. baseURL is
+// the public URL that the signed file route is mounted on, e.g. http://localhost:8080/files
+func NewLocalUploader(root, baseURL string, secret []byte) (*LocalUploader, error) {
+	if root == "" {
+		return nil, errors.New("storage root directory is required")
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("URL signing secret is required")
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage root: %w", err)
+	}
+
+	if err := os.MkdirAll(absRoot, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create storage root: %w", err)
+	}
+
+	slog.Info("Local uploader initialized successfully", "root", absRoot, "baseURL", baseURL)
+
+	return &LocalUploader{
+		root:    absRoot,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  secret,
+	}, nil
+}
+
+// Upload writes a file to disk atomically, see write
+func (l *LocalUploader) Upload(ctx context.Context, key string, body io.Reader, contentType string, size int64) error {
+	slog.Debug("Starting local upload", "key", key, "contentType", contentType, "size", size)
+
+	return l.write(key, body, func(written int64) error {
+		if size >= 0 && written != size {
+			return fmt.Errorf("file size mismatch: expected %d bytes, got %d", size, written)
+		}
+		return nil
+	})
+}
+
+// write stores body under key atomically: the body goes to a temp file in the target
+// directory, check is given its length, and only if it passes is the file fsynced and
+// renamed into place. On any failure the file already at key is left as it was.
+func (l *LocalUploader) write(key string, body io.Reader, check func(written int64) error) error {
+	target, err := l.resolve(key)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	// Remove the temp file on any failure; after a successful rename this is a no-op
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, body)
+	if err != nil {
+		tmp.Close()
+		slog.Error("Failed to write file", "error", err, "key", key)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := check(written); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
+
+	// Sync the directory so the rename itself survives a crash
+	if err := syncDir(dir); err != nil {
+		slog.Warn("Failed to sync directory", "error", err, "dir", dir)
+	}
+
+	slog.Info("File stored locally successfully", "key", key, "size", written)
+	return nil
+}
+
+// GetPresignedURL generates a signed, expiring URL for downloading a file
+func (l *LocalUploader) GetPresignedURL(ctx context.Context, key, fileName string, expiration time.Duration) (string, error) {
+	target, err := l.resolve(key)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(target); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("file not found: %s", key)
+		}
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	expires := time.Now().Add(expiration).Unix()
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("filename", fileName)
+	query.Set("signature", l.sign(key, expires, fileName))
+
+	return l.baseURL + "/" + escapeKey(key) + "?" + query.Encode(), nil
+}
+
+// PresignUpload generates a signed, expiring URL that StoreSigned accepts a PUT of
+// the file on
+func (l *LocalUploader) PresignUpload(ctx context.Context, key, contentType string, size int64, checksum string, expiration time.Duration) (*PresignedUpload, error) {
+	if _, err := l.resolve(key); err != nil {
+		return nil, err
+	}
+
+	expires := time.Now().Add(expiration).Unix()
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("size", strconv.FormatInt(size, 10))
+	query.Set("checksum", checksum)
+	query.Set("signature", l.signUpload(key, expires, contentType, size, checksum))
+
+	return &PresignedUpload{
+		URL:     l.baseURL + "/" + escapeKey(key) + "?" + query.Encode(),
+		Method:  http.MethodPut,
+		Headers: map[string]string{"Content-Type": contentType},
+	}, nil
+}
+
+// StoreSigned verifies a signed upload URL's parameters and stores body under key.
+// A body that doesn't match the signed size and checksum is discarded before it can
+// replace anything at key.
+func (l *LocalUploader) StoreSigned(ctx context.Context, key, contentType string, size int64, checksum string, expires int64, signature string, body io.Reader) error {
+	expected := l.signUpload(key, expires, contentType, size, checksum)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	if time.Now().Unix() > expires {
+		return ErrURLExpired
+	}
+
+	// Read one byte past the size so an oversized body is caught without reading it all
+	hash := sha256.New()
+	return l.write(key, io.TeeReader(io.LimitReader(body, size+1), hash), func(written int64) error {
+		if written != size || hex.EncodeToString(hash.Sum(nil)) != checksum {
+			return ErrUploadMismatch
+		}
+		return nil
+	})
+}
+
+// Stat describes a file on disk. The filesystem keeps no content type, so it is
+// sniffed from the file's first bytes.
+func (l *LocalUploader) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	target, err := l.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(target)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	hash := sha256.New()
+	hash.Write(head[:n])
+	rest, err := io.Copy(hash, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return &ObjectInfo{
+		Size:        int64(n) + rest,
+		ContentType: http.DetectContentType(head[:n]),
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// Open opens a file on disk
+func (l *LocalUploader) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	target, err := l.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(target)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return file, nil
+}
+
+// List walks the files under prefix. Temp files of interrupted uploads are listed
+// too, since nothing else will ever clean them up.
+func (l *LocalUploader) List(ctx context.Context, prefix string, fn func(ObjectSummary) error) error {
+	// Walk from the deepest directory the prefix names, then match the rest
+	dir := "."
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = strings.TrimSuffix(prefix[:i+1], "/")
+	}
+	start := l.root
+	if dir != "." && dir != "" {
+		var err error
+		if start, err = l.resolve(dir); err != nil {
+			return err
+		}
+	}
+
+	return filepath.WalkDir(start, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return fmt.Errorf("failed to list files: %w", err)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.root, p)
+		if err != nil {
+			return fmt.Errorf("failed to list files: %w", err)
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// Deleted since the directory was read
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return fmt.Errorf("failed to stat file: %w", err)
+		}
+		return fn(ObjectSummary{Key: key, Size: info.Size(), LastModified: info.ModTime()})
+	})
+}
+
+// Delete removes a file from disk
+func (l *LocalUploader) Delete(ctx context.Context, key string) error {
+	target, err := l.resolve(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(target); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("file not found: %s", key)
+		}
+		slog.Error("Failed to delete local file", "error", err, "key", key)
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	slog.Info("Local file deleted successfully", "key", key)
+	return nil
+}
+
+// OpenSigned verifies a signed URL's parameters and opens the file it refers to
+func (l *LocalUploader) OpenSigned(key, fileName string, expires int64, signature string) (*os.File, error) {
+	expected := l.sign(key, expires, fileName)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, ErrInvalidSignature
+	}
+
+	if time.Now().Unix() > expires {
+		return nil, ErrURLExpired
+	}
+
+	target, err := l.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(target)
+}
+
+// sign computes the URL signature over the key, expiry and download file name
+func (l *LocalUploader) sign(key string, expires int64, fileName string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	fmt.Fprintf(mac, "%s\n%d\n%s", key, expires, fileName)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signUpload computes the signature of an upload URL over the key, expiry and the
+// file's content type, size and checksum. The prefix keeps it from ever matching a
+// download signature.
+func (l *LocalUploader) signUpload(key string, expires int64, contentType string, size int64, checksum string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	fmt.Fprintf(mac, "upload\n%s\n%d\n%s\n%d\n%s", key, expires, contentType, size, checksum)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// resolve maps a storage key to a path inside the root, rejecting traversal
+func (l *LocalUploader) resolve(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+
+	cleaned := path.Clean(key)
+	if cleaned != key || cleaned == "." || strings.HasPrefix(cleaned, "../") || cleaned == ".." {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+
+	return filepath.Join(l.root, filepath.FromSlash(cleaned)), nil
+}
+
+// escapeKey path-escapes each segment of a key while keeping the separators
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// syncDir fsyncs a directory
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLocalUploader(t *testing.T) *LocalUploader {
+	t.Helper()
+	uploader, err := NewLocalUploader(t.TempDir(), "http://localhost:8080/files", []byte("test-secret"))
+	if err != nil {
+		t.Fatalf("NewLocalUploader() error = %v", err)
+	}
+	return uploader
+}
+
+// signedParams extracts the signed query parameters from a presigned URL
+func signedParams(t *testing.T, rawURL string) (key, fileName string, expires int64, signature string) {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("invalid presigned URL %q: %v", rawURL, err)
+	}
+	expires, err = strconv.ParseInt(u.Query().Get("expires"), 10, 64)
+	if err != nil {
+		t.Fatalf("invalid expires in %q: %v", rawURL, err)
+	}
+	return strings.TrimPrefix(u.Path, "/files/"), u.Query().Get("filename"), expires, u.Query().Get("signature")
+}
+
+func TestLocalUploader_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	uploader := newTestLocalUploader(t)
+	key := GenerateFileKey("test@rice.edu", "note-1", "lecture 1.pdf")
+
+	if err := uploader.Upload(ctx, key, strings.NewReader("%PDF-1.7"), "application/pdf", 8); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	presigned, err := uploader.GetPresignedURL(ctx, key, "lecture 1.pdf", time.Minute)
+	if err != nil {
+		t.Fatalf("GetPresignedURL() error = %v", err)
+	}
+
+	gotKey, fileName, expires, signature := signedParams(t, presigned)
+	if gotKey != key {
+		t.Errorf("presigned URL key = %q, want %q", gotKey, key)
+	}
+
+	file, err := uploader.OpenSigned(gotKey, fileName, expires, signature)
+	if err != nil {
+		t.Fatalf("OpenSigned() error = %v", err)
+	}
+	data, _ := io.ReadAll(file)
+	file.Close()
+	if string(data) != "%PDF-1.7" {
+		t.Errorf("stored contents = %q, want %q", data, "%PDF-1.7")
+	}
+
+	if _, err := uploader.OpenSigned(gotKey, "other.pdf", expires, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("OpenSigned() with tampered filename error = %v, want %v", err, ErrInvalidSignature)
+	}
+
+	body, err := uploader.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	data, _ = io.ReadAll(body)
+	body.Close()
+	if string(data) != "%PDF-1.7" {
+		t.Errorf("Open() contents = %q, want %q", data, "%PDF-1.7")
+	}
+
+	if err := uploader.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := uploader.Delete(ctx, key); err == nil {
+		t.Error("Delete() of missing file should fail")
+	}
+	if _, err := uploader.Open(ctx, key); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Open() of missing file error = %v, want %v", err, ErrObjectNotFound)
+	}
+}
+
+func TestLocalUploader_List(t *testing.T) {
+	ctx := context.Background()
+	uploader := newTestLocalUploader(t)
+
+	for _, key := range []string{"notes/u/n/b.pdf", "notes/u/n/versions/v/a.pdf", "notes/u/n/a.pdf", "other/c.pdf"} {
+		if err := uploader.Upload(ctx, key, strings.NewReader("%PDF-1.7"), "application/pdf", 8); err != nil {
+			t.Fatalf("Upload(%q) error = %v", key, err)
+		}
+	}
+
+	list := func(prefix string) []string {
+		t.Helper()
+		var keys []string
+		err := uploader.List(ctx, prefix, func(object ObjectSummary) error {
+			if object.Size != 8 || object.LastModified.IsZero() {
+				t.Errorf("List() object = %+v, want size and modification time", object)
+			}
+			keys = append(keys, object.Key)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("List(%q) error = %v", prefix, err)
+		}
+		return keys
+	}
+
+	want := []string{"notes/u/n/a.pdf", "notes/u/n/b.pdf", "notes/u/n/versions/v/a.pdf"}
+	if got := list("notes/"); !reflect.DeepEqual(got, want) {
+		t.Errorf("List(notes/) = %v, want %v", got, want)
+	}
+	if got := list("notes/u/n/b"); !reflect.DeepEqual(got, []string{"notes/u/n/b.pdf"}) {
+		t.Errorf("List(notes/u/n/b) = %v, want [notes/u/n/b.pdf]", got)
+	}
+	if got := list("missing/"); got != nil {
+		t.Errorf("List(missing/) = %v, want none", got)
+	}
+	if got := list(""); len(got) != 4 {
+		t.Errorf("List() of everything = %v, want 4 files", got)
+	}
+}
+
+func TestLocalUploader_Expired(t *testing.T) {
+	ctx := context.Background()
+	uploader := newTestLocalUploader(t)
+
+	if err := uploader.Upload(ctx, "notes/a.pdf", strings.NewReader("x"), "application/pdf", 1); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	presigned, err := uploader.GetPresignedURL(ctx, "notes/a.pdf", "a.pdf", -time.Minute)
+	if err != nil {
+		t.Fatalf("GetPresignedURL() error = %v", err)
+	}
+
+	key, fileName, expires, signature := signedParams(t, presigned)
+	if _, err := uploader.OpenSigned(key, fileName, expires, signature); !errors.Is(err, ErrURLExpired) {
+		t.Errorf("OpenSigned() error = %v, want %v", err, ErrURLExpired)
+	}
+}
+
+func TestLocalUploader_RejectsBadKeys(t *testing.T) {
+	ctx := context.Background()
+	uploader := newTestLocalUploader(t)
+
+	for _, key := range []string{"", "/etc/passwd", "../outside.pdf", "notes/../../outside.pdf", "notes/./a.pdf"} {
+		if err := uploader.Upload(ctx, key, strings.NewReader("x"), "application/pdf", 1); err == nil {
+			t.Errorf("Upload(%q) should fail", key)
+		}
+	}
+}
+
+func TestLocalUploader_SizeMismatch(t *testing.T) {
+	uploader := newTestLocalUploader(t)
+
+	err := uploader.Upload(context.Background(), "notes/short.pdf", strings.NewReader("abc"), "application/pdf", 10)
+	if err == nil {
+		t.Fatal("Upload() with wrong size should fail")
+	}
+
+	if _, err := uploader.GetPresignedURL(context.Background(), "notes/short.pdf", "short.pdf", time.Minute); err == nil {
+		t.Error("partial upload should not be visible")
+	}
+}
+
+func TestLocalUploader_SignedUpload(t *testing.T) {
+	ctx := context.Background()
+	uploader := newTestLocalUploader(t)
+	key := GenerateFileKey("user-1", "note-1", "lecture.pdf")
+	data := "%PDF-1.7 lecture notes"
+	sum := sha256.Sum256([]byte(data))
+	checksum := hex.EncodeToString(sum[:])
+
+	presigned, err := uploader.PresignUpload(ctx, key, "application/pdf", int64(len(data)), checksum, time.Minute)
+	if err != nil {
+		t.Fatalf("PresignUpload() error = %v", err)
+	}
+	if presigned.Method != http.MethodPut || presigned.Headers["Content-Type"] != "application/pdf" {
+		t.Errorf("PresignUpload() = %+v, want a PUT sending Content-Type", presigned)
+	}
+
+	u, err := url.Parse(presigned.URL)
+	if err != nil {
+		t.Fatalf("invalid presigned URL %q: %v", presigned.URL, err)
+	}
+	gotKey := strings.TrimPrefix(u.Path, "/files/")
+	query := u.Query()
+	size, _ := strconv.ParseInt(query.Get("size"), 10, 64)
+	expires, _ := strconv.ParseInt(query.Get("expires"), 10, 64)
+	store := func(contentType, body string) error {
+		return uploader.StoreSigned(ctx, gotKey, contentType, size, query.Get("checksum"), expires, query.Get("signature"), strings.NewReader(body))
+	}
+
+	if err := store("text/plain", data); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("StoreSigned() with another content type error = %v, want %v", err, ErrInvalidSignature)
+	}
+	for _, body := range []string{"%PDF-1.7 lecture notez", data + " and more"} {
+		if err := store("application/pdf", body); !errors.Is(err, ErrUploadMismatch) {
+			t.Errorf("StoreSigned(%q) error = %v, want %v", body, err, ErrUploadMismatch)
+		}
+		if _, err := uploader.Stat(ctx, key); !errors.Is(err, ErrObjectNotFound) {
+			t.Errorf("Stat() after mismatched upload error = %v, want %v", err, ErrObjectNotFound)
+		}
+	}
+
+	if err := store("application/pdf", data); err != nil {
+		t.Fatalf("StoreSigned() error = %v", err)
+	}
+	info, err := uploader.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	want := ObjectInfo{Size: int64(len(data)), ContentType: "application/pdf", SHA256: checksum}
+	if *info != want {
+		t.Errorf("Stat() = %+v, want %+v", *info, want)
+	}
+
+	// A mismatched retry of the same URL must not replace the stored file
+	if err := store("application/pdf", "%PDF-1.7 lecture notez"); !errors.Is(err, ErrUploadMismatch) {
+		t.Errorf("StoreSigned() over a stored file error = %v, want %v", err, ErrUploadMismatch)
+	}
+	if info, err := uploader.Stat(ctx, key); err != nil || *info != want {
+		t.Errorf("Stat() after mismatched retry = %+v, %v, want %+v", info, err, want)
+	}
+}
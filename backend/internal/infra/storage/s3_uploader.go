@@ -2,64 +2,241 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+// ErrObjectNotFound is returned by Stat and Open when nothing is stored under a key
+var ErrObjectNotFound = errors.New("object not found")
+
 // Uploader defines the interface for file upload operations
 type Uploader interface {
+	// Upload stores body under key. size is the body's length, or -1 if it is not
+	// known until the body has been read.
 	Upload(ctx context.Context, key string, body io.Reader, contentType string, size int64) error
-	GetPresignedURL(ctx context.Context, key string, expiration time.Duration) (string, error)
+	GetPresignedURL(ctx context.Context, key, fileName string, expiration time.Duration) (string, error)
+	// PresignUpload returns a URL a client can PUT a file to directly. The request
+	// must send the returned headers and exactly size bytes whose hex SHA-256 is checksum.
+	PresignUpload(ctx context.Context, key, contentType string, size int64, checksum string, expiration time.Duration) (*PresignedUpload, error)
+	// Stat describes the object stored under key, or returns ErrObjectNotFound
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Open streams the object stored under key, or returns ErrObjectNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// List calls fn for every object whose key starts with prefix, in key order,
+	// stopping at the first error fn returns
+	List(ctx context.Context, prefix string, fn func(ObjectSummary) error) error
 	Delete(ctx context.Context, key string) error
 }
 
+// PresignedUpload is a URL a client can upload a file to without going through
+// this server, and the headers the upload must carry
+type PresignedUpload struct {
+	URL     string
+	Method  string
+	Headers map[string]string
+}
+
+// ObjectInfo describes a stored object. SHA256 is hex encoded, and empty if the
+// backend has no checksum for the object.
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+	SHA256      string
+}
+
+// ObjectSummary is a stored object as listed by Uploader.List
+type ObjectSummary struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// S3Config configures an S3Uploader. Only Bucket and Region are needed for AWS;
+// the remaining fields support S3-compatible servers such as MinIO.
+type S3Config struct {
+	Bucket string
+	Region string
+	// Endpoint overrides the AWS endpoint, e.g. http://localhost:9000
+	Endpoint string
+	// UsePathStyle addresses buckets as endpoint/bucket instead of bucket.endpoint
+	UsePathStyle bool
+	// AccessKeyID and SecretAccessKey set static credentials instead of the
+	// default AWS credential chain
+	AccessKeyID     string
+	SecretAccessKey string
+	// CreateBucket creates the bucket on startup if it doesn't exist
+	CreateBucket bool
+	// ServerSideEncryption is the SSE algorithm for uploads (e.g. "AES256",
+	// "aws:kms"); empty or "none" disables it
+	ServerSideEncryption string
+}
+
 // S3Uploader implements Uploader interface using AWS S3
 type S3Uploader struct {
 	client *s3.Client
 	bucket string
+	sse    types.ServerSideEncryption
 }
 
 // NewS3Uploader creates a new S3 uploader instance
-func NewS3Uploader(ctx context.Context, bucket string, region string) (*S3Uploader, error) {
+func NewS3Uploader(ctx context.Context, cfg S3Config) (*S3Uploader, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("S3 bucket name is required")
+	}
+
+	sse, err := parseServerSideEncryption(cfg.ServerSideEncryption)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []func(*config.LoadOptions) error{config.WithRegion(cfg.Region)}
+	if cfg.AccessKeyID != "" || cfg.SecretAccessKey != "" {
+		if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+			return nil, errors.New("both S3 access key ID and secret access key must be set")
+		}
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, "")))
+	}
+
 	// Load AWS config
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
 	if err != nil {
 		slog.Error("Failed to load AWS config", "error", err)
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
-	client := s3.NewFromConfig(cfg)
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
+
+	uploader := &S3Uploader{
+		client: client,
+		bucket: cfg.Bucket,
+		sse:    sse,
+	}
+
+	if cfg.CreateBucket {
+		if err := uploader.ensureBucket(ctx, cfg.Region); err != nil {
+			return nil, err
+		}
+	}
 
-	// Note: Skipping HeadBucket check as it requires additional permissions
-	// The bucket access will be validated on first upload operation
+	// Note: Without CreateBucket we skip the HeadBucket check as it requires additional
+	// permissions. The bucket access will be validated on first upload operation
 
-	slog.Info("S3 uploader initialized successfully", "bucket", bucket, "region", region)
+	slog.Info("S3 uploader initialized successfully", "bucket", cfg.Bucket, "region", cfg.Region,
+		"endpoint", cfg.Endpoint, "pathStyle", cfg.UsePathStyle, "sse", sse)
 
-	return &S3Uploader{
-		client: client,
-		bucket: bucket,
-	}, nil
+	return uploader, nil
 }
 
-// Upload uploads a file to S3
+// ensureBucket creates the bucket if it doesn't already exist
+func (s *S3Uploader) ensureBucket(ctx context.Context, region string) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	if err == nil {
+		return nil
+	}
+
+	var notFound *types.NotFound
+	if !errors.As(err, &notFound) {
+		slog.Error("Failed to check S3 bucket", "error", err, "bucket", s.bucket)
+		return fmt.Errorf("failed to check bucket %s: %w", s.bucket, err)
+	}
+
+	input := &s3.CreateBucketInput{Bucket: aws.String(s.bucket)}
+	// us-east-1 is the default location and must not be sent as a constraint
+	if region != "" && region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+
+	if _, err := s.client.CreateBucket(ctx, input); err != nil {
+		var owned *types.BucketAlreadyOwnedByYou
+		if errors.As(err, &owned) {
+			return nil
+		}
+		slog.Error("Failed to create S3 bucket", "error", err, "bucket", s.bucket)
+		return fmt.Errorf("failed to create bucket %s: %w", s.bucket, err)
+	}
+
+	slog.Info("Created S3 bucket", "bucket", s.bucket)
+	return nil
+}
+
+// parseServerSideEncryption validates a configured SSE algorithm
+func parseServerSideEncryption(value string) (types.ServerSideEncryption, error) {
+	if value == "" || value == "none" {
+		return "", nil
+	}
+
+	for _, known := range types.ServerSideEncryption("").Values() {
+		if string(known) == value {
+			return known, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported server-side encryption %q", value)
+}
+
+// partSize is the size of each part of a multipart upload. Bodies that fit in one
+// part are sent with a single PutObject; 5 MiB is the smallest part S3 accepts.
+const partSize = 5 << 20
+
+// Upload uploads a file to S3. Bodies larger than one part are streamed as a
+// multipart upload, so at most one part is held in memory whatever the file size.
 func (s *S3Uploader) Upload(ctx context.Context, key string, body io.Reader, contentType string, size int64) error {
 	slog.Debug("Starting S3 upload", "key", key, "contentType", contentType, "size", size)
 
+	// Read the first part to find out whether a multipart upload is needed at all.
+	// Small bodies are sent from the buffer, which also lets the SDK checksum them
+	// without a trailing checksum, which plain HTTP endpoints don't support.
+	first := make([]byte, partSize)
+	n, err := io.ReadFull(body, first)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return s.putObject(ctx, key, bytes.NewReader(first[:n]), contentType, int64(n))
+	}
+	if err != nil {
+		slog.Error("Failed to read upload body", "error", err, "key", key)
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return s.multipartUpload(ctx, key, first, body, contentType)
+}
+
+// putObject uploads a buffered body in a single request
+func (s *S3Uploader) putObject(ctx context.Context, key string, body *bytes.Reader, contentType string, size int64) error {
 	input := &s3.PutObjectInput{
-		Bucket:        aws.String(s.bucket),
-		Key:           aws.String(key),
-		Body:          body,
-		ContentType:   aws.String(contentType),
-		ContentLength: aws.Int64(size),
-		ServerSideEncryption: types.ServerSideEncryptionAes256,
+		Bucket:               aws.String(s.bucket),
+		Key:                  aws.String(key),
+		Body:                 body,
+		ContentType:          aws.String(contentType),
+		ContentLength:        aws.Int64(size),
+		ServerSideEncryption: s.sse,
 	}
 
 	_, err := s.client.PutObject(ctx, input)
@@ -72,15 +249,90 @@ func (s *S3Uploader) Upload(ctx context.Context, key string, body io.Reader, con
 	return nil
 }
 
-// GetPresignedURL generates a presigned URL for downloading a file
-func (s *S3Uploader) GetPresignedURL(ctx context.Context, key string, expiration time.Duration) (string, error) {
+// multipartUpload uploads first and then the rest of body part by part. The upload is
+// aborted on failure so S3 doesn't keep the parts already sent.
+func (s *S3Uploader) multipartUpload(ctx context.Context, key string, first []byte, body io.Reader, contentType string) error {
+	created, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:               aws.String(s.bucket),
+		Key:                  aws.String(key),
+		ContentType:          aws.String(contentType),
+		ServerSideEncryption: s.sse,
+	})
+	if err != nil {
+		slog.Error("Failed to start S3 multipart upload", "error", err, "key", key)
+		return fmt.Errorf("failed to upload file to S3: %w", err)
+	}
+
+	parts, size, err := s.uploadParts(ctx, key, created.UploadId, first, body)
+	if err == nil {
+		_, err = s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(s.bucket),
+			Key:             aws.String(key),
+			UploadId:        created.UploadId,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+		})
+	}
+	if err != nil {
+		slog.Error("Failed to upload to S3", "error", err, "key", key)
+		// The request context may be what failed, so abort without it
+		_, abortErr := s.client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s.bucket),
+			Key:      aws.String(key),
+			UploadId: created.UploadId,
+		})
+		if abortErr != nil {
+			slog.Error("Failed to abort S3 multipart upload", "error", abortErr, "key", key)
+		}
+		return fmt.Errorf("failed to upload file to S3: %w", err)
+	}
+
+	slog.Info("File uploaded to S3 successfully", "key", key, "bucket", s.bucket, "size", size, "parts", len(parts))
+	return nil
+}
+
+// uploadParts sends first as part one and the rest of body in parts of the same
+// size, reusing first's buffer, and returns the completed parts and the total size
+func (s *S3Uploader) uploadParts(ctx context.Context, key string, uploadID *string, first []byte, body io.Reader) ([]types.CompletedPart, int64, error) {
+	var parts []types.CompletedPart
+	var size int64
+
+	buf, n := first, len(first)
+	for number := int32(1); ; number++ {
+		part, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(s.bucket),
+			Key:           aws.String(key),
+			UploadId:      uploadID,
+			PartNumber:    aws.Int32(number),
+			Body:          bytes.NewReader(buf[:n]),
+			ContentLength: aws.Int64(int64(n)),
+		})
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to upload part %d: %w", number, err)
+		}
+		parts = append(parts, types.CompletedPart{ETag: part.ETag, PartNumber: aws.Int32(number)})
+		size += int64(n)
+
+		n, err = io.ReadFull(body, buf)
+		if err == io.EOF {
+			return parts, size, nil
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, 0, fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+}
+
+// GetPresignedURL generates a presigned URL for downloading a file. The response
+// is served as an attachment named fileName.
+func (s *S3Uploader) GetPresignedURL(ctx context.Context, key, fileName string, expiration time.Duration) (string, error) {
 	slog.Debug("Generating presigned URL", "key", key, "expiration", expiration)
 
 	presignClient := s3.NewPresignClient(s.client)
 
 	request, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
+		Bucket:                     aws.String(s.bucket),
+		Key:                        aws.String(key),
+		ResponseContentDisposition: aws.String(ContentDisposition(fileName)),
 	}, func(opts *s3.PresignOptions) {
 		opts.Expires = expiration
 	})
@@ -94,6 +346,118 @@ func (s *S3Uploader) GetPresignedURL(ctx context.Context, key string, expiration
 	return request.URL, nil
 }
 
+// PresignUpload generates a presigned PUT URL. The size, content type and checksum
+// are signed, so S3 rejects an upload that doesn't match them.
+func (s *S3Uploader) PresignUpload(ctx context.Context, key, contentType string, size int64, checksum string, expiration time.Duration) (*PresignedUpload, error) {
+	slog.Debug("Generating presigned upload URL", "key", key, "size", size, "expiration", expiration)
+
+	sum, err := hex.DecodeString(checksum)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SHA-256 checksum: %w", err)
+	}
+
+	presignClient := s3.NewPresignClient(s.client)
+	request, err := presignClient.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:               aws.String(s.bucket),
+		Key:                  aws.String(key),
+		ContentType:          aws.String(contentType),
+		ContentLength:        aws.Int64(size),
+		ChecksumSHA256:       aws.String(base64.StdEncoding.EncodeToString(sum)),
+		ServerSideEncryption: s.sse,
+	}, func(opts *s3.PresignOptions) {
+		opts.Expires = expiration
+	})
+	if err != nil {
+		slog.Error("Failed to generate presigned upload URL", "error", err, "key", key)
+		return nil, fmt.Errorf("failed to generate presigned upload URL: %w", err)
+	}
+
+	// Browsers set Host and Content-Length themselves and refuse to have them set
+	headers := make(map[string]string)
+	for name, values := range request.SignedHeader {
+		if name == "Host" || name == "Content-Length" || len(values) == 0 {
+			continue
+		}
+		headers[name] = values[0]
+	}
+
+	return &PresignedUpload{URL: request.URL, Method: request.Method, Headers: headers}, nil
+}
+
+// Stat describes an object with HeadObject, including its SHA-256 checksum if it
+// was uploaded with one
+func (s *S3Uploader) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(s.bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, ErrObjectNotFound
+		}
+		slog.Error("Failed to stat S3 object", "error", err, "key", key)
+		return nil, fmt.Errorf("failed to stat file in S3: %w", err)
+	}
+
+	info := &ObjectInfo{
+		Size:        aws.ToInt64(head.ContentLength),
+		ContentType: aws.ToString(head.ContentType),
+	}
+	if head.ChecksumSHA256 != nil {
+		// Checksums of multipart uploads are composite and can't be compared
+		if sum, err := base64.StdEncoding.DecodeString(*head.ChecksumSHA256); err == nil {
+			info.SHA256 = hex.EncodeToString(sum)
+		}
+	}
+	return info, nil
+}
+
+// Open streams an object from S3
+func (s *S3Uploader) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, ErrObjectNotFound
+		}
+		slog.Error("Failed to get S3 object", "error", err, "key", key)
+		return nil, fmt.Errorf("failed to get file from S3: %w", err)
+	}
+	return object.Body, nil
+}
+
+// List pages through the objects under prefix with ListObjectsV2
+func (s *S3Uploader) List(ctx context.Context, prefix string, fn func(ObjectSummary) error) error {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			slog.Error("Failed to list S3 objects", "error", err, "prefix", prefix)
+			return fmt.Errorf("failed to list files in S3: %w", err)
+		}
+		for _, object := range page.Contents {
+			err := fn(ObjectSummary{
+				Key:          aws.ToString(object.Key),
+				Size:         aws.ToInt64(object.Size),
+				LastModified: aws.ToTime(object.LastModified),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Delete removes a file from S3
 func (s *S3Uploader) Delete(ctx context.Context, key string) error {
 	slog.Debug("Deleting file from S3", "key", key)
@@ -112,20 +476,62 @@ func (s *S3Uploader) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
-// GenerateFileKey creates a structured S3 key for a file
-func GenerateFileKey(userEmail, noteID, fileName string) string {
-	return fmt.Sprintf("notes/%s/%s/%s", userEmail, noteID, fileName)
+// NotesPrefix is the key prefix every note's files are stored under
+const NotesPrefix = "notes/"
+
+// GenerateFileKey creates a structured S3 key for a file under its owner's user ID
+func GenerateFileKey(ownerID, noteID, fileName string) string {
+	return fmt.Sprintf(NotesPrefix+"%s/%s/%s", ownerID, noteID, keySafeName(fileName))
+}
+
+// GenerateVersionFileKey creates the key for a later revision of a note's file, so
+// every revision is stored separately even when the file name is unchanged
+func GenerateVersionFileKey(ownerID, noteID, versionID, fileName string) string {
+	return fmt.Sprintf(NotesPrefix+"%s/%s/versions/%s/%s", ownerID, noteID, versionID, keySafeName(fileName))
 }
 
-// MockUploader is a mock implementation of Uploader for testing
+// keySafeName makes a client-supplied file name usable as the last segment of a key.
+// Path separators and control characters are replaced, so the name can't reach outside
+// its note's prefix; the original name is kept on the note for downloads.
+func keySafeName(fileName string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, fileName)
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+// ContentDisposition builds an attachment Content-Disposition header value that
+// preserves the original file name, including non-ASCII characters.
+func ContentDisposition(fileName string) string {
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": fileName})
+	if disposition == "" {
+		// FormatMediaType rejects names it cannot encode; fall back to a bare attachment
+		return "attachment"
+	}
+	return disposition
+}
+
+// MockUploader is a mock implementation of Uploader for testing. It is safe for
+// concurrent use, since background jobs read files while handlers write them.
 type MockUploader struct {
-	files map[string][]byte
+	mu           sync.RWMutex
+	files        map[string][]byte
+	contentTypes map[string]string
+	modified     map[string]time.Time
 }
 
 // NewMockUploader creates a new mock uploader for testing
 func NewMockUploader() *MockUploader {
 	return &MockUploader{
-		files: make(map[string][]byte),
+		files:        make(map[string][]byte),
+		contentTypes: make(map[string]string),
+		modified:     make(map[string]time.Time),
 	}
 }
 
@@ -135,26 +541,104 @@ func (m *MockUploader) Upload(ctx context.Context, key string, body io.Reader, c
 	if err != nil {
 		return fmt.Errorf("failed to read body: %w", err)
 	}
-	
+
+	m.mu.Lock()
 	m.files[key] = data
+	m.contentTypes[key] = contentType
+	m.modified[key] = time.Now()
+	m.mu.Unlock()
 	slog.Debug("Mock upload successful", "key", key, "size", len(data))
 	return nil
 }
 
 // GetPresignedURL returns a mock URL
-func (m *MockUploader) GetPresignedURL(ctx context.Context, key string, expiration time.Duration) (string, error) {
-	if _, exists := m.files[key]; !exists {
+func (m *MockUploader) GetPresignedURL(ctx context.Context, key, fileName string, expiration time.Duration) (string, error) {
+	m.mu.RLock()
+	_, exists := m.files[key]
+	m.mu.RUnlock()
+	if !exists {
 		return "", fmt.Errorf("file not found: %s", key)
 	}
-	return fmt.Sprintf("https://mock-bucket.s3.amazonaws.com/%s?expires=%d", key, time.Now().Add(expiration).Unix()), nil
+	query := url.Values{}
+	query.Set("expires", fmt.Sprint(time.Now().Add(expiration).Unix()))
+	query.Set("response-content-disposition", ContentDisposition(fileName))
+	return fmt.Sprintf("https://mock-bucket.s3.amazonaws.com/%s?%s", key, query.Encode()), nil
+}
+
+// PresignUpload returns a mock upload URL; nothing can actually be uploaded to it,
+// so tests store the file with Upload instead
+func (m *MockUploader) PresignUpload(ctx context.Context, key, contentType string, size int64, checksum string, expiration time.Duration) (*PresignedUpload, error) {
+	query := url.Values{}
+	query.Set("expires", fmt.Sprint(time.Now().Add(expiration).Unix()))
+	return &PresignedUpload{
+		URL:    fmt.Sprintf("https://mock-bucket.s3.amazonaws.com/%s?%s", key, query.Encode()),
+		Method: http.MethodPut,
+		Headers: map[string]string{
+			"Content-Type":          contentType,
+			"X-Amz-Checksum-Sha256": checksum,
+		},
+	}, nil
+}
+
+// Stat describes a file in mock storage
+func (m *MockUploader) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	m.mu.RLock()
+	data, exists := m.files[key]
+	contentType := m.contentTypes[key]
+	m.mu.RUnlock()
+	if !exists {
+		return nil, ErrObjectNotFound
+	}
+	sum := sha256.Sum256(data)
+	return &ObjectInfo{
+		Size:        int64(len(data)),
+		ContentType: contentType,
+		SHA256:      hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// Open reads a file from mock storage
+func (m *MockUploader) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	data, exists := m.files[key]
+	m.mu.RUnlock()
+	if !exists {
+		return nil, ErrObjectNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// List lists the files in mock storage. fn is called without the lock held, so it
+// may change the storage it is listing.
+func (m *MockUploader) List(ctx context.Context, prefix string, fn func(ObjectSummary) error) error {
+	m.mu.RLock()
+	var objects []ObjectSummary
+	for key, data := range m.files {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, ObjectSummary{Key: key, Size: int64(len(data)), LastModified: m.modified[key]})
+		}
+	}
+	m.mu.RUnlock()
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+	for _, object := range objects {
+		if err := fn(object); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Delete removes file from mock storage
 func (m *MockUploader) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if _, exists := m.files[key]; !exists {
 		return fmt.Errorf("file not found: %s", key)
 	}
 	delete(m.files, key)
+	delete(m.contentTypes, key)
+	delete(m.modified, key)
 	slog.Debug("Mock delete successful", "key", key)
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,384 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestParseServerSideEncryption(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    types.ServerSideEncryption
+		wantErr bool
+	}{
+		{value: "", want: ""},
+		{value: "none", want: ""},
+		{value: "AES256", want: types.ServerSideEncryptionAes256},
+		{value: "aws:kms", want: types.ServerSideEncryptionAwsKms},
+		{value: "aes256", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseServerSideEncryption(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseServerSideEncryption(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseServerSideEncryption(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeS3 is just enough of the S3 API to record single and multipart uploads
+type fakeS3 struct {
+	mu      sync.Mutex
+	puts    int
+	parts   map[int][]byte
+	objects map[string][]byte
+	aborted bool
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.parts = make(map[int][]byte)
+		fmt.Fprint(w, `<InitiateMultipartUploadResult><Bucket>notes</Bucket><Key>k</Key><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>`)
+	case r.Method == http.MethodPut && query.Has("partNumber"):
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		f.parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, number))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		numbers := make([]int, 0, len(f.parts))
+		for number := range f.parts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		var object []byte
+		for _, number := range numbers {
+			object = append(object, f.parts[number]...)
+		}
+		f.objects[r.URL.Path] = object
+		fmt.Fprint(w, `<CompleteMultipartUploadResult><ETag>"object"</ETag></CompleteMultipartUploadResult>`)
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		f.aborted = true
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodHead:
+		object, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		sum := sha256.Sum256(object)
+		w.Header().Set("Content-Length", strconv.Itoa(len(object)))
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("X-Amz-Checksum-Sha256", base64.StdEncoding.EncodeToString(sum[:]))
+	case r.Method == http.MethodGet && query.Get("list-type") == "2":
+		// Objects are recorded by path, /bucket/key
+		bucket := strings.TrimSuffix(r.URL.Path, "/") + "/"
+		var keys []string
+		for path := range f.objects {
+			key := strings.TrimPrefix(path, bucket)
+			if key != path && strings.HasPrefix(key, query.Get("prefix")) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		fmt.Fprint(w, `<ListBucketResult><Name>notes</Name><IsTruncated>false</IsTruncated>`)
+		for _, key := range keys {
+			fmt.Fprintf(w, `<Contents><Key>%s</Key><Size>%d</Size><LastModified>2026-01-02T03:04:05.000Z</LastModified></Contents>`, key, len(f.objects[bucket+key]))
+		}
+		fmt.Fprint(w, `</ListBucketResult>`)
+	case r.Method == http.MethodGet:
+		object, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			return
+		}
+		w.Write(object)
+	case r.Method == http.MethodPut:
+		f.puts++
+		f.objects[r.URL.Path] = body
+		w.Header().Set("ETag", `"object"`)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+// newFakeS3Uploader returns an S3Uploader talking to a fakeS3 server
+func newFakeS3Uploader(t *testing.T) (*fakeS3, *S3Uploader) {
+	t.Helper()
+	fake := &fakeS3{objects: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	uploader, err := NewS3Uploader(context.Background(), S3Config{
+		Bucket:          "notes",
+		Region:          "us-east-1",
+		Endpoint:        server.URL,
+		UsePathStyle:    true,
+		AccessKeyID:     "test",
+		SecretAccessKey: "test",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Uploader() error = %v", err)
+	}
+	return fake, uploader
+}
+
+func TestS3Uploader_Upload(t *testing.T) {
+	tests := []struct {
+		name      string
+		length    int
+		size      int64
+		wantParts int
+	}{
+		{name: "known size", length: 1024, size: 1024},
+		{name: "unknown size fitting one part", length: partSize - 1, size: -1},
+		{name: "unknown size", length: 2*partSize + 1024, size: -1, wantParts: 3},
+		{name: "known size over one part", length: partSize + 1, size: partSize + 1, wantParts: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, uploader := newFakeS3Uploader(t)
+
+			data := bytes.Repeat([]byte("0123456789abcdef"), tt.length/16+1)[:tt.length]
+			// Hide the reader's type so the uploader can't learn the size from it
+			body := io.MultiReader(bytes.NewReader(data))
+			if err := uploader.Upload(context.Background(), "notes/u/n/a.pdf", body, "application/pdf", tt.size); err != nil {
+				t.Fatalf("Upload() error = %v", err)
+			}
+
+			if !bytes.Equal(fake.objects["/notes/notes/u/n/a.pdf"], data) {
+				t.Errorf("Upload() stored %d bytes, want the %d uploaded", len(fake.objects["/notes/notes/u/n/a.pdf"]), len(data))
+			}
+			if tt.wantParts == 0 && fake.puts != 1 {
+				t.Errorf("Upload() made %d single uploads, want 1", fake.puts)
+			}
+			if len(fake.parts) != tt.wantParts {
+				t.Errorf("Upload() sent %d parts, want %d", len(fake.parts), tt.wantParts)
+			}
+		})
+	}
+}
+
+func TestS3Uploader_UploadAbortsOnReadError(t *testing.T) {
+	fake, uploader := newFakeS3Uploader(t)
+
+	readErr := fmt.Errorf("client went away")
+	body := io.MultiReader(bytes.NewReader(make([]byte, partSize+10)), &failingReader{err: readErr})
+	if err := uploader.Upload(context.Background(), "notes/u/n/a.pdf", body, "application/pdf", -1); err == nil {
+		t.Fatal("Upload() error = nil, want the read error")
+	}
+
+	if !fake.aborted {
+		t.Error("Upload() did not abort the multipart upload")
+	}
+	if len(fake.objects) != 0 {
+		t.Errorf("Upload() stored %d objects, want none", len(fake.objects))
+	}
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestS3Uploader_PresignUpload(t *testing.T) {
+	_, uploader := newFakeS3Uploader(t)
+	sum := sha256.Sum256([]byte("%PDF-1.7"))
+
+	presigned, err := uploader.PresignUpload(context.Background(), "notes/u/n/a.pdf", "application/pdf", 8, hex.EncodeToString(sum[:]), time.Minute)
+	if err != nil {
+		t.Fatalf("PresignUpload() error = %v", err)
+	}
+
+	if presigned.Method != http.MethodPut {
+		t.Errorf("PresignUpload() method = %s, want PUT", presigned.Method)
+	}
+	if !strings.Contains(presigned.URL, "/notes/notes/u/n/a.pdf?") || !strings.Contains(presigned.URL, "X-Amz-Signature=") {
+		t.Errorf("PresignUpload() URL = %s, want a signed URL for the key", presigned.URL)
+	}
+	// The SDK signs the checksum into the query string, leaving only the content type
+	// for the client to send
+	if want := map[string]string{"Content-Type": "application/pdf"}; !reflect.DeepEqual(presigned.Headers, want) {
+		t.Errorf("PresignUpload() headers = %v, want %v", presigned.Headers, want)
+	}
+	u, err := url.Parse(presigned.URL)
+	if err != nil {
+		t.Fatalf("invalid presigned URL %q: %v", presigned.URL, err)
+	}
+	if got, want := u.Query().Get("X-Amz-Checksum-Sha256"), base64.StdEncoding.EncodeToString(sum[:]); got != want {
+		t.Errorf("PresignUpload() signed checksum = %q, want %q", got, want)
+	}
+
+	if _, err := uploader.PresignUpload(context.Background(), "k", "application/pdf", 8, "not hex", time.Minute); err == nil {
+		t.Error("PresignUpload() with an invalid checksum should fail")
+	}
+}
+
+func TestS3Uploader_Stat(t *testing.T) {
+	fake, uploader := newFakeS3Uploader(t)
+	data := []byte("%PDF-1.7")
+	fake.objects["/notes/notes/u/n/a.pdf"] = data
+
+	info, err := uploader.Stat(context.Background(), "notes/u/n/a.pdf")
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	sum := sha256.Sum256(data)
+	want := ObjectInfo{Size: int64(len(data)), ContentType: "application/pdf", SHA256: hex.EncodeToString(sum[:])}
+	if *info != want {
+		t.Errorf("Stat() = %+v, want %+v", *info, want)
+	}
+
+	if _, err := uploader.Stat(context.Background(), "notes/u/n/missing.pdf"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Stat() of a missing object error = %v, want %v", err, ErrObjectNotFound)
+	}
+}
+
+func TestS3Uploader_Open(t *testing.T) {
+	fake, uploader := newFakeS3Uploader(t)
+	data := []byte("%PDF-1.7")
+	fake.objects["/notes/notes/u/n/a.pdf"] = data
+
+	body, err := uploader.Open(context.Background(), "notes/u/n/a.pdf")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	got, _ := io.ReadAll(body)
+	body.Close()
+	if !bytes.Equal(got, data) {
+		t.Errorf("Open() contents = %q, want %q", got, data)
+	}
+
+	if _, err := uploader.Open(context.Background(), "notes/u/n/missing.pdf"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Open() of a missing object error = %v, want %v", err, ErrObjectNotFound)
+	}
+}
+
+func TestS3Uploader_List(t *testing.T) {
+	fake, uploader := newFakeS3Uploader(t)
+	fake.objects["/notes/notes/u/n/b.pdf"] = []byte("%PDF-1.7 b")
+	fake.objects["/notes/notes/u/n/a.pdf"] = []byte("%PDF-1.7")
+	fake.objects["/notes/other/c.pdf"] = []byte("%PDF-1.7")
+
+	var got []ObjectSummary
+	err := uploader.List(context.Background(), "notes/", func(object ObjectSummary) error {
+		got = append(got, object)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	want := []ObjectSummary{
+		{Key: "notes/u/n/a.pdf", Size: 8, LastModified: modified},
+		{Key: "notes/u/n/b.pdf", Size: 10, LastModified: modified},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %+v, want %+v", got, want)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = uploader.List(context.Background(), "notes/", func(ObjectSummary) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("List() with failing callback = %v after %d calls, want %v after 1", err, calls, stop)
+	}
+}
+
+func TestGenerateFileKey(t *testing.T) {
+	tests := []struct {
+		fileName string
+		want     string
+	}{
+		{fileName: "lecture 1.pdf", want: "notes/u/n/lecture 1.pdf"},
+		{fileName: "../../v/lecture.pdf", want: "notes/u/n/.._.._v_lecture.pdf"},
+		{fileName: `..\lecture.pdf`, want: "notes/u/n/.._lecture.pdf"},
+		{fileName: "lecture\r\n.pdf", want: "notes/u/n/lecture__.pdf"},
+		{fileName: "..", want: "notes/u/n/_"},
+		{fileName: "", want: "notes/u/n/_"},
+	}
+
+	for _, tt := range tests {
+		if got := GenerateFileKey("u", "n", tt.fileName); got != tt.want {
+			t.Errorf("GenerateFileKey(%q) = %q, want %q", tt.fileName, got, tt.want)
+		}
+	}
+	if got, want := GenerateVersionFileKey("u", "n", "v", "../x.pdf"), "notes/u/n/versions/v/.._x.pdf"; got != want {
+		t.Errorf("GenerateVersionFileKey() = %q, want %q", got, want)
+	}
+}
+
+// TestMockUploader_Concurrent exercises the mock the way handlers and background jobs
+// do at once; run it with -race
+func TestMockUploader_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockUploader()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		key := fmt.Sprintf("notes/u/%d/a.pdf", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if err := mock.Upload(ctx, key, strings.NewReader("%PDF-1.7"), "application/pdf", 8); err != nil {
+					t.Errorf("Upload() error = %v", err)
+				}
+				mock.Delete(ctx, key)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				mock.Stat(ctx, key)
+				if r, err := mock.Open(ctx, key); err == nil {
+					io.Copy(io.Discard, r)
+					r.Close()
+				}
+				mock.List(ctx, "notes/", func(ObjectSummary) error { return nil })
+			}
+		}()
+	}
+	wg.Wait()
+}
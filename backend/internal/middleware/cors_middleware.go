@@ -41,7 +41,10 @@ func CORSMiddleware(next http.Handler) http.Handler {
 
 		// Which request headers the browser may send.
 		w.Header().Set("Access-Control-Allow-Headers",
-			"Authorization, Content-Type")
+			"Authorization, Content-Type, If-Match")
+
+		// Which response headers scripts may read; ETag feeds If-Match on updates.
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 		// Tell the browser to include cookies / authorization headers.
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 
@@ -28,7 +30,7 @@ func JWTMiddleware(authService AuthServiceInterface) func(http.Handler) http.Han
 				cookie, err := r.Cookie("jwt")
 				if err != nil {
 					slog.Debug("No JWT found in Authorization header or cookie", "path", r.URL.Path)
-					http.Error(w, "Authentication required", http.StatusUnauthorized)
+					sendError(w, http.StatusUnauthorized, "no_token", "Authentication required")
 					return
 				}
 				tokenString = cookie.Value
@@ -38,8 +40,13 @@ func JWTMiddleware(authService AuthServiceInterface) func(http.Handler) http.Han
 			// Validate JWT
 			claims, err := authService.ValidateJWT(r.Context(), tokenString)
 			if err != nil {
+				if !errors.Is(err, services.ErrUnauthorized) {
+					slog.Error("Failed to validate JWT", "error", err, "path", r.URL.Path)
+					sendError(w, http.StatusInternalServerError, "internal_error", "Failed to validate token")
+					return
+				}
 				slog.Warn("Invalid JWT token", "error", err, "path", r.URL.Path)
-				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+				sendError(w, http.StatusUnauthorized, "invalid_token", "Invalid or expired token")
 				return
 			}
 
@@ -54,6 +61,17 @@ func JWTMiddleware(authService AuthServiceInterface) func(http.Handler) http.Han
 	}
 }
 
+// sendError writes an error in the JSON shape the handlers use for every error response
+func sendError(w http.ResponseWriter, statusCode int, errorCode, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	response := map[string]string{"error": errorCode, "message": message}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Failed to encode error response", "error", err)
+	}
+}
+
 // GetUserFromContext extracts user claims from request context
 func GetUserFromContext(ctx context.Context) (*services.JWTClaims, bool) {
 	user, ok := ctx.Value(userContextKey).(*services.JWTClaims)
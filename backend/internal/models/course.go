@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Course is an entry in the course catalog. Code is the canonical course code,
+// such as "COMP 140".
+type Course struct {
+	Code          string    `json:"code" db:"code"`
+	Subject       string    `json:"subject" db:"subject"`
+	Number        string    `json:"number" db:"number"`
+	Title         string    `json:"title" db:"title"`
+	Term          string    `json:"term,omitempty" db:"term"`
+	CrossListings []string  `json:"cross_listings" db:"cross_listings"`
+	CreatedAt     time.Time `json:"-" db:"created_at"`
+	UpdatedAt     time.Time `json:"-" db:"updated_at"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import "errors"
+
+// Error kinds. Every domain error belongs to one, so callers can handle a whole class
+// of failures with errors.Is without knowing each sentinel.
+var (
+	// ErrNotFound means the requested resource does not exist or is hidden from the caller
+	ErrNotFound = errors.New("not found")
+	// ErrForbidden means the caller is known but not allowed to perform the action
+	ErrForbidden = errors.New("you do not have permission to perform this action")
+	// ErrUnauthorized means the caller's credentials are missing, invalid or expired
+	ErrUnauthorized = errors.New("authentication required")
+	// ErrValidation means the request itself is invalid
+	ErrValidation = errors.New("invalid request")
+	// ErrConflict means the request conflicts with the current state of a resource
+	ErrConflict = errors.New("conflict")
+	// ErrPreconditionFailed means a conditional request's precondition no longer holds
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrUpstream means a service we depend on, such as Google or file storage, failed
+	ErrUpstream = errors.New("upstream service failed")
+)
+
+// Error is a domain error: a kind, a stable code clients can act on and a message that
+// is safe to show them. Field names the request field at fault, if any, and Err is the
+// underlying cause, which is logged but never shown.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Field   string
+	Err     error
+}
+
+// NewError returns a domain error of the given kind, for use as a sentinel
+func NewError(kind error, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// Invalid returns a validation error for field, or for the request as a whole when
+// field is empty
+func Invalid(field, message string) *Error {
+	return &Error{Kind: ErrValidation, Code: "invalid_request", Message: message, Field: field}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the error's kind and cause, so errors.Is matches either
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
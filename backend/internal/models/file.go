@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileReference is a database row that points at a stored file: a revision of a
+// note, or a pending upload whose file may not have arrived yet
+type FileReference struct {
+	Key    string    `json:"key"`
+	NoteID uuid.UUID `json:"note_id"`
+	// Version is the revision of the note the file belongs to; zero for a pending upload
+	Version int `json:"version,omitempty"`
+	// Current is set for the note's current revision
+	Current   bool      `json:"current,omitempty"`
+	Pending   bool      `json:"pending,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
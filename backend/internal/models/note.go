@@ -6,34 +6,200 @@ import (
 	"github.com/google/uuid"
 )
 
+// Note visibilities. Private notes are visible to the owner and explicit shares, and
+// rice notes to any Rice user. There is no course-only level until enrollment data
+// exists to decide who takes a course.
+const (
+	VisibilityPrivate = "private"
+	VisibilityRice    = "rice"
+)
+
+// Note statuses. A note is uploading while its file is sent straight to storage,
+// processing until its current file has been inspected and indexed, and then ready.
+// Processing leaves files it can't use failed, and suspicious ones quarantined.
+// Only ready notes are listed by default or shown to anyone but their owner.
+const (
+	NoteStatusUploading   = "uploading"
+	NoteStatusProcessing  = "processing"
+	NoteStatusReady       = "ready"
+	NoteStatusFailed      = "failed"
+	NoteStatusQuarantined = "quarantined"
+)
+
+// NoteStatuses lists every note status
+var NoteStatuses = []string{NoteStatusUploading, NoteStatusProcessing, NoteStatusReady, NoteStatusFailed, NoteStatusQuarantined}
+
 // Note represents a PDF note uploaded by a user
 type Note struct {
+	ID           uuid.UUID `json:"id" db:"id"`
+	UserID       uuid.UUID `json:"-" db:"user_id"`
+	UserEmail    string    `json:"user_email" db:"user_email"`
+	Title        string    `json:"title" db:"title"`
+	CourseID     string    `json:"course_id" db:"course_id"`
+	FileName     string    `json:"file_name" db:"file_name"`
+	FilePath     string    `json:"file_path" db:"file_path"`
+	FileSize     int64     `json:"file_size" db:"file_size"`
+	SHA256       string    `json:"sha256,omitempty" db:"sha256"`
+	ContentType  string    `json:"content_type" db:"content_type"`
+	PageCount    int       `json:"page_count,omitempty" db:"page_count"`
+	PDFVersion   string    `json:"pdf_version,omitempty" db:"pdf_version"`
+	ContentText  string    `json:"-" db:"content_text"`
+	Visibility   string    `json:"visibility" db:"visibility"`
+	// AuthorName is the owner's current display name, filled in by course listings
+	AuthorName   string    `json:"author_name,omitempty" db:"-"`
+	Status       string    `json:"status" db:"status"`
+	StatusReason string    `json:"status_reason,omitempty" db:"status_reason"`
+	Version      int       `json:"version" db:"current_version"`
+	UploadedAt   time.Time `json:"uploaded_at" db:"uploaded_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	// Tags are the requesting user's tags on the note, filled in by listings
+	Tags []string `json:"tags,omitempty" db:"-"`
+}
+
+// NoteVersion is one revision of a note's file. The note's current file is always
+// its highest-numbered revision.
+type NoteVersion struct {
 	ID          uuid.UUID `json:"id" db:"id"`
-	UserEmail   string    `json:"user_email" db:"user_email"`
-	Title       string    `json:"title" db:"title"`
-	CourseID    string    `json:"course_id" db:"course_id"`
+	NoteID      uuid.UUID `json:"note_id" db:"note_id"`
+	Version     int       `json:"version" db:"version"`
 	FileName    string    `json:"file_name" db:"file_name"`
-	FilePath    string    `json:"file_path" db:"file_path"`
+	FilePath    string    `json:"-" db:"file_path"`
 	FileSize    int64     `json:"file_size" db:"file_size"`
+	SHA256      string    `json:"sha256,omitempty" db:"sha256"`
 	ContentType string    `json:"content_type" db:"content_type"`
-	UploadedAt  time.Time `json:"uploaded_at" db:"uploaded_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	PageCount   int       `json:"page_count,omitempty" db:"page_count"`
+	PDFVersion  string    `json:"pdf_version,omitempty" db:"pdf_version"`
+	ContentText string    `json:"-" db:"content_text"`
+	UploadedBy  string    `json:"uploaded_by,omitempty" db:"uploaded_by"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 }
 
 // CreateNoteRequest represents the request payload for creating a new note
 type CreateNoteRequest struct {
-	Title    string `json:"title" form:"title"`
-	CourseID string `json:"course_id" form:"course_id"`
+	Title      string `json:"title" form:"title"`
+	CourseID   string `json:"course_id" form:"course_id"`
+	Visibility string `json:"visibility" form:"visibility"`
 	// File will be handled separately in multipart form
 }
 
+// UpdateNoteRequest represents a partial update to a note's metadata; nil fields are left unchanged
+type UpdateNoteRequest struct {
+	Title      *string `json:"title"`
+	CourseID   *string `json:"course_id"`
+	Visibility *string `json:"visibility"`
+}
+
+// NoteFilter narrows a listing of a user's notes. Empty fields match every note.
+type NoteFilter struct {
+	CourseID string
+	Tags     []string
+	// MatchAllTags requires a note to carry every tag; otherwise any one will do
+	MatchAllTags bool
+	// Statuses limits the listing to notes in these statuses; empty means ready only
+	Statuses []string
+}
+
+// NoteListOptions orders and pages a listing of a user's notes. Sort is one of
+// uploaded_at, title, file_size or course_id, and Order is asc or desc. Cursor is the
+// NextCursor of the previous page.
+type NoteListOptions struct {
+	Sort   string
+	Order  string
+	Cursor string
+	Limit  int
+}
+
+// NotesPage is one page of a user's notes. Total counts every note matching the
+// filter, and NextCursor is empty on the last page.
+type NotesPage struct {
+	Items      []*Note `json:"items"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	Total      int     `json:"total"`
+}
+
+// AddTagsRequest represents tags to add to a note
+type AddTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// TagCount is one of a user's tags and how many of their notes carry it
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
 // NoteResponse represents the response when returning note information
 type NoteResponse struct {
-	ID          uuid.UUID `json:"id"`
-	Title       string    `json:"title"`
-	CourseID    string    `json:"course_id"`
-	FileName    string    `json:"file_name"`
-	FileSize    int64     `json:"file_size"`
-	ContentType string    `json:"content_type"`
-	UploadedAt  time.Time `json:"uploaded_at"`
+	ID           uuid.UUID `json:"id"`
+	Title        string    `json:"title"`
+	CourseID     string    `json:"course_id"`
+	FileName     string    `json:"file_name"`
+	FileSize     int64     `json:"file_size"`
+	SHA256       string    `json:"sha256"`
+	ContentType  string    `json:"content_type"`
+	PageCount    int       `json:"page_count,omitempty"`
+	PDFVersion   string    `json:"pdf_version,omitempty"`
+	Visibility   string    `json:"visibility"`
+	Status       string    `json:"status"`
+	StatusReason string    `json:"status_reason,omitempty"`
+	UploadedAt   time.Time `json:"uploaded_at"`
+}
+
+// NoteStatus reports where a note is in processing
+type NoteStatus struct {
+	ID           uuid.UUID `json:"id"`
+	Status       string    `json:"status"`
+	StatusReason string    `json:"status_reason,omitempty"`
+}
+
+// CourseNote is a published note as listed in a course's library. It names the
+// author by display name and never exposes their email.
+type CourseNote struct {
+	ID         uuid.UUID `json:"id"`
+	Title      string    `json:"title"`
+	CourseID   string    `json:"course_id"`
+	FileName   string    `json:"file_name"`
+	FileSize   int64     `json:"file_size"`
+	PageCount  int       `json:"page_count,omitempty"`
+	Visibility string    `json:"visibility"`
+	AuthorName string    `json:"author_name"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+// CourseNotesPage is one page of a course's library. NextCursor is empty on the last page.
+type CourseNotesPage struct {
+	Items      []*CourseNote `json:"items"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// DownloadResponse represents a short-lived link for downloading a note's file
+type DownloadResponse struct {
+	URL       string    `json:"url"`
+	FileName  string    `json:"file_name"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SearchResult is a note matching a full-text search, with its relevance and a
+// highlighted excerpt. Snippet is HTML-escaped, with matches wrapped in <mark>.
+type SearchResult struct {
+	*Note
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// JobProcessNote is the background job that inspects a revision of a note's file
+// once it is stored and indexes its text for search
+const JobProcessNote = "process_note"
+
+// ProcessNoteJob is the payload of a JobProcessNote job
+type ProcessNoteJob struct {
+	NoteID  uuid.UUID `json:"note_id"`
+	Version int       `json:"version"`
+}
+
+// NoteContent is what processing learns about a revision of a note's file
+type NoteContent struct {
+	PageCount  int
+	PDFVersion string
+	Text       string
 }
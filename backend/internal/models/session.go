@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is one refresh token. Every token issued by rotation from the same login
+// shares a FamilyID, so a stolen token can be cut off together with its successors.
+type Session struct {
+	ID          uuid.UUID  `db:"id"`
+	FamilyID    uuid.UUID  `db:"family_id"`
+	UserID      uuid.UUID  `db:"user_id"`
+	UserEmail   string     `db:"user_email"`
+	UserName    string     `db:"user_name"`
+	UserPicture string     `db:"user_picture"`
+	TokenHash   string     `db:"token_hash"`
+	CreatedAt   time.Time  `db:"created_at"`
+	ExpiresAt   time.Time  `db:"expires_at"`
+	RotatedAt   *time.Time `db:"rotated_at"`
+	RevokedAt   *time.Time `db:"revoked_at"`
+}
+
+// AuthCode is a one-time code the frontend trades for an access token after login.
+// It is bound to the client that started the login by a PKCE challenge.
+type AuthCode struct {
+	CodeHash        string    `db:"code_hash"`
+	SessionID       uuid.UUID `db:"session_id"`
+	UserID          uuid.UUID `db:"user_id"`
+	UserEmail       string    `db:"user_email"`
+	UserName        string    `db:"user_name"`
+	UserPicture     string    `db:"user_picture"`
+	ClientChallenge string    `db:"client_challenge"`
+	CreatedAt       time.Time `db:"created_at"`
+	ExpiresAt       time.Time `db:"expires_at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Share roles, from least to most privileged
+const (
+	ShareRoleViewer = "viewer"
+	ShareRoleEditor = "editor"
+)
+
+// NoteShare grants another user access to a note. GranteeEmail is the grantee's
+// current email; the share itself follows GranteeID.
+type NoteShare struct {
+	NoteID       uuid.UUID `json:"note_id" db:"note_id"`
+	GranteeID    uuid.UUID `json:"-" db:"grantee_user_id"`
+	GranteeEmail string    `json:"email" db:"email"`
+	Role         string    `json:"role" db:"role"`
+	GrantedBy    string    `json:"granted_by" db:"granted_by"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ShareNoteRequest represents the request payload for sharing a note
+type ShareNoteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// SharedNote is a note another user has shared with the requesting user
+type SharedNote struct {
+	*Note
+	Role     string    `json:"role"`
+	SharedAt time.Time `json:"shared_at"`
+}
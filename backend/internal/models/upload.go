@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PendingUpload is a note whose file the client is uploading straight to storage.
+// Completing the upload creates the note, with the same ID; an upload that is never
+// completed expires. ExpiresAt allows a grace period past the upload URL's expiry for
+// the client to complete an upload that finished at the last moment.
+type PendingUpload struct {
+	ID         uuid.UUID `db:"id"`
+	UserID     uuid.UUID `db:"user_id"`
+	UserEmail  string    `db:"user_email"`
+	Title      string    `db:"title"`
+	CourseID   string    `db:"course_id"`
+	Visibility string    `db:"visibility"`
+	FileName   string    `db:"file_name"`
+	FilePath   string    `db:"file_path"`
+	FileSize   int64     `db:"file_size"`
+	SHA256     string    `db:"sha256"`
+	CreatedAt  time.Time `db:"created_at"`
+	ExpiresAt  time.Time `db:"expires_at"`
+}
+
+// CreateUploadRequest describes a note and the file the client is about to upload
+// for it. SHA256 is the hex-encoded checksum of the file.
+type CreateUploadRequest struct {
+	Title      string `json:"title"`
+	CourseID   string `json:"course_id"`
+	Visibility string `json:"visibility"`
+	FileName   string `json:"file_name"`
+	FileSize   int64  `json:"file_size"`
+	SHA256     string `json:"sha256"`
+}
+
+// UploadResponse tells the client where to upload a note's file. The request must
+// use Method and carry Headers; it is accepted until ExpiresAt. The upload must then
+// be completed by CompleteBy.
+type UploadResponse struct {
+	UploadID   uuid.UUID         `json:"upload_id"`
+	URL        string            `json:"url"`
+	Method     string            `json:"method"`
+	Headers    map[string]string `json:"headers"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+	CompleteBy time.Time         `json:"complete_by"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// User is someone who has signed in with Google
+type User struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	GoogleSub   string     `json:"-" db:"google_sub"`
+	Email       string     `json:"email" db:"email"`
+	Name        string     `json:"name" db:"name"`
+	Picture     string     `json:"picture" db:"picture"`
+	DisplayName *string    `json:"display_name" db:"display_name"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty" db:"last_login_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// UpdateUserRequest represents the profile fields a user may change. Omitted fields
+// are left as they are; an empty display name clears it.
+type UpdateUserRequest struct {
+	DisplayName *string `json:"display_name"`
+}
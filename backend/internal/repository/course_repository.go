@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrCourseNotFound is returned when a course code is not in the catalog
+var ErrCourseNotFound = models.NewError(models.ErrNotFound, "course_not_found", "course not found")
+
+// courseColumns is the column list for courses queries; keep it in sync with scanCourse
+const courseColumns = `code, subject, number, title, term, cross_listings, created_at, updated_at`
+
+// scanCourse scans a row selected with courseColumns
+func scanCourse(row pgx.Row) (*models.Course, error) {
+	course := &models.Course{}
+	err := row.Scan(
+		&course.Code,
+		&course.Subject,
+		&course.Number,
+		&course.Title,
+		&course.Term,
+		&course.CrossListings,
+		&course.CreatedAt,
+		&course.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return course, nil
+}
+
+// CourseRepository defines the interface for course catalog operations. Codes passed
+// in must already be canonical.
+type CourseRepository interface {
+	GetCourse(ctx context.Context, code string) (*models.Course, error)
+	SearchCourses(ctx context.Context, query string, limit int) ([]*models.Course, error)
+	UpsertCourses(ctx context.Context, courses []*models.Course) error
+}
+
+// PostgresCourseRepository implements CourseRepository using PostgreSQL
+type PostgresCourseRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewPostgresCourseRepository creates a new PostgreSQL-based course repository
+func NewPostgresCourseRepository(db *pgxpool.Pool) *PostgresCourseRepository {
+	return &PostgresCourseRepository{
+		db: db,
+	}
+}
+
+// GetCourse looks a course up by its code or by one of its cross-listings, so
+// "ELEC 220" finds the course listed as "COMP 220"
+func (r *PostgresCourseRepository) GetCourse(ctx context.Context, code string) (*models.Course, error) {
+	query := `
+		SELECT ` + courseColumns + `
+		FROM courses
+		WHERE code = $1 OR cross_listings @> ARRAY[$1]
+		ORDER BY code = $1 DESC
+		LIMIT 1`
+
+	course, err := scanCourse(r.db.QueryRow(ctx, query, code))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrCourseNotFound
+		}
+		slog.Error("Failed to get course", "error", err, "code", code)
+		return nil, fmt.Errorf("failed to get course: %w", err)
+	}
+
+	return course, nil
+}
+
+// SearchCourses finds courses for autocomplete. query matches the start of a code,
+// ignoring the space ("comp1" finds "COMP 140"), or any part of the title. Code
+// matches come first.
+func (r *PostgresCourseRepository) SearchCourses(ctx context.Context, query string, limit int) ([]*models.Course, error) {
+	sqlQuery := `
+		SELECT ` + courseColumns + `
+		FROM courses
+		WHERE REPLACE(code, ' ', '') LIKE $1 || '%'
+		   OR title ILIKE '%' || $2 || '%'
+		ORDER BY REPLACE(code, ' ', '') LIKE $1 || '%' DESC, code
+		LIMIT $3`
+
+	// The code pattern is compared without spaces; LIKE wildcards in either pattern
+	// are matched literally
+	codePrefix := escapeLike(compactCode(query))
+	rows, err := r.db.Query(ctx, sqlQuery, codePrefix, escapeLike(query), limit)
+	if err != nil {
+		slog.Error("Failed to search courses", "error", err, "query", query)
+		return nil, fmt.Errorf("failed to search courses: %w", err)
+	}
+	defer rows.Close()
+
+	var courses []*models.Course
+	for rows.Next() {
+		course, err := scanCourse(rows)
+		if err != nil {
+			slog.Error("Failed to scan course", "error", err)
+			return nil, fmt.Errorf("failed to scan course: %w", err)
+		}
+		courses = append(courses, course)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("Error iterating courses", "error", err)
+		return nil, fmt.Errorf("error iterating courses: %w", err)
+	}
+
+	return courses, nil
+}
+
+// UpsertCourses adds courses to the catalog or replaces the title, term and
+// cross-listings of ones already there, all in one transaction
+func (r *PostgresCourseRepository) UpsertCourses(ctx context.Context, courses []*models.Course) error {
+	query := `
+		INSERT INTO courses (code, subject, number, title, term, cross_listings)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (code) DO UPDATE
+		SET title = EXCLUDED.title,
+			term = EXCLUDED.term,
+			cross_listings = EXCLUDED.cross_listings`
+
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		batch := &pgx.Batch{}
+		for _, course := range courses {
+			crossListings := course.CrossListings
+			if crossListings == nil {
+				crossListings = []string{}
+			}
+			batch.Queue(query, course.Code, course.Subject, course.Number, course.Title, course.Term, crossListings)
+		}
+		return tx.SendBatch(ctx, batch).Close()
+	})
+	if err != nil {
+		slog.Error("Failed to upsert courses", "error", err, "count", len(courses))
+		return fmt.Errorf("failed to upsert courses: %w", err)
+	}
+
+	slog.Info("Courses upserted", "count", len(courses))
+	return nil
+}
+
+// likeEscaper makes LIKE's wildcards match literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// compactCode uppercases a code and drops the separators users type between subject
+// and number
+func compactCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(code))
+}
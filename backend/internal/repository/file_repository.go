@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// FileRepository defines the interface for the rows that refer to stored files,
+// across notes, their revisions and pending uploads
+type FileRepository interface {
+	ListFileReferences(ctx context.Context) ([]*models.FileReference, error)
+	DeleteFileReference(ctx context.Context, ref *models.FileReference) error
+}
+
+// PostgresFileRepository implements FileRepository using PostgreSQL
+type PostgresFileRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewPostgresFileRepository creates a new PostgreSQL-based file repository
+func NewPostgresFileRepository(db *pgxpool.Pool) *PostgresFileRepository {
+	return &PostgresFileRepository{
+		db: db,
+	}
+}
+
+// ListFileReferences lists every row that refers to a stored file: each revision of
+// every note and each pending upload, expired or not. Restored revisions share their
+// file with the revision they restored, so a key can appear more than once.
+func (r *PostgresFileRepository) ListFileReferences(ctx context.Context) ([]*models.FileReference, error) {
+	query := `
+		SELECT v.file_path, v.note_id, v.version, v.version = n.current_version, FALSE, v.created_at
+		FROM note_versions v
+		JOIN notes n ON n.id = v.note_id
+		UNION ALL
+		SELECT file_path, id, 0, FALSE, TRUE, created_at
+		FROM note_uploads
+		ORDER BY 1`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		slog.Error("Failed to list file references", "error", err)
+		return nil, fmt.Errorf("failed to list file references: %w", err)
+	}
+	defer rows.Close()
+
+	var refs []*models.FileReference
+	for rows.Next() {
+		ref := &models.FileReference{}
+		if err := rows.Scan(&ref.Key, &ref.NoteID, &ref.Version, &ref.Current, &ref.Pending, &ref.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan file reference: %w", err)
+		}
+		refs = append(refs, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list file references: %w", err)
+	}
+
+	return refs, nil
+}
+
+// DeleteFileReference deletes the note revision behind ref. A note cannot exist
+// without its current file, so for the current revision the whole note is deleted;
+// older revisions are deleted on their own. Either delete is skipped if a newer
+// revision has been uploaded since ref was listed. Pending uploads expire by
+// themselves and are never deleted here.
+func (r *PostgresFileRepository) DeleteFileReference(ctx context.Context, ref *models.FileReference) error {
+	query := `
+		DELETE FROM note_versions v
+		USING notes n
+		WHERE n.id = v.note_id AND v.note_id = $1 AND v.file_path = $2 AND v.version = $3
+			AND v.version <> n.current_version`
+	if ref.Current {
+		query = `DELETE FROM notes WHERE id = $1 AND file_path = $2 AND current_version = $3`
+	}
+
+	result, err := r.db.Exec(ctx, query, ref.NoteID, ref.Key, ref.Version)
+	if err != nil {
+		slog.Error("Failed to delete file reference", "error", err, "noteID", ref.NoteID, "version", ref.Version)
+		return fmt.Errorf("failed to delete file reference: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNoteNotFound
+	}
+
+	slog.Info("File reference deleted", "noteID", ref.NoteID, "version", ref.Version, "key", ref.Key)
+	return nil
+}
@@ -3,22 +3,156 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"html"
 	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/angel-romero-f/rice-notes/internal/infra/jobs"
 	"github.com/angel-romero-f/rice-notes/internal/models"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// noteColumns is the column list shared by every query that returns notes; keep it
+// in sync with scanNote
+const noteColumns = `id, user_id, user_email, title, course_id, file_name, file_path, file_size,
+			   COALESCE(sha256, ''), content_type, COALESCE(page_count, 0), COALESCE(pdf_version, ''), visibility,
+			   status, status_reason, current_version, uploaded_at, updated_at`
+
+var (
+	// ErrNoteNotFound is returned when a note does not exist
+	ErrNoteNotFound = models.NewError(models.ErrNotFound, "note_not_found", "note not found")
+	// ErrNoteModified is returned when a conditional update finds the note has changed
+	ErrNoteModified = models.NewError(models.ErrPreconditionFailed, "precondition_failed", "note has been modified since it was retrieved")
+	// ErrVersionNotFound is returned when a note has no revision with the requested number
+	ErrVersionNotFound = models.NewError(models.ErrNotFound, "version_not_found", "note version not found")
+	// ErrTagNotFound is returned when removing a tag the note does not carry
+	ErrTagNotFound = models.NewError(models.ErrNotFound, "tag_not_found", "tag not found")
+)
+
+// versionColumns is the column list for note_versions queries; keep it in sync with scanVersion
+const versionColumns = `id, note_id, version, file_name, file_path, file_size, COALESCE(sha256, ''), content_type,
+			   COALESCE(page_count, 0), COALESCE(pdf_version, ''), content_text, uploaded_by, created_at`
+
+// scanVersion scans a row selected with versionColumns
+func scanVersion(row pgx.Row) (*models.NoteVersion, error) {
+	v := &models.NoteVersion{}
+	err := row.Scan(
+		&v.ID,
+		&v.NoteID,
+		&v.Version,
+		&v.FileName,
+		&v.FilePath,
+		&v.FileSize,
+		&v.SHA256,
+		&v.ContentType,
+		&v.PageCount,
+		&v.PDFVersion,
+		&v.ContentText,
+		&v.UploadedBy,
+		&v.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// scanNote scans a row selected with noteColumns; extra receives any columns
+// selected after them
+func scanNote(row pgx.Row, extra ...any) (*models.Note, error) {
+	note := &models.Note{}
+	dest := []any{
+		&note.ID,
+		&note.UserID,
+		&note.UserEmail,
+		&note.Title,
+		&note.CourseID,
+		&note.FileName,
+		&note.FilePath,
+		&note.FileSize,
+		&note.SHA256,
+		&note.ContentType,
+		&note.PageCount,
+		&note.PDFVersion,
+		&note.Visibility,
+		&note.Status,
+		&note.StatusReason,
+		&note.Version,
+		&note.UploadedAt,
+		&note.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
 // NoteRepository defines the interface for note database operations
 type NoteRepository interface {
 	CreateNote(ctx context.Context, note *models.Note) error
 	GetNoteByID(ctx context.Context, id uuid.UUID) (*models.Note, error)
-	GetNotesByUser(ctx context.Context, userEmail string, limit, offset int) ([]*models.Note, error)
-	GetNotesByCourse(ctx context.Context, userEmail, courseID string, limit, offset int) ([]*models.Note, error)
-	DeleteNote(ctx context.Context, id uuid.UUID, userEmail string) error
+	ListNotes(ctx context.Context, userID uuid.UUID, query *NoteListQuery) ([]*models.Note, error)
+	CountNotes(ctx context.Context, userID uuid.UUID, filter *models.NoteFilter) (int, error)
+	SearchNotes(ctx context.Context, userID uuid.UUID, query, courseID string, limit, offset int) ([]*models.SearchResult, error)
+	GetPublishedNotesByCourse(ctx context.Context, courseID string, viewerID uuid.UUID, after *Cursor, limit int) ([]*models.Note, error)
+	UpdateNote(ctx context.Context, id uuid.UUID, update *models.UpdateNoteRequest, ifUnmodifiedAt *time.Time) (*models.Note, error)
+	AddNoteVersion(ctx context.Context, version *models.NoteVersion) (*models.Note, error)
+	GetNoteVersions(ctx context.Context, noteID uuid.UUID) ([]*models.NoteVersion, error)
+	GetNoteVersion(ctx context.Context, noteID uuid.UUID, version int) (*models.NoteVersion, error)
+	SetNoteContent(ctx context.Context, noteID uuid.UUID, version int, content *models.NoteContent) error
+	SetNoteStatus(ctx context.Context, noteID uuid.UUID, version int, status, reason string) error
+	DeleteNote(ctx context.Context, id, userID uuid.UUID) error
+	AddNoteTags(ctx context.Context, noteID, userID uuid.UUID, tags []string) ([]string, error)
+	RemoveNoteTag(ctx context.Context, noteID, userID uuid.UUID, tag string) error
+	GetNoteTags(ctx context.Context, userID uuid.UUID, noteIDs []uuid.UUID) (map[uuid.UUID][]string, error)
+	GetUserTags(ctx context.Context, userID uuid.UUID) ([]*models.TagCount, error)
+}
+
+// Cursor is a keyset pagination position: the sort key of the last row already returned
+type Cursor struct {
+	UploadedAt time.Time
+	ID         uuid.UUID
+}
+
+// NoteSort is a column note listings can be ordered by
+type NoteSort string
+
+// Supported note listing orders
+const (
+	SortUploadedAt NoteSort = "uploaded_at"
+	SortTitle      NoteSort = "title"
+	SortFileSize   NoteSort = "file_size"
+	SortCourseID   NoteSort = "course_id"
+)
+
+// noteSortKeys maps each NoteSort to the SQL its cursor value is compared with
+var noteSortKeys = map[NoteSort]string{
+	SortUploadedAt: "$%d::timestamptz",
+	SortTitle:      "$%d::text",
+	SortFileSize:   "$%d::bigint",
+	SortCourseID:   "$%d::text",
+}
+
+// ListCursor is a keyset position in a sorted listing: the last returned row's sort
+// column, as text, and its ID to break ties
+type ListCursor struct {
+	Value string
+	ID    uuid.UUID
+}
+
+// NoteListQuery selects one page of a user's notes
+type NoteListQuery struct {
+	Filter     *models.NoteFilter
+	Sort       NoteSort
+	Descending bool
+	// After is the position of the previous page's last row; nil starts at the beginning
+	After *ListCursor
+	Limit int
 }
 
 // PostgresNoteRepository implements NoteRepository using PostgreSQL
@@ -33,59 +167,91 @@ func NewPostgresNoteRepository(db *pgxpool.Pool) *PostgresNoteRepository {
 	}
 }
 
-// CreateNote creates a new note in the database
+// CreateNote creates a new note in the database, recording its file as revision 1
 func (r *PostgresNoteRepository) CreateNote(ctx context.Context, note *models.Note) error {
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		return insertNote(ctx, tx, note)
+	})
+
+	if err != nil {
+		slog.Error("Failed to create note", "error", err, "noteID", note.ID)
+		return fmt.Errorf("failed to create note: %w", err)
+	}
+
+	slog.Info("Note created successfully", "noteID", note.ID, "userEmail", note.UserEmail)
+	return nil
+}
+
+// insertNote inserts a note and its first revision within tx, and queues the revision
+// for processing so the job commits or rolls back with the note
+func insertNote(ctx context.Context, tx pgx.Tx, note *models.Note) error {
 	query := `
-		INSERT INTO notes (id, user_email, title, course_id, file_name, file_path, file_size, content_type)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING uploaded_at, updated_at`
-	
-	err := r.db.QueryRow(ctx, query,
+		INSERT INTO notes (id, user_email, user_id, title, course_id, file_name, file_path, file_size, content_type,
+			page_count, pdf_version, content_text, visibility, sha256, status, status_reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, 0), NULLIF($11, ''), $12, $13, NULLIF($14, ''), $15, $16)
+		RETURNING current_version, uploaded_at, updated_at`
+
+	versionQuery := `
+		INSERT INTO note_versions (note_id, version, file_name, file_path, file_size, content_type,
+			page_count, pdf_version, content_text, uploaded_by, created_at, sha256)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, 0), NULLIF($8, ''), $9, $10, $11, NULLIF($12, ''))`
+
+	err := tx.QueryRow(ctx, query,
 		note.ID,
 		note.UserEmail,
+		note.UserID,
 		note.Title,
 		note.CourseID,
 		note.FileName,
 		note.FilePath,
 		note.FileSize,
 		note.ContentType,
-	).Scan(&note.UploadedAt, &note.UpdatedAt)
+		note.PageCount,
+		note.PDFVersion,
+		note.ContentText,
+		note.Visibility,
+		note.SHA256,
+		note.Status,
+		note.StatusReason,
+	).Scan(&note.Version, &note.UploadedAt, &note.UpdatedAt)
+	if err != nil {
+		return err
+	}
 
+	_, err = tx.Exec(ctx, versionQuery,
+		note.ID,
+		note.Version,
+		note.FileName,
+		note.FilePath,
+		note.FileSize,
+		note.ContentType,
+		note.PageCount,
+		note.PDFVersion,
+		note.ContentText,
+		note.UserEmail,
+		note.UploadedAt,
+		note.SHA256,
+	)
 	if err != nil {
-		slog.Error("Failed to create note", "error", err, "noteID", note.ID)
-		return fmt.Errorf("failed to create note: %w", err)
+		return err
 	}
 
-	slog.Info("Note created successfully", "noteID", note.ID, "userEmail", note.UserEmail)
-	return nil
+	return jobs.Enqueue(ctx, tx, models.JobProcessNote, models.ProcessNoteJob{NoteID: note.ID, Version: note.Version})
 }
 
 // GetNoteByID retrieves a note by its ID
 func (r *PostgresNoteRepository) GetNoteByID(ctx context.Context, id uuid.UUID) (*models.Note, error) {
 	query := `
-		SELECT id, user_email, title, course_id, file_name, file_path, file_size, 
-			   content_type, uploaded_at, updated_at
+		SELECT ` + noteColumns + `
 		FROM notes 
 		WHERE id = $1`
 	
-	note := &models.Note{}
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&note.ID,
-		&note.UserEmail,
-		&note.Title,
-		&note.CourseID,
-		&note.FileName,
-		&note.FilePath,
-		&note.FileSize,
-		&note.ContentType,
-		&note.UploadedAt,
-		&note.UpdatedAt,
-	)
+	note, err := scanNote(r.db.QueryRow(ctx, query, id))
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			slog.Debug("Note not found", "noteID", id)
-			return nil, fmt.Errorf("note not found: %s", id)
+			return nil, fmt.Errorf("%w: %s", ErrNoteNotFound, id)
 		}
 		slog.Error("Failed to get note by ID", "error", err, "noteID", id)
 		return nil, fmt.Errorf("failed to get note: %w", err)
@@ -95,38 +261,79 @@ func (r *PostgresNoteRepository) GetNoteByID(ctx context.Context, id uuid.UUID)
 	return note, nil
 }
 
-// GetNotesByUser retrieves notes for a specific user with pagination
-func (r *PostgresNoteRepository) GetNotesByUser(ctx context.Context, userEmail string, limit, offset int) ([]*models.Note, error) {
-	query := `
-		SELECT id, user_email, title, course_id, file_name, file_path, file_size, 
-			   content_type, uploaded_at, updated_at
-		FROM notes 
-		WHERE user_email = $1
-		ORDER BY uploaded_at DESC
-		LIMIT $2 OFFSET $3`
-	
-	rows, err := r.db.Query(ctx, query, userEmail, limit, offset)
+// noteFilterClause builds the WHERE clause selecting a user's notes that match filter,
+// appending its parameters to args
+func noteFilterClause(userID uuid.UUID, filter *models.NoteFilter, args []any) (string, []any) {
+	args = append(args, userID)
+	userParam := len(args)
+	where := fmt.Sprintf(`user_id = $%d`, userParam)
+
+	statuses := filter.Statuses
+	if len(statuses) == 0 {
+		statuses = []string{models.NoteStatusReady}
+	}
+	args = append(args, statuses)
+	where += fmt.Sprintf(` AND status = ANY($%d)`, len(args))
+
+	if filter.CourseID != "" {
+		args = append(args, filter.CourseID)
+		where += fmt.Sprintf(` AND course_id = $%d`, len(args))
+	}
+
+	// The tags must be distinct for the count to mean "every tag"
+	if len(filter.Tags) > 0 {
+		args = append(args, filter.Tags, filter.MatchAllTags)
+		where += fmt.Sprintf(`
+		  AND id IN (
+			  SELECT note_id
+			  FROM note_tags
+			  WHERE user_id = $%[1]d AND tag = ANY($%[2]d)
+			  GROUP BY note_id
+			  HAVING NOT $%[3]d OR COUNT(*) = cardinality($%[2]d::text[])
+		  )`, userParam, len(args)-1, len(args))
+	}
+
+	return where, args
+}
+
+// ListNotes retrieves one page of a user's notes matching the query's filter, in the
+// requested order. Ties are broken by ID so every row has a unique position.
+func (r *PostgresNoteRepository) ListNotes(ctx context.Context, userID uuid.UUID, q *NoteListQuery) ([]*models.Note, error) {
+	key, ok := noteSortKeys[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unsupported note sort %q", q.Sort)
+	}
+	column := string(q.Sort)
+	direction, compare := "ASC", ">"
+	if q.Descending {
+		direction, compare = "DESC", "<"
+	}
+
+	where, args := noteFilterClause(userID, q.Filter, nil)
+	if q.After != nil {
+		args = append(args, q.After.Value, q.After.ID)
+		where += fmt.Sprintf(`
+		  AND (%s, id) %s (`+key+`, $%d)`, column, compare, len(args)-1, len(args))
+	}
+	args = append(args, q.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT `+noteColumns+`
+		FROM notes
+		WHERE %s
+		ORDER BY %s %s, id %s
+		LIMIT $%d`, where, column, direction, direction, len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
-		slog.Error("Failed to query notes by user", "error", err, "userEmail", userEmail)
-		return nil, fmt.Errorf("failed to get notes for user: %w", err)
+		slog.Error("Failed to list notes", "error", err, "userID", userID)
+		return nil, fmt.Errorf("failed to list notes: %w", err)
 	}
 	defer rows.Close()
 
 	var notes []*models.Note
 	for rows.Next() {
-		note := &models.Note{}
-		err := rows.Scan(
-			&note.ID,
-			&note.UserEmail,
-			&note.Title,
-			&note.CourseID,
-			&note.FileName,
-			&note.FilePath,
-			&note.FileSize,
-			&note.ContentType,
-			&note.UploadedAt,
-			&note.UpdatedAt,
-		)
+		note, err := scanNote(rows)
 		if err != nil {
 			slog.Error("Failed to scan note", "error", err)
 			return nil, fmt.Errorf("failed to scan note: %w", err)
@@ -139,47 +346,145 @@ func (r *PostgresNoteRepository) GetNotesByUser(ctx context.Context, userEmail s
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
 
-	slog.Debug("Notes retrieved for user", "userEmail", userEmail, "count", len(notes))
+	slog.Debug("Notes listed for user", "userID", userID, "sort", q.Sort, "count", len(notes))
 	return notes, nil
 }
 
-// GetNotesByCourse retrieves notes for a specific user and course with pagination
-func (r *PostgresNoteRepository) GetNotesByCourse(ctx context.Context, userEmail, courseID string, limit, offset int) ([]*models.Note, error) {
+// CountNotes counts a user's notes matching filter
+func (r *PostgresNoteRepository) CountNotes(ctx context.Context, userID uuid.UUID, filter *models.NoteFilter) (int, error) {
+	where, args := noteFilterClause(userID, filter, nil)
+
+	var count int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM notes WHERE `+where, args...).Scan(&count); err != nil {
+		slog.Error("Failed to count notes", "error", err, "userID", userID)
+		return 0, fmt.Errorf("failed to count notes: %w", err)
+	}
+
+	return count, nil
+}
+
+// Markers ts_headline wraps matches in. They are control characters, which never
+// appear in stored titles or extracted text, so they survive HTML escaping intact.
+const (
+	highlightStart = "\x01"
+	highlightStop  = "\x02"
+)
+
+// SearchNotes runs a full-text search over the titles and text of the ready notes a
+// user can view: their own, those shared with them and those published to Rice, best
+// matches first. As when viewing one, a published note's owner email is left out.
+func (r *PostgresNoteRepository) SearchNotes(ctx context.Context, userID uuid.UUID, query, courseID string, limit, offset int) ([]*models.SearchResult, error) {
+	// Rank and page first, then build headlines only for the returned rows;
+	// ts_headline re-parses the whole document and is the expensive part
+	sqlQuery := `
+		WITH q AS (SELECT websearch_to_tsquery('english', $2) AS query),
+		matches AS (
+			SELECT notes.id AS match_id, ts_rank_cd(notes.search_vector, q.query) AS match_rank,
+				   notes.user_id = $1 OR shares.note_id IS NOT NULL AS match_known
+			FROM notes
+			CROSS JOIN q
+			LEFT JOIN note_shares shares ON shares.note_id = notes.id AND shares.grantee_user_id = $1
+			WHERE (notes.user_id = $1 OR shares.note_id IS NOT NULL OR notes.visibility = 'rice')
+			  AND notes.status = 'ready'
+			  AND notes.search_vector @@ q.query
+			  AND ($3 = '' OR notes.course_id = $3)
+			ORDER BY match_rank DESC, notes.uploaded_at DESC
+			LIMIT $4 OFFSET $5
+		)
+		SELECT ` + noteColumns + `, matches.match_rank, matches.match_known,
+			   ts_headline('english',
+				   CASE WHEN content_text = '' THEN title ELSE content_text END,
+				   q.query,
+				   'StartSel="` + highlightStart + `", StopSel="` + highlightStop + `", MaxFragments=2, MaxWords=20, MinWords=8, FragmentDelimiter=" … "')
+		FROM notes
+		JOIN matches ON matches.match_id = notes.id, q
+		ORDER BY matches.match_rank DESC, uploaded_at DESC`
+
+	rows, err := r.db.Query(ctx, sqlQuery, userID, query, courseID, limit, offset)
+	if err != nil {
+		slog.Error("Failed to search notes", "error", err, "userID", userID)
+		return nil, fmt.Errorf("failed to search notes: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*models.SearchResult
+	for rows.Next() {
+		result := &models.SearchResult{}
+		var headline string
+		var known bool
+		note, err := scanNote(rows, &result.Rank, &known, &headline)
+		if err != nil {
+			slog.Error("Failed to scan search result", "error", err)
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		if !known {
+			note.UserEmail = ""
+		}
+		result.Note = note
+		result.Snippet = highlightSnippet(headline)
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("Error iterating rows", "error", err)
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	slog.Debug("Notes searched", "userID", userID, "courseID", courseID, "count", len(results))
+	return results, nil
+}
+
+// highlightSnippet escapes a headline for HTML and turns the match markers into <mark> tags
+func highlightSnippet(headline string) string {
+	escaped := html.EscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightStop, "</mark>")
+}
+
+// GetPublishedNotesByCourse retrieves a course's published ready notes, newest first, excluding
+// the viewer's own. Each note's AuthorName is its owner's current display name, falling
+// back to their Google name. Pass the last row of the previous page as after to continue
+// from it.
+func (r *PostgresNoteRepository) GetPublishedNotesByCourse(ctx context.Context, courseID string, viewerID uuid.UUID, after *Cursor, limit int) ([]*models.Note, error) {
 	query := `
-		SELECT id, user_email, title, course_id, file_name, file_path, file_size, 
-			   content_type, uploaded_at, updated_at
-		FROM notes 
-		WHERE user_email = $1 AND course_id = $2
-		ORDER BY uploaded_at DESC
-		LIMIT $3 OFFSET $4`
-	
-	rows, err := r.db.Query(ctx, query, userEmail, courseID, limit, offset)
+		SELECT ` + noteColumns + `, COALESCE(authors.author_name, '')
+		FROM notes
+		LEFT JOIN (
+			SELECT id AS author_id, COALESCE(display_name, name) AS author_name FROM users
+		) authors ON authors.author_id = notes.user_id
+		WHERE course_id = $1
+		  AND user_id <> $2
+		  AND status = 'ready'
+		  AND visibility = 'rice'`
+	args := []any{courseID, viewerID}
+
+	if after != nil {
+		query += `
+		  AND (uploaded_at, id) < ($3, $4)`
+		args = append(args, after.UploadedAt, after.ID)
+	}
+
+	query += fmt.Sprintf(`
+		ORDER BY uploaded_at DESC, id DESC
+		LIMIT $%d`, len(args)+1)
+	args = append(args, limit)
+
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
-		slog.Error("Failed to query notes by course", "error", err, 
-			"userEmail", userEmail, "courseID", courseID)
-		return nil, fmt.Errorf("failed to get notes for course: %w", err)
+		slog.Error("Failed to query published notes", "error", err, "courseID", courseID)
+		return nil, fmt.Errorf("failed to get course notes: %w", err)
 	}
 	defer rows.Close()
 
 	var notes []*models.Note
 	for rows.Next() {
-		note := &models.Note{}
-		err := rows.Scan(
-			&note.ID,
-			&note.UserEmail,
-			&note.Title,
-			&note.CourseID,
-			&note.FileName,
-			&note.FilePath,
-			&note.FileSize,
-			&note.ContentType,
-			&note.UploadedAt,
-			&note.UpdatedAt,
-		)
+		var author string
+		note, err := scanNote(rows, &author)
 		if err != nil {
 			slog.Error("Failed to scan note", "error", err)
 			return nil, fmt.Errorf("failed to scan note: %w", err)
 		}
+		note.AuthorName = author
 		notes = append(notes, note)
 	}
 
@@ -188,26 +493,364 @@ func (r *PostgresNoteRepository) GetNotesByCourse(ctx context.Context, userEmail
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
 
-	slog.Debug("Notes retrieved for course", "userEmail", userEmail, "courseID", courseID, "count", len(notes))
+	slog.Debug("Published notes retrieved for course", "courseID", courseID, "count", len(notes))
 	return notes, nil
 }
 
+// UpdateNote applies the non-nil fields of update. When ifUnmodifiedAt is set the row is
+// only changed if its updated_at still matches, and ErrNoteModified is returned otherwise.
+// The update_notes_updated_at trigger bumps updated_at.
+func (r *PostgresNoteRepository) UpdateNote(ctx context.Context, id uuid.UUID, update *models.UpdateNoteRequest, ifUnmodifiedAt *time.Time) (*models.Note, error) {
+	query := `
+		UPDATE notes
+		SET title = COALESCE($2, title),
+			course_id = COALESCE($3, course_id),
+			visibility = COALESCE($4, visibility)
+		WHERE id = $1 AND ($5::timestamptz IS NULL OR updated_at = $5)
+		RETURNING ` + noteColumns
+
+	note, err := scanNote(r.db.QueryRow(ctx, query, id, update.Title, update.CourseID, update.Visibility, ifUnmodifiedAt))
+	if err == pgx.ErrNoRows {
+		// Tell a failed precondition apart from a note deleted in the meantime
+		var exists bool
+		if err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1)`, id).Scan(&exists); err != nil {
+			slog.Error("Failed to check note existence", "error", err, "noteID", id)
+			return nil, fmt.Errorf("failed to update note: %w", err)
+		}
+		if exists {
+			slog.Debug("Note update precondition failed", "noteID", id)
+			return nil, ErrNoteModified
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNoteNotFound, id)
+	}
+	if err != nil {
+		slog.Error("Failed to update note", "error", err, "noteID", id)
+		return nil, fmt.Errorf("failed to update note: %w", err)
+	}
+
+	slog.Info("Note updated successfully", "noteID", id)
+	return note, nil
+}
+
+// AddNoteVersion records a new revision of a note's file, makes it current and queues
+// it for processing; the note is processing until the job finishes. The revision number
+// is assigned here, one past the note's current version.
+func (r *PostgresNoteRepository) AddNoteVersion(ctx context.Context, version *models.NoteVersion) (*models.Note, error) {
+	insertQuery := `
+		INSERT INTO note_versions (id, note_id, version, file_name, file_path, file_size, content_type,
+			page_count, pdf_version, content_text, uploaded_by, sha256)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, 0), NULLIF($9, ''), $10, $11, NULLIF($12, ''))
+		RETURNING created_at`
+
+	updateQuery := `
+		UPDATE notes
+		SET file_name = $2, file_path = $3, file_size = $4, content_type = $5,
+			page_count = NULLIF($6, 0), pdf_version = NULLIF($7, ''), content_text = $8,
+			current_version = $9, sha256 = NULLIF($10, ''), status = 'processing', status_reason = ''
+		WHERE id = $1
+		RETURNING ` + noteColumns
+
+	var note *models.Note
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		// Lock the note so concurrent uploads get consecutive revision numbers
+		var current int
+		err := tx.QueryRow(ctx, `SELECT current_version FROM notes WHERE id = $1 FOR UPDATE`, version.NoteID).Scan(&current)
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("%w: %s", ErrNoteNotFound, version.NoteID)
+		}
+		if err != nil {
+			return err
+		}
+		version.Version = current + 1
+
+		err = tx.QueryRow(ctx, insertQuery,
+			version.ID,
+			version.NoteID,
+			version.Version,
+			version.FileName,
+			version.FilePath,
+			version.FileSize,
+			version.ContentType,
+			version.PageCount,
+			version.PDFVersion,
+			version.ContentText,
+			version.UploadedBy,
+			version.SHA256,
+		).Scan(&version.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		note, err = scanNote(tx.QueryRow(ctx, updateQuery,
+			version.NoteID,
+			version.FileName,
+			version.FilePath,
+			version.FileSize,
+			version.ContentType,
+			version.PageCount,
+			version.PDFVersion,
+			version.ContentText,
+			version.Version,
+			version.SHA256,
+		))
+		if err != nil {
+			return err
+		}
+
+		return jobs.Enqueue(ctx, tx, models.JobProcessNote, models.ProcessNoteJob{NoteID: version.NoteID, Version: version.Version})
+	})
+
+	if errors.Is(err, ErrNoteNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		slog.Error("Failed to add note version", "error", err, "noteID", version.NoteID)
+		return nil, fmt.Errorf("failed to add note version: %w", err)
+	}
+
+	slog.Info("Note version added", "noteID", version.NoteID, "version", version.Version)
+	return note, nil
+}
+
+// GetNoteVersions lists every revision of a note, newest first
+func (r *PostgresNoteRepository) GetNoteVersions(ctx context.Context, noteID uuid.UUID) ([]*models.NoteVersion, error) {
+	query := `
+		SELECT ` + versionColumns + `
+		FROM note_versions
+		WHERE note_id = $1
+		ORDER BY version DESC`
+
+	rows, err := r.db.Query(ctx, query, noteID)
+	if err != nil {
+		slog.Error("Failed to query note versions", "error", err, "noteID", noteID)
+		return nil, fmt.Errorf("failed to get note versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []*models.NoteVersion
+	for rows.Next() {
+		v, err := scanVersion(rows)
+		if err != nil {
+			slog.Error("Failed to scan note version", "error", err)
+			return nil, fmt.Errorf("failed to scan note version: %w", err)
+		}
+		versions = append(versions, v)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("Error iterating rows", "error", err)
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return versions, nil
+}
+
+// GetNoteVersion retrieves one revision of a note by number
+func (r *PostgresNoteRepository) GetNoteVersion(ctx context.Context, noteID uuid.UUID, version int) (*models.NoteVersion, error) {
+	query := `
+		SELECT ` + versionColumns + `
+		FROM note_versions
+		WHERE note_id = $1 AND version = $2`
+
+	v, err := scanVersion(r.db.QueryRow(ctx, query, noteID, version))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrVersionNotFound
+		}
+		slog.Error("Failed to get note version", "error", err, "noteID", noteID, "version", version)
+		return nil, fmt.Errorf("failed to get note version: %w", err)
+	}
+
+	return v, nil
+}
+
+// SetNoteContent records what processing found in a revision of a note's file. If the
+// revision is still current, it is copied to the note and the note becomes ready.
+func (r *PostgresNoteRepository) SetNoteContent(ctx context.Context, noteID uuid.UUID, version int, content *models.NoteContent) error {
+	versionQuery := `
+		UPDATE note_versions
+		SET page_count = NULLIF($3, 0), pdf_version = NULLIF($4, ''), content_text = $5
+		WHERE note_id = $1 AND version = $2`
+
+	noteQuery := `
+		UPDATE notes
+		SET page_count = NULLIF($3, 0), pdf_version = NULLIF($4, ''), content_text = $5,
+			status = 'ready', status_reason = ''
+		WHERE id = $1 AND current_version = $2`
+
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, versionQuery, noteID, version, content.PageCount, content.PDFVersion, content.Text)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return ErrVersionNotFound
+		}
+
+		_, err = tx.Exec(ctx, noteQuery, noteID, version, content.PageCount, content.PDFVersion, content.Text)
+		return err
+	})
+
+	if errors.Is(err, ErrVersionNotFound) {
+		return err
+	}
+	if err != nil {
+		slog.Error("Failed to set note content", "error", err, "noteID", noteID, "version", version)
+		return fmt.Errorf("failed to set note content: %w", err)
+	}
+
+	return nil
+}
+
+// SetNoteStatus moves a note to status, unless a newer revision than version has been
+// uploaded since, which has a status of its own
+func (r *PostgresNoteRepository) SetNoteStatus(ctx context.Context, noteID uuid.UUID, version int, status, reason string) error {
+	query := `
+		UPDATE notes
+		SET status = $3, status_reason = $4
+		WHERE id = $1 AND current_version = $2`
+
+	if _, err := r.db.Exec(ctx, query, noteID, version, status, reason); err != nil {
+		slog.Error("Failed to set note status", "error", err, "noteID", noteID, "status", status)
+		return fmt.Errorf("failed to set note status: %w", err)
+	}
+
+	slog.Info("Note status changed", "noteID", noteID, "version", version, "status", status)
+	return nil
+}
+
 // DeleteNote deletes a note (only if it belongs to the specified user)
-func (r *PostgresNoteRepository) DeleteNote(ctx context.Context, id uuid.UUID, userEmail string) error {
-	query := `DELETE FROM notes WHERE id = $1 AND user_email = $2`
+func (r *PostgresNoteRepository) DeleteNote(ctx context.Context, id, userID uuid.UUID) error {
+	query := `DELETE FROM notes WHERE id = $1 AND user_id = $2`
 	
-	result, err := r.db.Exec(ctx, query, id, userEmail)
+	result, err := r.db.Exec(ctx, query, id, userID)
 	if err != nil {
-		slog.Error("Failed to delete note", "error", err, "noteID", id, "userEmail", userEmail)
+		slog.Error("Failed to delete note", "error", err, "noteID", id, "userID", userID)
 		return fmt.Errorf("failed to delete note: %w", err)
 	}
 
 	rowsAffected := result.RowsAffected()
 	if rowsAffected == 0 {
-		slog.Warn("Note not found or not owned by user", "noteID", id, "userEmail", userEmail)
-		return fmt.Errorf("note not found or not owned by user")
+		slog.Warn("Note not found or not owned by user", "noteID", id, "userID", userID)
+		return ErrNoteNotFound
 	}
 
-	slog.Info("Note deleted successfully", "noteID", id, "userEmail", userEmail)
+	slog.Info("Note deleted successfully", "noteID", id, "userID", userID)
 	return nil
-}
\ No newline at end of file
+}
+
+// AddNoteTags tags a note for a user, ignoring tags it already carries, and returns
+// all of the user's tags on the note
+func (r *PostgresNoteRepository) AddNoteTags(ctx context.Context, noteID, userID uuid.UUID, tags []string) ([]string, error) {
+	insertQuery := `
+		INSERT INTO note_tags (note_id, user_id, tag)
+		SELECT $1, $2, UNNEST($3::text[])
+		ON CONFLICT DO NOTHING`
+
+	selectQuery := `SELECT tag FROM note_tags WHERE note_id = $1 AND user_id = $2 ORDER BY tag`
+
+	var all []string
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, insertQuery, noteID, userID, tags); err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx, selectQuery, noteID, userID)
+		if err != nil {
+			return err
+		}
+		all, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		return err
+	})
+	if err != nil {
+		slog.Error("Failed to add note tags", "error", err, "noteID", noteID, "userID", userID)
+		return nil, fmt.Errorf("failed to add note tags: %w", err)
+	}
+
+	return all, nil
+}
+
+// RemoveNoteTag removes one of a user's tags from a note
+func (r *PostgresNoteRepository) RemoveNoteTag(ctx context.Context, noteID, userID uuid.UUID, tag string) error {
+	query := `DELETE FROM note_tags WHERE note_id = $1 AND user_id = $2 AND tag = $3`
+
+	result, err := r.db.Exec(ctx, query, noteID, userID, tag)
+	if err != nil {
+		slog.Error("Failed to remove note tag", "error", err, "noteID", noteID, "userID", userID)
+		return fmt.Errorf("failed to remove note tag: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrTagNotFound
+	}
+
+	return nil
+}
+
+// GetNoteTags returns a user's tags on each of the given notes, keyed by note ID.
+// Notes without tags are left out of the map.
+func (r *PostgresNoteRepository) GetNoteTags(ctx context.Context, userID uuid.UUID, noteIDs []uuid.UUID) (map[uuid.UUID][]string, error) {
+	query := `
+		SELECT note_id, tag
+		FROM note_tags
+		WHERE user_id = $1 AND note_id = ANY($2)
+		ORDER BY note_id, tag`
+
+	rows, err := r.db.Query(ctx, query, userID, noteIDs)
+	if err != nil {
+		slog.Error("Failed to query note tags", "error", err, "userID", userID)
+		return nil, fmt.Errorf("failed to get note tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[uuid.UUID][]string)
+	for rows.Next() {
+		var noteID uuid.UUID
+		var tag string
+		if err := rows.Scan(&noteID, &tag); err != nil {
+			slog.Error("Failed to scan note tag", "error", err)
+			return nil, fmt.Errorf("failed to scan note tag: %w", err)
+		}
+		tags[noteID] = append(tags[noteID], tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("Error iterating rows", "error", err)
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return tags, nil
+}
+
+// GetUserTags lists a user's tags with the number of their notes carrying each,
+// most used first
+func (r *PostgresNoteRepository) GetUserTags(ctx context.Context, userID uuid.UUID) ([]*models.TagCount, error) {
+	query := `
+		SELECT tag, COUNT(*)
+		FROM note_tags
+		WHERE user_id = $1
+		GROUP BY tag
+		ORDER BY COUNT(*) DESC, tag`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		slog.Error("Failed to query user tags", "error", err, "userID", userID)
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []*models.TagCount
+	for rows.Next() {
+		count := &models.TagCount{}
+		if err := rows.Scan(&count.Tag, &count.Count); err != nil {
+			slog.Error("Failed to scan tag count", "error", err)
+			return nil, fmt.Errorf("failed to scan tag count: %w", err)
+		}
+		counts = append(counts, count)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("Error iterating rows", "error", err)
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return counts, nil
+}
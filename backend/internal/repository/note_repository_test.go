@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPostgresNoteRepository_SearchNotes_Scope(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	repo := NewPostgresNoteRepository(db)
+
+	newUser := func() (uuid.UUID, string) {
+		email := uuid.NewString() + "@rice.edu"
+		var id uuid.UUID
+		if err := db.QueryRow(ctx, `INSERT INTO users (email) VALUES ($1) RETURNING id`, email).Scan(&id); err != nil {
+			t.Fatalf("seeding user error = %v", err)
+		}
+		return id, email
+	}
+	searcher, searcherEmail := newUser()
+	other, otherEmail := newUser()
+
+	// A word no other test's notes contain, so only these notes match
+	word := "zq" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	newNote := func(ownerID uuid.UUID, ownerEmail, title, visibility, status string) uuid.UUID {
+		var id uuid.UUID
+		err := db.QueryRow(ctx, `
+			INSERT INTO notes (user_id, user_email, title, course_id, file_name, file_path, file_size, visibility, status)
+			VALUES ($1, $2, $3, 'COMP 140', 'a.pdf', 'notes/a.pdf', 8, $4, $5)
+			RETURNING id`, ownerID, ownerEmail, title+" "+word, visibility, status).Scan(&id)
+		if err != nil {
+			t.Fatalf("seeding note error = %v", err)
+		}
+		return id
+	}
+	own := newNote(searcher, searcherEmail, "own", "private", "ready")
+	shared := newNote(other, otherEmail, "shared", "private", "ready")
+	published := newNote(other, otherEmail, "published", "rice", "ready")
+	newNote(other, otherEmail, "private", "private", "ready")
+	newNote(other, otherEmail, "processing", "rice", "processing")
+	if _, err := db.Exec(ctx, `INSERT INTO note_shares (note_id, grantee_user_id, role, granted_by) VALUES ($1, $2, 'viewer', $3)`, shared, searcher, otherEmail); err != nil {
+		t.Fatalf("seeding share error = %v", err)
+	}
+
+	results, err := repo.SearchNotes(ctx, searcher, word, "", 10, 0)
+	if err != nil {
+		t.Fatalf("SearchNotes() error = %v", err)
+	}
+
+	wantEmails := map[uuid.UUID]string{own: searcherEmail, shared: otherEmail, published: ""}
+	if len(results) != len(wantEmails) {
+		t.Fatalf("SearchNotes() returned %d notes, want %d", len(results), len(wantEmails))
+	}
+	for _, result := range results {
+		wantEmail, ok := wantEmails[result.ID]
+		if !ok {
+			t.Errorf("SearchNotes() returned %q, which the user can't view", result.Title)
+			continue
+		}
+		if result.UserEmail != wantEmail {
+			t.Errorf("SearchNotes() %q owner email = %q, want %q", result.Title, result.UserEmail, wantEmail)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// ErrSessionNotFound is returned when no session has the given refresh token
+	ErrSessionNotFound = models.NewError(models.ErrNotFound, "session_not_found", "session not found")
+	// ErrSessionRotated is returned when rotating a session that was already rotated or revoked
+	ErrSessionRotated = models.NewError(models.ErrConflict, "session_rotated", "session already rotated")
+	// ErrAuthCodeNotFound is returned when an auth code is unknown, used or expired
+	ErrAuthCodeNotFound = models.NewError(models.ErrNotFound, "auth_code_not_found", "auth code not found")
+)
+
+// SessionRepository defines the interface for refresh token session operations
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *models.Session) error
+	GetSessionByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error)
+	RotateSession(ctx context.Context, oldID uuid.UUID, next *models.Session) error
+	RevokeSessionFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeSessionFamilyOf(ctx context.Context, sessionID uuid.UUID) error
+	RevokeUserSessions(ctx context.Context, userID uuid.UUID) error
+	CreateAuthCode(ctx context.Context, code *models.AuthCode) error
+	ConsumeAuthCode(ctx context.Context, codeHash string) (*models.AuthCode, error)
+}
+
+// PostgresSessionRepository implements SessionRepository using PostgreSQL
+type PostgresSessionRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewPostgresSessionRepository creates a new PostgreSQL-based session repository
+func NewPostgresSessionRepository(db *pgxpool.Pool) *PostgresSessionRepository {
+	return &PostgresSessionRepository{
+		db: db,
+	}
+}
+
+const insertSessionQuery = `
+		INSERT INTO sessions (id, family_id, user_id, user_email, user_name, user_picture, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at`
+
+// CreateSession stores a new session
+func (r *PostgresSessionRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	err := r.db.QueryRow(ctx, insertSessionQuery,
+		session.ID,
+		session.FamilyID,
+		session.UserID,
+		session.UserEmail,
+		session.UserName,
+		session.UserPicture,
+		session.TokenHash,
+		session.ExpiresAt,
+	).Scan(&session.CreatedAt)
+
+	if err != nil {
+		slog.Error("Failed to create session", "error", err, "userEmail", session.UserEmail)
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	slog.Debug("Session created", "sessionID", session.ID, "familyID", session.FamilyID)
+	return nil
+}
+
+// GetSessionByTokenHash retrieves the session for a hashed refresh token
+func (r *PostgresSessionRepository) GetSessionByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	query := `
+		SELECT id, family_id, user_id, user_email, user_name, user_picture, token_hash,
+			   created_at, expires_at, rotated_at, revoked_at
+		FROM sessions
+		WHERE token_hash = $1`
+
+	session := &models.Session{}
+	err := r.db.QueryRow(ctx, query, tokenHash).Scan(
+		&session.ID,
+		&session.FamilyID,
+		&session.UserID,
+		&session.UserEmail,
+		&session.UserName,
+		&session.UserPicture,
+		&session.TokenHash,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+		&session.RotatedAt,
+		&session.RevokedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrSessionNotFound
+		}
+		slog.Error("Failed to get session", "error", err)
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	return session, nil
+}
+
+// RotateSession marks a session rotated and stores its successor atomically. Only one
+// of several concurrent rotations of the same session can succeed; the others get
+// ErrSessionRotated.
+func (r *PostgresSessionRepository) RotateSession(ctx context.Context, oldID uuid.UUID, next *models.Session) error {
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, `
+			UPDATE sessions SET rotated_at = NOW()
+			WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL`, oldID)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return ErrSessionRotated
+		}
+
+		return tx.QueryRow(ctx, insertSessionQuery,
+			next.ID,
+			next.FamilyID,
+			next.UserID,
+			next.UserEmail,
+			next.UserName,
+			next.UserPicture,
+			next.TokenHash,
+			next.ExpiresAt,
+		).Scan(&next.CreatedAt)
+	})
+
+	if errors.Is(err, ErrSessionRotated) {
+		return err
+	}
+	if err != nil {
+		slog.Error("Failed to rotate session", "error", err, "sessionID", oldID)
+		return fmt.Errorf("failed to rotate session: %w", err)
+	}
+
+	slog.Debug("Session rotated", "from", oldID, "to", next.ID, "familyID", next.FamilyID)
+	return nil
+}
+
+// RevokeSessionFamily revokes every session descended from the same login
+func (r *PostgresSessionRepository) RevokeSessionFamily(ctx context.Context, familyID uuid.UUID) error {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(ctx, query, familyID)
+	if err != nil {
+		slog.Error("Failed to revoke session family", "error", err, "familyID", familyID)
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	slog.Info("Session family revoked", "familyID", familyID, "sessions", result.RowsAffected())
+	return nil
+}
+
+// RevokeSessionFamilyOf revokes the family a session belongs to, ending that login
+func (r *PostgresSessionRepository) RevokeSessionFamilyOf(ctx context.Context, sessionID uuid.UUID) error {
+	query := `
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE family_id = (SELECT family_id FROM sessions WHERE id = $1) AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(ctx, query, sessionID)
+	if err != nil {
+		slog.Error("Failed to revoke session", "error", err, "sessionID", sessionID)
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	slog.Info("Session revoked", "sessionID", sessionID, "sessions", result.RowsAffected())
+	return nil
+}
+
+// RevokeUserSessions revokes every session a user has, on every device, including
+// sessions started under an email the user has since changed
+func (r *PostgresSessionRepository) RevokeUserSessions(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(ctx, query, userID)
+	if err != nil {
+		slog.Error("Failed to revoke user sessions", "error", err, "userID", userID)
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	slog.Info("User sessions revoked", "userID", userID, "sessions", result.RowsAffected())
+	return nil
+}
+
+// CreateAuthCode stores a one-time login code. Codes that have expired unused are
+// purged on the way.
+func (r *PostgresSessionRepository) CreateAuthCode(ctx context.Context, code *models.AuthCode) error {
+	query := `
+		INSERT INTO auth_codes (code_hash, session_id, user_email, user_name, user_picture, client_challenge, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at`
+
+	err := r.db.QueryRow(ctx, query,
+		code.CodeHash,
+		code.SessionID,
+		code.UserEmail,
+		code.UserName,
+		code.UserPicture,
+		code.ClientChallenge,
+		code.ExpiresAt,
+	).Scan(&code.CreatedAt)
+
+	if err != nil {
+		slog.Error("Failed to create auth code", "error", err, "userEmail", code.UserEmail)
+		return fmt.Errorf("failed to create auth code: %w", err)
+	}
+
+	if _, err := r.db.Exec(ctx, `DELETE FROM auth_codes WHERE expires_at < NOW()`); err != nil {
+		// Leftover rows are harmless, just wasted space
+		slog.Warn("Failed to purge expired auth codes", "error", err)
+	}
+
+	return nil
+}
+
+// ConsumeAuthCode deletes and returns an unexpired auth code whose session is still
+// live. Deleting makes it single-use even under concurrent redemption.
+func (r *PostgresSessionRepository) ConsumeAuthCode(ctx context.Context, codeHash string) (*models.AuthCode, error) {
+	query := `
+		DELETE FROM auth_codes c
+		USING sessions s
+		WHERE c.code_hash = $1 AND c.expires_at > NOW()
+		  AND s.id = c.session_id AND s.revoked_at IS NULL
+		RETURNING c.code_hash, c.session_id, s.user_id, c.user_email, c.user_name, c.user_picture,
+			c.client_challenge, c.created_at, c.expires_at`
+
+	code := &models.AuthCode{}
+	err := r.db.QueryRow(ctx, query, codeHash).Scan(
+		&code.CodeHash,
+		&code.SessionID,
+		&code.UserID,
+		&code.UserEmail,
+		&code.UserName,
+		&code.UserPicture,
+		&code.ClientChallenge,
+		&code.CreatedAt,
+		&code.ExpiresAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrAuthCodeNotFound
+		}
+		slog.Error("Failed to consume auth code", "error", err)
+		return nil, fmt.Errorf("failed to consume auth code: %w", err)
+	}
+
+	return code, nil
+}
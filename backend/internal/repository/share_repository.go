@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrShareNotFound is returned when a note has not been shared with a user
+var ErrShareNotFound = models.NewError(models.ErrNotFound, "share_not_found", "share not found")
+
+// ShareRepository defines the interface for note share database operations. Shares are
+// keyed by the grantee's user ID, so they survive the grantee's email changing.
+type ShareRepository interface {
+	UpsertShare(ctx context.Context, share *models.NoteShare) error
+	GetShare(ctx context.Context, noteID, granteeID uuid.UUID) (*models.NoteShare, error)
+	GetSharesByNote(ctx context.Context, noteID uuid.UUID) ([]*models.NoteShare, error)
+	GetNotesSharedWith(ctx context.Context, granteeID uuid.UUID, limit, offset int) ([]*models.SharedNote, error)
+	DeleteShare(ctx context.Context, noteID uuid.UUID, granteeEmail string) error
+}
+
+// shareColumns is the column list for note_shares queries joined to the grantee's user
+// row; keep it in sync with scanShare
+const shareColumns = `note_shares.note_id, note_shares.grantee_user_id, users.email, note_shares.role,
+			   note_shares.granted_by, note_shares.created_at, note_shares.updated_at`
+
+// scanShare scans a row selected with shareColumns
+func scanShare(row pgx.Row) (*models.NoteShare, error) {
+	share := &models.NoteShare{}
+	err := row.Scan(
+		&share.NoteID,
+		&share.GranteeID,
+		&share.GranteeEmail,
+		&share.Role,
+		&share.GrantedBy,
+		&share.CreatedAt,
+		&share.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// PostgresShareRepository implements ShareRepository using PostgreSQL
+type PostgresShareRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewPostgresShareRepository creates a new PostgreSQL-based share repository
+func NewPostgresShareRepository(db *pgxpool.Pool) *PostgresShareRepository {
+	return &PostgresShareRepository{
+		db: db,
+	}
+}
+
+// UpsertShare grants a share to the user with share.GranteeEmail, or changes the role of
+// an existing one, and fills in share.GranteeID. A grantee who has never signed in gets a
+// user row without a Google subject, which becomes theirs when they first sign in.
+func (r *PostgresShareRepository) UpsertShare(ctx context.Context, share *models.NoteShare) error {
+	query := `
+		INSERT INTO note_shares (note_id, grantee_user_id, role, granted_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (note_id, grantee_user_id)
+		DO UPDATE SET role = EXCLUDED.role, granted_by = EXCLUDED.granted_by
+		RETURNING created_at, updated_at`
+
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `INSERT INTO users (email) VALUES ($1) ON CONFLICT (email) DO NOTHING`, share.GranteeEmail)
+		if err != nil {
+			return err
+		}
+		if err := tx.QueryRow(ctx, `SELECT id FROM users WHERE email = $1`, share.GranteeEmail).Scan(&share.GranteeID); err != nil {
+			return err
+		}
+
+		return tx.QueryRow(ctx, query,
+			share.NoteID,
+			share.GranteeID,
+			share.Role,
+			share.GrantedBy,
+		).Scan(&share.CreatedAt, &share.UpdatedAt)
+	})
+
+	if err != nil {
+		slog.Error("Failed to upsert share", "error", err, "noteID", share.NoteID, "grantee", share.GranteeEmail)
+		return fmt.Errorf("failed to share note: %w", err)
+	}
+
+	slog.Info("Note shared", "noteID", share.NoteID, "grantee", share.GranteeEmail, "granteeID", share.GranteeID, "role", share.Role)
+	return nil
+}
+
+// GetShare retrieves the share of a note with a specific user
+func (r *PostgresShareRepository) GetShare(ctx context.Context, noteID, granteeID uuid.UUID) (*models.NoteShare, error) {
+	query := `
+		SELECT ` + shareColumns + `
+		FROM note_shares
+		JOIN users ON users.id = note_shares.grantee_user_id
+		WHERE note_shares.note_id = $1 AND note_shares.grantee_user_id = $2`
+
+	share, err := scanShare(r.db.QueryRow(ctx, query, noteID, granteeID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrShareNotFound
+		}
+		slog.Error("Failed to get share", "error", err, "noteID", noteID, "granteeID", granteeID)
+		return nil, fmt.Errorf("failed to get share: %w", err)
+	}
+
+	return share, nil
+}
+
+// GetSharesByNote lists everyone a note is shared with, by their current email
+func (r *PostgresShareRepository) GetSharesByNote(ctx context.Context, noteID uuid.UUID) ([]*models.NoteShare, error) {
+	query := `
+		SELECT ` + shareColumns + `
+		FROM note_shares
+		JOIN users ON users.id = note_shares.grantee_user_id
+		WHERE note_shares.note_id = $1
+		ORDER BY note_shares.created_at`
+
+	rows, err := r.db.Query(ctx, query, noteID)
+	if err != nil {
+		slog.Error("Failed to query shares by note", "error", err, "noteID", noteID)
+		return nil, fmt.Errorf("failed to get shares for note: %w", err)
+	}
+	defer rows.Close()
+
+	var shares []*models.NoteShare
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			slog.Error("Failed to scan share", "error", err)
+			return nil, fmt.Errorf("failed to scan share: %w", err)
+		}
+		shares = append(shares, share)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("Error iterating rows", "error", err)
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return shares, nil
+}
+
+// GetNotesSharedWith retrieves ready notes other users have shared with granteeID
+func (r *PostgresShareRepository) GetNotesSharedWith(ctx context.Context, granteeID uuid.UUID, limit, offset int) ([]*models.SharedNote, error) {
+	query := `
+		SELECT ` + noteColumns + `, note_shares.role, note_shares.created_at
+		FROM notes
+		JOIN note_shares ON note_shares.note_id = notes.id
+		WHERE note_shares.grantee_user_id = $1
+		  AND notes.status = 'ready'
+		ORDER BY note_shares.created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.Query(ctx, query, granteeID, limit, offset)
+	if err != nil {
+		slog.Error("Failed to query shared notes", "error", err, "granteeID", granteeID)
+		return nil, fmt.Errorf("failed to get shared notes: %w", err)
+	}
+	defer rows.Close()
+
+	var notes []*models.SharedNote
+	for rows.Next() {
+		shared := &models.SharedNote{}
+		note, err := scanNote(rows, &shared.Role, &shared.SharedAt)
+		if err != nil {
+			slog.Error("Failed to scan shared note", "error", err)
+			return nil, fmt.Errorf("failed to scan shared note: %w", err)
+		}
+		shared.Note = note
+		notes = append(notes, shared)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("Error iterating rows", "error", err)
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	slog.Debug("Shared notes retrieved", "granteeID", granteeID, "count", len(notes))
+	return notes, nil
+}
+
+// DeleteShare revokes access to a note from the user whose current email is granteeEmail
+func (r *PostgresShareRepository) DeleteShare(ctx context.Context, noteID uuid.UUID, granteeEmail string) error {
+	query := `
+		DELETE FROM note_shares
+		USING users
+		WHERE note_shares.note_id = $1
+		  AND users.id = note_shares.grantee_user_id
+		  AND users.email = $2`
+
+	result, err := r.db.Exec(ctx, query, noteID, granteeEmail)
+	if err != nil {
+		slog.Error("Failed to delete share", "error", err, "noteID", noteID, "grantee", granteeEmail)
+		return fmt.Errorf("failed to revoke share: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrShareNotFound
+	}
+
+	slog.Info("Share revoked", "noteID", noteID, "grantee", granteeEmail)
+	return nil
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TokenRevocationRepository defines the interface for revoking access tokens before they expire
+type TokenRevocationRepository interface {
+	RevokeToken(ctx context.Context, jti uuid.UUID, userEmail string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti, userID uuid.UUID, generation int) (bool, error)
+	GetTokenGeneration(ctx context.Context, userID uuid.UUID) (int, error)
+	BumpTokenGeneration(ctx context.Context, userID uuid.UUID) (int, error)
+}
+
+// PostgresTokenRevocationRepository implements TokenRevocationRepository using PostgreSQL
+type PostgresTokenRevocationRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewPostgresTokenRevocationRepository creates a new PostgreSQL-based token revocation repository
+func NewPostgresTokenRevocationRepository(db *pgxpool.Pool) *PostgresTokenRevocationRepository {
+	return &PostgresTokenRevocationRepository{
+		db: db,
+	}
+}
+
+// RevokeToken records a token as revoked until it would have expired anyway. Rows of
+// tokens that have since expired are purged on the way.
+func (r *PostgresTokenRevocationRepository) RevokeToken(ctx context.Context, jti uuid.UUID, userEmail string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, user_email, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING`
+
+	if _, err := r.db.Exec(ctx, query, jti, userEmail, expiresAt); err != nil {
+		slog.Error("Failed to revoke token", "error", err, "userEmail", userEmail)
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	if result, err := r.db.Exec(ctx, `DELETE FROM revoked_tokens WHERE expires_at < NOW()`); err != nil {
+		// Leftover rows are harmless, just wasted space
+		slog.Warn("Failed to purge expired token revocations", "error", err)
+	} else if result.RowsAffected() > 0 {
+		slog.Debug("Purged expired token revocations", "count", result.RowsAffected())
+	}
+
+	slog.Debug("Token revoked", "jti", jti, "userEmail", userEmail)
+	return nil
+}
+
+// IsTokenRevoked reports whether a token was revoked individually or belongs to a
+// generation older than the user's current one
+func (r *PostgresTokenRevocationRepository) IsTokenRevoked(ctx context.Context, jti, userID uuid.UUID, generation int) (bool, error) {
+	query := `
+		SELECT EXISTS (
+				SELECT 1 FROM revoked_tokens WHERE jti = $1 AND expires_at > NOW()
+			) OR COALESCE(
+				(SELECT generation FROM user_token_generations WHERE user_id = $2), 0
+			) > $3`
+
+	var revoked bool
+	if err := r.db.QueryRow(ctx, query, jti, userID, generation).Scan(&revoked); err != nil {
+		slog.Error("Failed to check token revocation", "error", err, "userID", userID)
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// GetTokenGeneration returns the generation new tokens for a user are issued in
+func (r *PostgresTokenRevocationRepository) GetTokenGeneration(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `
+		SELECT COALESCE(
+			(SELECT generation FROM user_token_generations WHERE user_id = $1), 0
+		)`
+
+	var generation int
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&generation); err != nil {
+		slog.Error("Failed to get token generation", "error", err, "userID", userID)
+		return 0, fmt.Errorf("failed to get token generation: %w", err)
+	}
+
+	return generation, nil
+}
+
+// BumpTokenGeneration advances a user's token generation, invalidating every token
+// issued before, and returns the new generation
+func (r *PostgresTokenRevocationRepository) BumpTokenGeneration(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `
+		INSERT INTO user_token_generations (user_id, generation)
+		VALUES ($1, 1)
+		ON CONFLICT (user_id) DO UPDATE
+		SET generation = user_token_generations.generation + 1, updated_at = NOW()
+		RETURNING generation`
+
+	var generation int
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&generation); err != nil {
+		slog.Error("Failed to bump token generation", "error", err, "userID", userID)
+		return 0, fmt.Errorf("failed to bump token generation: %w", err)
+	}
+
+	slog.Info("Token generation bumped", "userID", userID, "generation", generation)
+	return generation, nil
+}
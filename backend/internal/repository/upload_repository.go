@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrUploadNotFound is returned when a pending upload is unknown, completed or expired
+var ErrUploadNotFound = models.NewError(models.ErrNotFound, "upload_not_found", "upload not found or expired")
+
+// uploadColumns is the column list for note_uploads queries; keep it in sync with scanUpload
+const uploadColumns = `id, user_id, user_email, title, course_id, visibility,
+			   file_name, file_path, file_size, sha256, created_at, expires_at`
+
+// scanUpload scans a row selected with uploadColumns
+func scanUpload(row pgx.Row) (*models.PendingUpload, error) {
+	upload := &models.PendingUpload{}
+	err := row.Scan(
+		&upload.ID,
+		&upload.UserID,
+		&upload.UserEmail,
+		&upload.Title,
+		&upload.CourseID,
+		&upload.Visibility,
+		&upload.FileName,
+		&upload.FilePath,
+		&upload.FileSize,
+		&upload.SHA256,
+		&upload.CreatedAt,
+		&upload.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// UploadRepository defines the interface for pending direct-to-storage uploads
+type UploadRepository interface {
+	CreateUpload(ctx context.Context, upload *models.PendingUpload) error
+	GetUpload(ctx context.Context, id uuid.UUID) (*models.PendingUpload, error)
+	CompleteUpload(ctx context.Context, id uuid.UUID, note *models.Note) error
+	DeleteExpiredUploads(ctx context.Context) ([]*models.PendingUpload, error)
+}
+
+// PostgresUploadRepository implements UploadRepository using PostgreSQL
+type PostgresUploadRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewPostgresUploadRepository creates a new PostgreSQL-based upload repository
+func NewPostgresUploadRepository(db *pgxpool.Pool) *PostgresUploadRepository {
+	return &PostgresUploadRepository{
+		db: db,
+	}
+}
+
+// CreateUpload records a pending upload
+func (r *PostgresUploadRepository) CreateUpload(ctx context.Context, upload *models.PendingUpload) error {
+	query := `
+		INSERT INTO note_uploads (id, user_id, user_email, title, course_id, visibility,
+			file_name, file_path, file_size, sha256, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING created_at`
+
+	err := r.db.QueryRow(ctx, query,
+		upload.ID,
+		upload.UserID,
+		upload.UserEmail,
+		upload.Title,
+		upload.CourseID,
+		upload.Visibility,
+		upload.FileName,
+		upload.FilePath,
+		upload.FileSize,
+		upload.SHA256,
+		upload.ExpiresAt,
+	).Scan(&upload.CreatedAt)
+
+	if err != nil {
+		slog.Error("Failed to create upload", "error", err, "uploadID", upload.ID)
+		return fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	return nil
+}
+
+// GetUpload retrieves a pending upload that has not expired
+func (r *PostgresUploadRepository) GetUpload(ctx context.Context, id uuid.UUID) (*models.PendingUpload, error) {
+	query := `SELECT ` + uploadColumns + ` FROM note_uploads WHERE id = $1 AND expires_at > NOW()`
+
+	upload, err := scanUpload(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrUploadNotFound
+		}
+		slog.Error("Failed to get upload", "error", err, "uploadID", id)
+		return nil, fmt.Errorf("failed to get upload: %w", err)
+	}
+
+	return upload, nil
+}
+
+// CompleteUpload deletes a pending upload and creates its note in one transaction,
+// so an upload completed twice at once only creates one note
+func (r *PostgresUploadRepository) CompleteUpload(ctx context.Context, id uuid.UUID, note *models.Note) error {
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, `DELETE FROM note_uploads WHERE id = $1 AND expires_at > NOW()`, id)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return ErrUploadNotFound
+		}
+
+		return insertNote(ctx, tx, note)
+	})
+
+	if errors.Is(err, ErrUploadNotFound) {
+		return err
+	}
+	if err != nil {
+		slog.Error("Failed to complete upload", "error", err, "uploadID", id)
+		return fmt.Errorf("failed to complete upload: %w", err)
+	}
+
+	slog.Info("Upload completed", "uploadID", id, "noteID", note.ID, "userEmail", note.UserEmail)
+	return nil
+}
+
+// DeleteExpiredUploads deletes and returns the pending uploads that have expired, so
+// any file they uploaded can be removed from storage
+func (r *PostgresUploadRepository) DeleteExpiredUploads(ctx context.Context) ([]*models.PendingUpload, error) {
+	rows, err := r.db.Query(ctx, `DELETE FROM note_uploads WHERE expires_at <= NOW() RETURNING `+uploadColumns)
+	if err != nil {
+		slog.Error("Failed to delete expired uploads", "error", err)
+		return nil, fmt.Errorf("failed to delete expired uploads: %w", err)
+	}
+	defer rows.Close()
+
+	var uploads []*models.PendingUpload
+	for rows.Next() {
+		upload, err := scanUpload(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan expired upload: %w", err)
+		}
+		uploads = append(uploads, upload)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to delete expired uploads: %w", err)
+	}
+
+	return uploads, nil
+}
@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// uniqueViolation is the Postgres error code for a unique constraint violation
+const uniqueViolation = "23505"
+
+var (
+	// ErrUserNotFound is returned when a user does not exist
+	ErrUserNotFound = models.NewError(models.ErrNotFound, "user_not_found", "user profile not found, please sign in again")
+	// ErrEmailTaken is returned when signing in with an email that belongs to another Google account
+	ErrEmailTaken = models.NewError(models.ErrConflict, "email_taken", "email belongs to another account")
+)
+
+// userColumns is the column list for users queries; keep it in sync with scanUser
+const userColumns = `id, COALESCE(google_sub, ''), email, name, picture, display_name,
+			   created_at, last_login_at, updated_at`
+
+// scanUser scans a row selected with userColumns
+func scanUser(row pgx.Row) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(
+		&user.ID,
+		&user.GoogleSub,
+		&user.Email,
+		&user.Name,
+		&user.Picture,
+		&user.DisplayName,
+		&user.CreatedAt,
+		&user.LastLoginAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UserRepository defines the interface for user database operations
+type UserRepository interface {
+	UpsertUser(ctx context.Context, user *models.User) (*models.User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	UpdateUser(ctx context.Context, id uuid.UUID, update *models.UpdateUserRequest) (*models.User, error)
+}
+
+// PostgresUserRepository implements UserRepository using PostgreSQL
+type PostgresUserRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewPostgresUserRepository creates a new PostgreSQL-based user repository
+func NewPostgresUserRepository(db *pgxpool.Pool) *PostgresUserRepository {
+	return &PostgresUserRepository{
+		db: db,
+	}
+}
+
+// UpsertUser records a login. Users are matched by Google subject first, so an email
+// change (say, a new netID alias) keeps their account and notes; a user first seen by
+// email alone, from before subjects were recorded or from being shared a note, is
+// claimed by their subject, and merged into their account if it already has one. Google profile fields and
+// last_login_at are refreshed, and profile fields the user set themselves are kept.
+// The email must already be canonical.
+func (r *PostgresUserRepository) UpsertUser(ctx context.Context, user *models.User) (*models.User, error) {
+	bySubQuery := `
+		UPDATE users
+		SET email = $2, name = $3, picture = $4, last_login_at = NOW()
+		WHERE id = $1
+		RETURNING ` + userColumns
+
+	// A row for the email with a different subject belongs to a different Google account
+	// and is left alone
+	byEmailQuery := `
+		INSERT INTO users (google_sub, email, name, picture, last_login_at)
+		VALUES (NULLIF($1, ''), $2, $3, $4, NOW())
+		ON CONFLICT (email) DO UPDATE
+		SET google_sub = COALESCE(users.google_sub, EXCLUDED.google_sub),
+			name = EXCLUDED.name,
+			picture = EXCLUDED.picture,
+			last_login_at = EXCLUDED.last_login_at
+		WHERE users.google_sub IS NULL OR EXCLUDED.google_sub IS NULL
+		   OR users.google_sub = EXCLUDED.google_sub
+		RETURNING ` + userColumns
+
+	var stored *models.User
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if user.GoogleSub != "" {
+			var id uuid.UUID
+			err := tx.QueryRow(ctx, `SELECT id FROM users WHERE google_sub = $1 FOR UPDATE`, user.GoogleSub).Scan(&id)
+			if err == nil {
+				if err := claimEmail(ctx, tx, id, user.Email); err != nil {
+					return err
+				}
+				stored, err = scanUser(tx.QueryRow(ctx, bySubQuery, id, user.Email, user.Name, user.Picture))
+				if err != nil {
+					return err
+				}
+				// Keep the compatibility copy of the owner's email in step
+				_, err = tx.Exec(ctx, `UPDATE notes SET user_email = $2 WHERE user_id = $1 AND user_email <> $2`,
+					stored.ID, stored.Email)
+				return err
+			}
+			if err != pgx.ErrNoRows {
+				return err
+			}
+		}
+
+		var err error
+
+		stored, err = scanUser(tx.QueryRow(ctx, byEmailQuery, user.GoogleSub, user.Email, user.Name, user.Picture))
+		if err == pgx.ErrNoRows {
+			return ErrEmailTaken
+		}
+		return err
+	})
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		// Another login claimed the email between our check and the write
+		err = ErrEmailTaken
+	}
+	if errors.Is(err, ErrEmailTaken) {
+		slog.Warn("Email already belongs to another Google account", "email", user.Email)
+		return nil, err
+	}
+	if err != nil {
+		slog.Error("Failed to upsert user", "error", err, "email", user.Email)
+		return nil, fmt.Errorf("failed to upsert user: %w", err)
+	}
+
+	slog.Debug("User upserted", "userID", stored.ID, "email", stored.Email)
+	return stored, nil
+}
+
+// mergedUserStatements move everything a user row owns to another user, $1 to $2.
+// Tags the target already has, and shares of notes already shared with the target, are
+// left behind and go with the merged row.
+var mergedUserStatements = []string{
+	`UPDATE notes SET user_id = $2 WHERE user_id = $1`,
+	`UPDATE sessions SET user_id = $2 WHERE user_id = $1`,
+	`UPDATE note_uploads SET user_id = $2 WHERE user_id = $1`,
+	`UPDATE note_tags SET user_id = $2
+	 WHERE user_id = $1 AND NOT EXISTS (
+		SELECT 1 FROM note_tags t WHERE t.note_id = note_tags.note_id AND t.user_id = $2 AND t.tag = note_tags.tag
+	 )`,
+	`UPDATE note_shares SET grantee_user_id = $2
+	 WHERE grantee_user_id = $1 AND NOT EXISTS (
+		SELECT 1 FROM note_shares s WHERE s.note_id = note_shares.note_id AND s.grantee_user_id = $2
+	 )`,
+	`INSERT INTO user_token_generations (user_id, generation)
+	 SELECT $2, generation FROM user_token_generations WHERE user_id = $1
+	 ON CONFLICT (user_id) DO UPDATE
+	 SET generation = GREATEST(user_token_generations.generation, EXCLUDED.generation), updated_at = NOW()`,
+	`DELETE FROM users WHERE id = $1`,
+}
+
+// claimEmail frees email for the user keepID is about to be renamed to. A row holding
+// the email without a Google subject was created for the same person before they signed
+// in (see migrations 010 and 011, and ShareRepository.UpsertShare), and Google has just
+// vouched that the email is theirs, so it is merged into keepID. A row with a subject is another
+// account, and the email is taken.
+func claimEmail(ctx context.Context, tx pgx.Tx, keepID uuid.UUID, email string) error {
+	var otherID uuid.UUID
+	var claimed bool
+	err := tx.QueryRow(ctx,
+		`SELECT id, google_sub IS NOT NULL FROM users WHERE email = $1 AND id <> $2 FOR UPDATE`,
+		email, keepID).Scan(&otherID, &claimed)
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if claimed {
+		return ErrEmailTaken
+	}
+
+	for _, statement := range mergedUserStatements {
+		if _, err := tx.Exec(ctx, statement, otherID, keepID); err != nil {
+			return fmt.Errorf("failed to merge user %s: %w", otherID, err)
+		}
+	}
+
+	slog.Info("Merged user seen before sign-in into their account", "mergedID", otherID, "userID", keepID, "email", email)
+	return nil
+}
+
+// GetUserByID retrieves a user by ID
+func (r *PostgresUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+
+	user, err := scanUser(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		slog.Error("Failed to get user", "error", err, "userID", id)
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// UpdateUser changes the profile fields present in update and returns the updated user
+func (r *PostgresUserRepository) UpdateUser(ctx context.Context, id uuid.UUID, update *models.UpdateUserRequest) (*models.User, error) {
+	// $2 says whether display_name is being changed at all, since NULL is a valid new value
+	query := `
+		UPDATE users
+		SET display_name = CASE WHEN $2 THEN NULLIF($3, '') ELSE display_name END
+		WHERE id = $1
+		RETURNING ` + userColumns
+
+	var displayName string
+	if update.DisplayName != nil {
+		displayName = *update.DisplayName
+	}
+
+	user, err := scanUser(r.db.QueryRow(ctx, query, id, update.DisplayName != nil, displayName))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		slog.Error("Failed to update user", "error", err, "userID", id)
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	slog.Info("User updated", "userID", id)
+	return user, nil
+}
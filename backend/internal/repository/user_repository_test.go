@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/migrations"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newTestDB connects to the database TEST_DATABASE_URL names and migrates it, or skips
+// the test if it is not set. Tests share the database, so they use fresh emails and
+// subjects rather than cleaning up.
+func newTestDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	ctx := context.Background()
+	db, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		t.Fatalf("pgxpool.New() error = %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	runner, err := migrations.NewRunner(db)
+	if err != nil {
+		t.Fatalf("NewRunner() error = %v", err)
+	}
+	if _, err := runner.Up(ctx); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	return db
+}
+
+func TestPostgresUserRepository_UpsertUser_Alias(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	repo := NewPostgresUserRepository(db)
+
+	sub := "sub-" + uuid.NewString()
+	original := uuid.NewString() + "@rice.edu"
+	alias := uuid.NewString() + "@rice.edu"
+
+	account, err := repo.UpsertUser(ctx, &models.User{GoogleSub: sub, Email: original, Name: "Student"})
+	if err != nil {
+		t.Fatalf("UpsertUser() error = %v", err)
+	}
+
+	// The migrations left a row without a subject for the alias, owning a note
+	var seededID uuid.UUID
+	if err := db.QueryRow(ctx, `INSERT INTO users (email) VALUES ($1) RETURNING id`, alias).Scan(&seededID); err != nil {
+		t.Fatalf("seeding user error = %v", err)
+	}
+	var noteID uuid.UUID
+	err = db.QueryRow(ctx, `
+		INSERT INTO notes (user_id, user_email, title, course_id, file_name, file_path, file_size)
+		VALUES ($1, $2, 'Old notes', 'COMP 140', 'a.pdf', 'notes/a.pdf', 8)
+		RETURNING id`, seededID, alias).Scan(&noteID)
+	if err != nil {
+		t.Fatalf("seeding note error = %v", err)
+	}
+
+	// Google now reports the alias for the same account
+	merged, err := repo.UpsertUser(ctx, &models.User{GoogleSub: sub, Email: alias, Name: "Student"})
+	if err != nil {
+		t.Fatalf("UpsertUser(alias) error = %v", err)
+	}
+	if merged.ID != account.ID || merged.Email != alias {
+		t.Errorf("UpsertUser(alias) = %s %s, want %s %s", merged.ID, merged.Email, account.ID, alias)
+	}
+
+	var ownerID uuid.UUID
+	var ownerEmail string
+	if err := db.QueryRow(ctx, `SELECT user_id, user_email FROM notes WHERE id = $1`, noteID).Scan(&ownerID, &ownerEmail); err != nil {
+		t.Fatalf("reading note error = %v", err)
+	}
+	if ownerID != account.ID || ownerEmail != alias {
+		t.Errorf("note owner = %s %s, want %s %s", ownerID, ownerEmail, account.ID, alias)
+	}
+	if _, err := repo.GetUserByID(ctx, seededID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUserByID(merged row) error = %v, want ErrUserNotFound", err)
+	}
+
+	// An email held by another Google account stays theirs
+	other := uuid.NewString() + "@rice.edu"
+	if _, err := repo.UpsertUser(ctx, &models.User{GoogleSub: "sub-" + uuid.NewString(), Email: other}); err != nil {
+		t.Fatalf("UpsertUser(other account) error = %v", err)
+	}
+	if _, err := repo.UpsertUser(ctx, &models.User{GoogleSub: sub, Email: other}); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("UpsertUser(another account's email) error = %v, want ErrEmailTaken", err)
+	}
+}
@@ -3,10 +3,15 @@ package routes
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 
 	"github.com/angel-romero-f/rice-notes/internal/handlers"
+	"github.com/angel-romero-f/rice-notes/internal/infra/jobs"
 	"github.com/angel-romero-f/rice-notes/internal/infra/storage"
 	internal_middleware "github.com/angel-romero-f/rice-notes/internal/middleware"
 	"github.com/angel-romero-f/rice-notes/internal/repository"
@@ -16,12 +21,46 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// Storage backends selectable through RouterConfig.StorageBackend
+const (
+	StorageS3    = "s3"
+	StorageLocal = "local"
+	StorageMock  = "mock"
+)
+
 // RouterConfig contains configuration for setting up the router
 type RouterConfig struct {
 	DB           *pgxpool.Pool
 	S3Bucket     string
 	S3Region     string
 	UseMockS3    bool // For development/testing
+
+	// S3-compatible server settings (MinIO etc.); see storage.S3Config
+	S3Endpoint             string
+	S3UsePathStyle         bool
+	S3AccessKeyID          string
+	S3SecretAccessKey      string
+	S3CreateBucket         bool
+	S3ServerSideEncryption string
+
+	// StorageBackend selects the Uploader: "s3" (default), "local" or "mock".
+	// UseMockS3 takes precedence for backward compatibility.
+	StorageBackend string
+	// LocalStorageDir is the root directory for the local backend
+	LocalStorageDir string
+	// PublicURL is the externally reachable base URL of this server, used to
+	// build signed download links for the local backend
+	PublicURL string
+	// StorageSigningKey signs local download URLs; derived from JWT_SECRET if empty
+	StorageSigningKey string
+
+	// MaxUploadSize is the largest file accepted for upload, in bytes; zero means
+	// services.DefaultMaxFileSize
+	MaxUploadSize int64
+
+	// Jobs receives the handlers for the background jobs the services queue; the
+	// caller runs them with a jobs.Pool. Nil if no workers run in this process.
+	Jobs *jobs.Registry
 }
 
 // NewRouter sets up the routing and their handlers for incoming HTTP requests. Returns
@@ -41,42 +80,69 @@ func NewRouter(config *RouterConfig) (*chi.Mux, error) {
 
 	// Create auth service and handler
 	googleProvider := services.NewGoogleOAuth2Provider(googleClientID, googleClientSecret, redirectURL)
-	authService := services.NewAuthService(googleProvider, jwtSecret)
+	userRepo := repository.NewPostgresUserRepository(config.DB)
+	sessionRepo := repository.NewPostgresSessionRepository(config.DB)
+	revocationRepo := repository.NewPostgresTokenRevocationRepository(config.DB)
+	authService := services.NewAuthService(googleProvider, userRepo, sessionRepo, revocationRepo, jwtSecret)
 	authHandler := handlers.NewAuthHandler(authService)
 
-	// Create S3 uploader (or mock for development)
-	var uploader storage.Uploader
-	var err error
-
-	if config.UseMockS3 {
-		slog.Info("Using mock S3 uploader for development")
-		uploader = storage.NewMockUploader()
-	} else {
-		slog.Info("Initializing S3 uploader", "bucket", config.S3Bucket, "region", config.S3Region)
-		uploader, err = storage.NewS3Uploader(context.Background(), config.S3Bucket, config.S3Region)
-		if err != nil {
-			slog.Error("Failed to initialize S3 uploader", "error", err)
-			return nil, err
-		}
+	// Create the storage backend
+	uploader, err := NewUploader(config, jwtSecret)
+	if err != nil {
+		slog.Error("Failed to initialize storage", "error", err)
+		return nil, err
 	}
 
 	// Create repository layer
 	noteRepo := repository.NewPostgresNoteRepository(config.DB)
+	shareRepo := repository.NewPostgresShareRepository(config.DB)
+	courseRepo := repository.NewPostgresCourseRepository(config.DB)
+	uploadRepo := repository.NewPostgresUploadRepository(config.DB)
 
 	// Create services
-	noteService := services.NewNoteService(noteRepo, uploader)
+	noteService := services.NewNoteService(noteRepo, shareRepo, courseRepo, uploadRepo, uploader, config.MaxUploadSize)
+	userService := services.NewUserService(userRepo)
+	courseService := services.NewCourseService(courseRepo)
+
+	// Register background job handlers
+	if config.Jobs != nil {
+		noteService.RegisterJobs(config.Jobs)
+	}
 
 	// Create handlers  
 	noteHandler := handlers.NewNoteHandler(noteService)
+	userHandler := handlers.NewUserHandler(userService)
+	courseHandler := handlers.NewCourseHandler(courseService)
 
 	// Public routes
 	r.Get("/", noteHandler.Welcome)
 
+	// Signed local file downloads and uploads (authorized by the URL signature, not the JWT)
+	if local, ok := uploader.(*storage.LocalUploader); ok {
+		fileHandler := handlers.NewFileHandler(local)
+		r.Get("/files/*", fileHandler.ServeFile)
+		r.Put("/files/*", fileHandler.UploadFile)
+	}
+
 	// Auth routes (public)
 	r.Route("/api/auth", func(r chi.Router) {
 		r.Get("/google", authHandler.GoogleLogin)
 		r.Get("/google/callback", authHandler.GoogleCallback)
+		r.Post("/token", authHandler.Token)
+		r.Post("/refresh", authHandler.Refresh)
+		r.Post("/logout", authHandler.Logout)
 		r.Get("/me", authHandler.Me)
+
+		// Ending every session requires a valid token
+		r.With(internal_middleware.JWTMiddleware(authService)).Post("/logout-all", authHandler.LogoutEverywhere)
+	})
+
+	// User profile routes (protected)
+	r.Route("/api/users", func(r chi.Router) {
+		r.Use(internal_middleware.JWTMiddleware(authService))
+
+		r.Get("/me", userHandler.GetMe)     // GET /api/users/me - current user's profile
+		r.Patch("/me", userHandler.UpdateMe) // PATCH /api/users/me - update display name
 	})
 
 	// Protected note routes (require JWT authentication)
@@ -87,10 +153,81 @@ func NewRouter(config *RouterConfig) (*chi.Mux, error) {
 		// Note endpoints
 		r.Post("/", noteHandler.CreateNote)           // POST /api/notes - upload PDF
 		r.Get("/", noteHandler.GetNotes)              // GET /api/notes - list user's notes
+		r.Get("/search", noteHandler.SearchNotes)     // GET /api/notes/search - full-text search
+		r.Get("/shared-with-me", noteHandler.GetSharedNotes) // GET /api/notes/shared-with-me - notes shared with user
+		r.Get("/tags", noteHandler.GetTags)           // GET /api/notes/tags - user's tags with counts
+		r.Post("/uploads", noteHandler.CreateUpload)  // POST /api/notes/uploads - start a direct-to-storage upload
+		r.Post("/uploads/{id}/complete", noteHandler.CompleteUpload) // POST /api/notes/uploads/{id}/complete - create the note once uploaded
 		r.Get("/{id}", noteHandler.GetNote)           // GET /api/notes/{id} - get specific note
+		r.Get("/{id}/download", noteHandler.DownloadNote) // GET /api/notes/{id}/download - presigned download
+		r.Get("/{id}/status", noteHandler.GetNoteStatus) // GET /api/notes/{id}/status?wait= - processing status, long-polled
+		r.Patch("/{id}", noteHandler.UpdateNote)      // PATCH /api/notes/{id} - update title, course or visibility
 		r.Delete("/{id}", noteHandler.DeleteNote)     // DELETE /api/notes/{id} - delete note
+		r.Put("/{id}/file", noteHandler.ReplaceNoteFile)  // PUT /api/notes/{id}/file - upload a new revision
+		r.Get("/{id}/versions", noteHandler.GetNoteVersions) // GET /api/notes/{id}/versions - list revisions
+		r.Get("/{id}/versions/{version}/download", noteHandler.DownloadNoteVersion) // GET /api/notes/{id}/versions/{version}/download - download a revision
+		r.Post("/{id}/versions/{version}/restore", noteHandler.RestoreNoteVersion)  // POST /api/notes/{id}/versions/{version}/restore - make a revision current
+		r.Post("/{id}/shares", noteHandler.ShareNote)     // POST /api/notes/{id}/shares - grant or update a share
+		r.Get("/{id}/shares", noteHandler.GetNoteShares)  // GET /api/notes/{id}/shares - list shares
+		r.Delete("/{id}/shares/{email}", noteHandler.RevokeShare) // DELETE /api/notes/{id}/shares/{email} - revoke a share
+		r.Post("/{id}/tags", noteHandler.AddNoteTags)             // POST /api/notes/{id}/tags - add tags
+		r.Delete("/{id}/tags/{tag}", noteHandler.RemoveNoteTag)   // DELETE /api/notes/{id}/tags/{tag} - remove a tag
+	})
+
+	// Course routes (protected)
+	r.Route("/api/courses", func(r chi.Router) {
+		r.Use(internal_middleware.JWTMiddleware(authService))
+
+		r.Get("/", courseHandler.SearchCourses)                    // GET /api/courses?q= - autocomplete from the catalog
+		r.Get("/{courseID}/notes", noteHandler.BrowseCourseNotes) // GET /api/courses/{courseID}/notes - published notes
 	})
 
 	slog.Info("Router initialized successfully")
 	return r, nil
 }
+
+
+// NewUploader creates the storage backend selected by the router configuration.
+// jwtSecret derives the local backend's signing key if none is configured.
+func NewUploader(config *RouterConfig, jwtSecret string) (storage.Uploader, error) {
+	backend := config.StorageBackend
+	if config.UseMockS3 {
+		backend = StorageMock
+	}
+
+	switch backend {
+	case StorageMock:
+		slog.Info("Using mock S3 uploader for development")
+		return storage.NewMockUploader(), nil
+
+	case StorageLocal:
+		signingKey := []byte(config.StorageSigningKey)
+		if len(signingKey) == 0 {
+			if jwtSecret == "" {
+				return nil, errors.New("STORAGE_SIGNING_KEY or JWT_SECRET is required for local storage")
+			}
+			// Derive a separate key so download URLs can't be used to forge JWTs or vice versa
+			mac := hmac.New(sha256.New, []byte(jwtSecret))
+			mac.Write([]byte("rice-notes local storage"))
+			signingKey = mac.Sum(nil)
+		}
+		slog.Info("Initializing local uploader", "dir", config.LocalStorageDir)
+		return storage.NewLocalUploader(config.LocalStorageDir, config.PublicURL+"/files", signingKey)
+
+	case StorageS3, "":
+		slog.Info("Initializing S3 uploader", "bucket", config.S3Bucket, "region", config.S3Region, "endpoint", config.S3Endpoint)
+		return storage.NewS3Uploader(context.Background(), storage.S3Config{
+			Bucket:               config.S3Bucket,
+			Region:               config.S3Region,
+			Endpoint:             config.S3Endpoint,
+			UsePathStyle:         config.S3UsePathStyle,
+			AccessKeyID:          config.S3AccessKeyID,
+			SecretAccessKey:      config.S3SecretAccessKey,
+			CreateBucket:         config.S3CreateBucket,
+			ServerSideEncryption: config.S3ServerSideEncryption,
+		})
+
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
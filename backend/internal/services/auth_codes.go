@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"golang.org/x/oauth2"
+)
+
+// AuthCodeTTL is how long the frontend has to redeem the code from the login redirect
+const AuthCodeTTL = 60 * time.Second
+
+var (
+	// ErrInvalidAuthCode is returned for unknown, used or expired auth codes, and for
+	// codes presented without the verifier of the client that started the login
+	ErrInvalidAuthCode = models.NewError(ErrValidation, "invalid_grant", "login code is invalid or has expired, please sign in again")
+	// ErrInvalidClientChallenge is returned when a login's client challenge is not an S256 PKCE challenge
+	ErrInvalidClientChallenge = models.NewError(ErrValidation, "invalid_code_challenge", "code_challenge must be an S256 PKCE challenge")
+)
+
+// clientChallengePattern matches a base64url SHA-256 digest, the only form of challenge accepted
+var clientChallengePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{43}$`)
+
+// validClientChallenge checks a client's PKCE challenge
+func validClientChallenge(challenge string) bool {
+	return clientChallengePattern.MatchString(challenge)
+}
+
+// issueAuthCode stores a one-time code for a freshly started session. Only its hash is
+// kept, and only the holder of the verifier for clientChallenge can redeem it.
+func (a *AuthService) issueAuthCode(ctx context.Context, result *AuthResult, clientChallenge string) (string, error) {
+	code, err := randomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate auth code: %w", err)
+	}
+
+	err = a.sessions.CreateAuthCode(ctx, &models.AuthCode{
+		CodeHash:        hashRefreshToken(code),
+		SessionID:       result.SessionID,
+		UserEmail:       result.Email,
+		UserName:        result.Name,
+		UserPicture:     result.Picture,
+		ClientChallenge: clientChallenge,
+		ExpiresAt:       time.Now().Add(AuthCodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// ExchangeAuthCode redeems the one-time code from the login redirect for an access token
+// on the login's session. The code is burned on the first attempt, even one with the
+// wrong verifier, so it can't be guessed at.
+func (a *AuthService) ExchangeAuthCode(ctx context.Context, code, codeVerifier string) (*AuthResult, error) {
+	if code == "" || codeVerifier == "" {
+		return nil, ErrInvalidAuthCode
+	}
+
+	authCode, err := a.sessions.ConsumeAuthCode(ctx, hashRefreshToken(code))
+	if errors.Is(err, repository.ErrAuthCodeNotFound) {
+		slog.Warn("Unknown, used or expired auth code presented")
+		return nil, ErrInvalidAuthCode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := oauth2.S256ChallengeFromVerifier(codeVerifier)
+	if subtle.ConstantTimeCompare([]byte(challenge), []byte(authCode.ClientChallenge)) != 1 {
+		slog.Warn("Auth code presented by a different client", "email", authCode.UserEmail)
+		return nil, ErrInvalidAuthCode
+	}
+
+	userInfo, err := a.userInfo(ctx, authCode.UserID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil, ErrInvalidAuthCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	generation, err := a.revocations.GetTokenGeneration(ctx, userInfo.UserID)
+	if err != nil {
+		return nil, err
+	}
+	jwtToken, expiresAt, err := a.generateJWT(userInfo, authCode.SessionID, generation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate JWT: %w", err)
+	}
+
+	slog.Info("Auth code exchanged", "email", userInfo.Email)
+	return &AuthResult{
+		Email:     userInfo.Email,
+		Name:      userInfo.Name,
+		Picture:   userInfo.Picture,
+		JWT:       jwtToken,
+		ExpiresAt: expiresAt,
+		SessionID: authCode.SessionID,
+	}, nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// loginWithChallenge runs a Google login started with the challenge of verifier
+func loginWithChallenge(t *testing.T, service *AuthService, provider *fakeProvider, verifier string) *AuthResult {
+	t.Helper()
+
+	attempt, err := service.BeginGoogleLogin("", oauth2.S256ChallengeFromVerifier(verifier))
+	if err != nil {
+		t.Fatalf("BeginGoogleLogin() error = %v", err)
+	}
+	result, err := service.CompleteGoogleLogin(context.Background(), "code", provider.state, attempt.StateCookie)
+	if err != nil {
+		t.Fatalf("CompleteGoogleLogin() error = %v", err)
+	}
+	return result
+}
+
+func TestExchangeAuthCode(t *testing.T) {
+	ctx := context.Background()
+	provider := &fakeProvider{}
+	service := NewAuthService(provider, newFakeUsers(), newFakeSessions(), newFakeRevocations(), "test-secret")
+	verifier := oauth2.GenerateVerifier()
+
+	login := loginWithChallenge(t, service, provider, verifier)
+	if login.Code == "" {
+		t.Fatal("CompleteGoogleLogin() issued no auth code for a login with a client challenge")
+	}
+
+	result, err := service.ExchangeAuthCode(ctx, login.Code, verifier)
+	if err != nil {
+		t.Fatalf("ExchangeAuthCode() error = %v", err)
+	}
+	claims, err := service.ValidateJWT(ctx, result.JWT)
+	if err != nil {
+		t.Fatalf("ValidateJWT() error = %v", err)
+	}
+	if claims.Email != "student@rice.edu" || claims.SessionID != login.SessionID.String() {
+		t.Errorf("exchanged claims = %+v", claims)
+	}
+
+	if _, err := service.ExchangeAuthCode(ctx, login.Code, verifier); !errors.Is(err, ErrInvalidAuthCode) {
+		t.Errorf("ExchangeAuthCode(reused) error = %v, want ErrInvalidAuthCode", err)
+	}
+}
+
+func TestExchangeAuthCode_Rejects(t *testing.T) {
+	ctx := context.Background()
+	provider := &fakeProvider{}
+	sessions := newFakeSessions()
+	service := NewAuthService(provider, newFakeUsers(), sessions, newFakeRevocations(), "test-secret")
+	verifier := oauth2.GenerateVerifier()
+
+	// Another client's verifier burns the code
+	login := loginWithChallenge(t, service, provider, verifier)
+	if _, err := service.ExchangeAuthCode(ctx, login.Code, oauth2.GenerateVerifier()); !errors.Is(err, ErrInvalidAuthCode) {
+		t.Errorf("ExchangeAuthCode(wrong verifier) error = %v, want ErrInvalidAuthCode", err)
+	}
+	if _, err := service.ExchangeAuthCode(ctx, login.Code, verifier); !errors.Is(err, ErrInvalidAuthCode) {
+		t.Errorf("ExchangeAuthCode(after wrong verifier) error = %v, want ErrInvalidAuthCode", err)
+	}
+
+	// Codes expire
+	login = loginWithChallenge(t, service, provider, verifier)
+	for _, code := range sessions.codes {
+		code.ExpiresAt = time.Now().Add(-time.Second)
+	}
+	if _, err := service.ExchangeAuthCode(ctx, login.Code, verifier); !errors.Is(err, ErrInvalidAuthCode) {
+		t.Errorf("ExchangeAuthCode(expired) error = %v, want ErrInvalidAuthCode", err)
+	}
+
+	// Logins without a challenge get no code
+	attempt, err := service.BeginGoogleLogin("", "")
+	if err != nil {
+		t.Fatalf("BeginGoogleLogin() error = %v", err)
+	}
+	result, err := service.CompleteGoogleLogin(ctx, "code", provider.state, attempt.StateCookie)
+	if err != nil {
+		t.Fatalf("CompleteGoogleLogin() error = %v", err)
+	}
+	if result.Code != "" {
+		t.Error("CompleteGoogleLogin() issued an auth code without a client challenge")
+	}
+
+	if _, err := service.BeginGoogleLogin("", "plain-verifier"); !errors.Is(err, ErrInvalidClientChallenge) {
+		t.Errorf("BeginGoogleLogin(bad challenge) error = %v, want ErrInvalidClientChallenge", err)
+	}
+}
@@ -2,8 +2,7 @@ package services
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/hex"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,15 +11,27 @@ import (
 	"strings"
 	"time"
 
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
 
+var (
+	// ErrNotRiceEmail is returned when someone signs in with a Google account outside rice.edu
+	ErrNotRiceEmail = models.NewError(ErrForbidden, "not_rice_email", "only Rice University emails are allowed")
+	// ErrInvalidGoogleCode is returned when Google rejects the authorization code from the callback
+	ErrInvalidGoogleCode = models.NewError(ErrUnauthorized, "invalid_code", "invalid authorization code")
+	// ErrInvalidToken is returned by ValidateJWT for missing, malformed or expired tokens
+	ErrInvalidToken = models.NewError(ErrUnauthorized, "invalid_token", "invalid or expired token")
+)
+
 // OAuth2Provider defines the interface for OAuth2 operations
 type OAuth2Provider interface {
-	GetAuthURL(state string) string
-	ExchangeCode(ctx context.Context, code string) (*TokenResult, error)
+	GetAuthURL(state, nonce, codeVerifier string) string
+	ExchangeCode(ctx context.Context, code, codeVerifier string) (*TokenResult, error)
 	GetUserInfo(ctx context.Context, accessToken string) (*UserInfo, error)
 }
 
@@ -29,29 +40,46 @@ type TokenResult struct {
 	AccessToken string `json:"access_token"`
 	TokenType   string `json:"token_type"`
 	ExpiresIn   int    `json:"expires_in"`
+	IDToken     string `json:"id_token"`
 }
 
 // UserInfo represents Google user information
 type UserInfo struct {
+	Subject  string `json:"id"` // the same stable ID as the OIDC sub claim
 	Email    string `json:"email"`
 	Name     string `json:"name"`
 	Picture  string `json:"picture"`
 	Verified bool   `json:"email_verified"`
+
+	// UserID is the user's users.id, filled in once the login is recorded
+	UserID uuid.UUID `json:"-"`
 }
 
 // AuthResult represents the result of successful authentication
 type AuthResult struct {
-	Email   string
-	Name    string
-	Picture string
-	JWT     string
+	Email        string
+	Name         string
+	Picture      string
+	JWT          string
+	ExpiresAt    time.Time
+	RefreshToken string
+	ReturnTo     string
+	SessionID    uuid.UUID
+	// Code is the one-time auth code for the redirect, set when the login was started
+	// with a client challenge
+	Code string
 }
 
-// JWTClaims represents the claims in our JWT
+// JWTClaims represents the claims in our JWT. The registered sub claim carries the
+// user's Google subject, and uid their users.id.
 type JWTClaims struct {
-	Email   string `json:"email"`
-	Name    string `json:"name"`
-	Picture string `json:"picture"`
+	Email     string `json:"email"`
+	Name      string `json:"name"`
+	Picture   string `json:"picture"`
+	UserID    string `json:"uid"`
+	SessionID string `json:"sid,omitempty"`
+	// Generation is the user's token generation at issue time; see LogoutEverywhere
+	Generation int `json:"gen"`
 	jwt.RegisteredClaims
 }
 
@@ -73,23 +101,36 @@ func NewGoogleOAuth2Provider(clientID, clientSecret, redirectURL string) *Google
 	return &GoogleOAuth2Provider{config: config}
 }
 
-// GetAuthURL generates the Google OAuth2 authorization URL
-func (g *GoogleOAuth2Provider) GetAuthURL(state string) string {
-	return g.config.AuthCodeURL(state, oauth2.AccessTypeOffline)
+// GetAuthURL generates the Google OAuth2 authorization URL. The nonce is echoed back
+// in the ID token, and the S256 challenge of codeVerifier binds the code to this login.
+func (g *GoogleOAuth2Provider) GetAuthURL(state, nonce, codeVerifier string) string {
+	return g.config.AuthCodeURL(state,
+		oauth2.AccessTypeOffline,
+		oauth2.SetAuthURLParam("nonce", nonce),
+		oauth2.S256ChallengeOption(codeVerifier),
+	)
 }
 
-// ExchangeCode exchanges authorization code for access token
-func (g *GoogleOAuth2Provider) ExchangeCode(ctx context.Context, code string) (*TokenResult, error) {
-	token, err := g.config.Exchange(ctx, code)
+// ExchangeCode exchanges authorization code for access token, proving possession of the PKCE verifier
+func (g *GoogleOAuth2Provider) ExchangeCode(ctx context.Context, code, codeVerifier string) (*TokenResult, error) {
+	token, err := g.config.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
 	if err != nil {
 		slog.Error("Failed to exchange code for token", "error", err)
-		return nil, fmt.Errorf("code exchange failed: %w", err)
+		// Google answers invalid_grant for codes that are unknown, used or expired
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant" {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidGoogleCode, err)
+		}
+		return nil, upstreamError("Google sign-in", err)
 	}
 
+	idToken, _ := token.Extra("id_token").(string)
+
 	return &TokenResult{
 		AccessToken: token.AccessToken,
 		TokenType:   token.TokenType,
 		ExpiresIn:   int(time.Until(token.Expiry).Seconds()),
+		IDToken:     idToken,
 	}, nil
 }
 
@@ -129,78 +170,168 @@ func (g *GoogleOAuth2Provider) GetUserInfo(ctx context.Context, accessToken stri
 
 // AuthService handles authentication operations
 type AuthService struct {
-	provider  OAuth2Provider
-	jwtSecret []byte
+	provider    OAuth2Provider
+	users       repository.UserRepository
+	sessions    repository.SessionRepository
+	revocations repository.TokenRevocationRepository
+	jwtSecret   []byte
 }
 
 // NewAuthService creates a new AuthService instance
-func NewAuthService(provider OAuth2Provider, jwtSecret string) *AuthService {
+func NewAuthService(provider OAuth2Provider, users repository.UserRepository, sessions repository.SessionRepository, revocations repository.TokenRevocationRepository, jwtSecret string) *AuthService {
 	return &AuthService{
-		provider:  provider,
-		jwtSecret: []byte(jwtSecret),
+		provider:    provider,
+		users:       users,
+		sessions:    sessions,
+		revocations: revocations,
+		jwtSecret:   []byte(jwtSecret),
 	}
 }
 
-// GetGoogleAuthURL generates a Google OAuth2 authorization URL with state
-func (a *AuthService) GetGoogleAuthURL(state string) string {
-	if state == "" {
-		// Generate a random state if none provided
-		state = a.generateState()
+// BeginGoogleLogin starts a Google login with a fresh random state, nonce and PKCE
+// verifier. They travel to the callback in the returned signed state cookie, together
+// with returnTo, which the caller must already have checked is a safe destination.
+// A client that wants a one-time auth code instead of relying on cookies passes the
+// S256 challenge of a verifier it keeps; otherwise clientChallenge is empty.
+func (a *AuthService) BeginGoogleLogin(returnTo, clientChallenge string) (*LoginAttempt, error) {
+	if clientChallenge != "" && !validClientChallenge(clientChallenge) {
+		return nil, ErrInvalidClientChallenge
 	}
 
-	url := a.provider.GetAuthURL(state)
-	slog.Info("Generated Google auth URL", "state", state)
-	return url
+	state, err := randomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate state: %w", err)
+	}
+	nonce, err := randomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	s := &loginState{
+		State:           state,
+		Nonce:           nonce,
+		CodeVerifier:    oauth2.GenerateVerifier(),
+		ReturnTo:        returnTo,
+		ClientChallenge: clientChallenge,
+		ExpiresAt:       time.Now().Add(LoginStateTTL).Unix(),
+	}
+	cookie, err := a.signLoginState(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign login state: %w", err)
+	}
+
+	url := a.provider.GetAuthURL(s.State, s.Nonce, s.CodeVerifier)
+	slog.Info("Generated Google auth URL")
+	return &LoginAttempt{AuthURL: url, StateCookie: cookie}, nil
 }
 
-// ExchangeCodeForToken exchanges an authorization code for a JWT token
-func (a *AuthService) ExchangeCodeForToken(ctx context.Context, code string) (*AuthResult, error) {
+// CompleteGoogleLogin finishes a login started by BeginGoogleLogin. It checks the
+// callback's state against the state cookie, exchanges the code using the PKCE
+// verifier, checks the ID token's nonce and issues a JWT.
+func (a *AuthService) CompleteGoogleLogin(ctx context.Context, code, state, stateCookie string) (*AuthResult, error) {
 	slog.Info("Starting code exchange", "code_length", len(code))
 
+	login, err := a.openLoginState(stateCookie, state)
+	if err != nil {
+		slog.Warn("OAuth callback state does not match this browser's login")
+		return nil, err
+	}
+
 	// Exchange code for access token
-	tokenResult, err := a.provider.ExchangeCode(ctx, code)
+	tokenResult, err := a.provider.ExchangeCode(ctx, code, login.CodeVerifier)
 	if err != nil {
 		slog.Error("Code exchange failed", "error", err)
 		return nil, fmt.Errorf("code exchange failed: %w", err)
 	}
 
+	// The ID token came straight from Google's token endpoint over TLS, so its
+	// claims can be trusted without checking the signature (OIDC Core 3.1.3.7)
+	if err := checkIDTokenNonce(tokenResult.IDToken, login.Nonce); err != nil {
+		slog.Warn("ID token nonce check failed", "error", err)
+		return nil, ErrInvalidLoginState
+	}
+
 	// Get user information
 	userInfo, err := a.provider.GetUserInfo(ctx, tokenResult.AccessToken)
 	if err != nil {
 		slog.Error("Failed to get user info", "error", err)
-		return nil, fmt.Errorf("failed to get user info: %w", err)
+		return nil, upstreamError("Google sign-in", err)
+	}
+
+	// Notes are keyed by Google's stable subject ID, so a profile without one can't sign in
+	if userInfo.Subject == "" {
+		slog.Error("Google profile has no subject ID", "email", userInfo.Email)
+		return nil, errors.New("google profile is missing its subject ID")
 	}
 
 	// Validate Rice University email - we only allow @rice.edu domains
+	userInfo.Email = CanonicalEmail(userInfo.Email)
 	if !a.isRiceEmail(userInfo.Email) {
 		slog.Warn("Non-Rice email attempted login", "email", userInfo.Email)
-		return nil, errors.New("only Rice University emails are allowed")
+		return nil, ErrNotRiceEmail
 	}
 
 	// For Rice emails, we trust Google's domain verification and don't require additional email verification
 	slog.Debug("Rice email authenticated", "email", userInfo.Email, "verified", userInfo.Verified)
 
-	// Generate JWT
-	jwtToken, err := a.generateJWT(userInfo)
+	// Record the login; notes are owned by the stored user's ID
+	user, err := a.users.UpsertUser(ctx, &models.User{
+		GoogleSub: userInfo.Subject,
+		Email:     userInfo.Email,
+		Name:      userInfo.Name,
+		Picture:   userInfo.Picture,
+	})
 	if err != nil {
-		slog.Error("Failed to generate JWT", "error", err)
-		return nil, fmt.Errorf("failed to generate JWT: %w", err)
+		slog.Error("Failed to record user", "error", err)
+		return nil, err
+	}
+	userInfo.UserID = user.ID
+
+	// Start a session and issue its first access and refresh tokens
+	result, err := a.startSession(ctx, userInfo)
+	if err != nil {
+		slog.Error("Failed to start session", "error", err)
+		return nil, err
+	}
+	result.ReturnTo = login.ReturnTo
+
+	// Clients that can't rely on the cookies get a one-time code to trade for a token
+	if login.ClientChallenge != "" {
+		code, err := a.issueAuthCode(ctx, result, login.ClientChallenge)
+		if err != nil {
+			slog.Error("Failed to issue auth code", "error", err)
+			return nil, err
+		}
+		result.Code = code
 	}
 
 	slog.Info("Successful authentication", "email", userInfo.Email)
+	return result, nil
+}
 
-	return &AuthResult{
-		Email:   userInfo.Email,
-		Name:    userInfo.Name,
-		Picture: userInfo.Picture,
-		JWT:     jwtToken,
-	}, nil
+// checkIDTokenNonce reads the nonce claim of an ID token and compares it to the expected one
+func checkIDTokenNonce(idToken, nonce string) error {
+	if idToken == "" {
+		return errors.New("token response has no ID token")
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(idToken, claims); err != nil {
+		return fmt.Errorf("failed to parse ID token: %w", err)
+	}
+
+	got, _ := claims["nonce"].(string)
+	if subtle.ConstantTimeCompare([]byte(got), []byte(nonce)) != 1 {
+		return errors.New("ID token nonce does not match")
+	}
+	return nil
 }
 
-// ValidateJWT validates a JWT token and returns claims
+// ValidateJWT validates a JWT token and returns claims. Tokens revoked by logout, or
+// issued before the user last logged out everywhere, are rejected.
 func (a *AuthService) ValidateJWT(ctx context.Context, tokenString string) (*JWTClaims, error) {
 	if tokenString == "" {
-		return nil, errors.New("empty token")
+		return nil, fmt.Errorf("%w: empty token", ErrInvalidToken)
 	}
 
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (any, error) {
@@ -213,19 +344,39 @@ func (a *AuthService) ValidateJWT(ctx context.Context, tokenString string) (*JWT
 
 	if err != nil {
 		slog.Error("JWT validation failed", "error", err)
-		return nil, fmt.Errorf("invalid token: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
 	}
 
 	claims, ok := token.Claims.(*JWTClaims)
 	if !ok || !token.Valid {
 		slog.Error("Invalid JWT claims")
-		return nil, errors.New("invalid token claims")
+		return nil, fmt.Errorf("%w: invalid token claims", ErrInvalidToken)
 	}
 
 	// Check if token is expired
 	if time.Now().Unix() > claims.ExpiresAt.Unix() {
 		slog.Warn("Expired JWT token", "email", claims.Email)
-		return nil, errors.New("token expired")
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+
+	jti, err := uuid.Parse(claims.ID)
+	if err != nil {
+		slog.Warn("JWT without a valid jti", "email", claims.Email)
+		return nil, fmt.Errorf("%w: invalid token claims", ErrInvalidToken)
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		// Issued before users were keyed by ID; the client refreshes or signs in again
+		slog.Warn("JWT without a user ID", "email", claims.Email)
+		return nil, fmt.Errorf("%w: invalid token claims", ErrInvalidToken)
+	}
+	revoked, err := a.revocations.IsTokenRevoked(ctx, jti, userID, claims.Generation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked {
+		slog.Warn("Revoked JWT token", "email", claims.Email)
+		return nil, ErrTokenRevoked
 	}
 
 	slog.Debug("JWT validation successful", "email", claims.Email)
@@ -234,6 +385,11 @@ func (a *AuthService) ValidateJWT(ctx context.Context, tokenString string) (*JWT
 
 // isRiceEmail checks if an email belongs to Rice University
 func (a *AuthService) isRiceEmail(email string) bool {
+	return isRiceEmail(email)
+}
+
+// isRiceEmail checks if an email belongs to Rice University
+func isRiceEmail(email string) bool {
 	if email == "" {
 		return false
 	}
@@ -245,16 +401,21 @@ func (a *AuthService) isRiceEmail(email string) bool {
 	return strings.HasSuffix(email, "@rice.edu") || strings.Contains(email, ".rice.edu")
 }
 
-// generateJWT creates a JWT token for the authenticated user
-func (a *AuthService) generateJWT(userInfo *UserInfo) (string, error) {
-	// Token expires in 24 hours
-	expirationTime := time.Now().Add(24 * time.Hour)
+// generateJWT creates a short-lived access token for a user's session. Each token gets
+// its own jti so it can be revoked on its own.
+func (a *AuthService) generateJWT(userInfo *UserInfo, sessionID uuid.UUID, generation int) (string, time.Time, error) {
+	expirationTime := time.Now().Add(AccessTokenTTL)
 
 	claims := &JWTClaims{
-		Email:   userInfo.Email,
-		Name:    userInfo.Name,
-		Picture: userInfo.Picture,
+		Email:      userInfo.Email,
+		Name:       userInfo.Name,
+		Picture:    userInfo.Picture,
+		UserID:     userInfo.UserID.String(),
+		SessionID:  sessionID.String(),
+		Generation: generation,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   userInfo.Subject,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "rice-notes",
@@ -264,19 +425,9 @@ func (a *AuthService) generateJWT(userInfo *UserInfo) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString(a.jwtSecret)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign JWT: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to sign JWT: %w", err)
 	}
 
 	slog.Debug("Generated JWT token", "email", userInfo.Email, "expires", expirationTime)
-	return tokenString, nil
-}
-
-// generateState generates a random state string for OAuth2 flow
-func (a *AuthService) generateState() string {
-	bytes := make([]byte, 16)
-	if _, err := rand.Read(bytes); err != nil {
-		// Fallback to timestamp-based state if random generation fails
-		return fmt.Sprintf("state_%d", time.Now().UnixNano())
-	}
-	return hex.EncodeToString(bytes)
+	return tokenString, expirationTime, nil
 }
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+)
+
+const (
+	// MaxCourseSearchResults caps how many courses autocomplete returns
+	MaxCourseSearchResults = 50
+	// DefaultCourseSearchResults is how many courses autocomplete returns by default
+	DefaultCourseSearchResults = 10
+)
+
+// Catalog file formats accepted by LoadCourses
+const (
+	CatalogFormatCSV  = "csv"
+	CatalogFormatJSON = "json"
+)
+
+// courseCodePattern matches a Rice course code in any of the spellings students type:
+// "COMP 140", "comp140", "Comp-140"
+var courseCodePattern = regexp.MustCompile(`^([A-Za-z]{4})[\s_-]*([0-9]{3})$`)
+
+// CourseCode is a parsed Rice course code: a four-letter subject and a three-digit number
+type CourseCode struct {
+	Subject string
+	Number  string
+}
+
+// String returns the canonical form of the code, such as "COMP 140"
+func (c CourseCode) String() string {
+	return c.Subject + " " + c.Number
+}
+
+// ParseCourseCode parses a Rice course code, accepting any case and a space, hyphen,
+// underscore or nothing between subject and number
+func ParseCourseCode(raw string) (CourseCode, error) {
+	match := courseCodePattern.FindStringSubmatch(strings.TrimSpace(raw))
+	if match == nil {
+		return CourseCode{}, invalidf("course_id", "%q is not a Rice course code like COMP 140", raw)
+	}
+	return CourseCode{Subject: strings.ToUpper(match[1]), Number: match[2]}, nil
+}
+
+// CanonicalCourseID returns the canonical form of a course ID if it parses as a course
+// code, and the ID unchanged otherwise, so filters keep matching notes filed under
+// IDs that predate the catalog
+func CanonicalCourseID(courseID string) string {
+	code, err := ParseCourseCode(courseID)
+	if err != nil {
+		return courseID
+	}
+	return code.String()
+}
+
+// resolveCourse parses a course ID and looks it up in the catalog, returning the code
+// notes are filed under. A cross-listed code resolves to the course's primary code.
+func resolveCourse(ctx context.Context, courses repository.CourseRepository, courseID string) (string, error) {
+	code, err := ParseCourseCode(courseID)
+	if err != nil {
+		return "", err
+	}
+
+	course, err := courses.GetCourse(ctx, code.String())
+	if errors.Is(err, repository.ErrCourseNotFound) {
+		return "", invalidf("course_id", "%s is not in the course catalog", code)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up course: %w", err)
+	}
+
+	return course.Code, nil
+}
+
+// CourseService handles course catalog lookups and imports
+type CourseService struct {
+	courses repository.CourseRepository
+}
+
+// NewCourseService creates a new course service instance
+func NewCourseService(courses repository.CourseRepository) *CourseService {
+	return &CourseService{courses: courses}
+}
+
+// SearchCourses returns catalog courses whose code starts with query or whose title
+// contains it, for autocomplete
+func (s *CourseService) SearchCourses(ctx context.Context, query string, limit int) ([]*models.Course, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, invalidf("q", "search query is required")
+	}
+	if len(query) > MaxSearchQueryLength {
+		return nil, invalidf("q", "search query must be %d characters or less", MaxSearchQueryLength)
+	}
+
+	if limit <= 0 {
+		limit = DefaultCourseSearchResults
+	}
+	if limit > MaxCourseSearchResults {
+		limit = MaxCourseSearchResults
+	}
+
+	courses, err := s.courses.SearchCourses(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search courses: %w", err)
+	}
+
+	return courses, nil
+}
+
+// ImportCourses loads a catalog file and adds its courses to the catalog, updating
+// ones already there. Nothing is imported if any entry is invalid.
+func (s *CourseService) ImportCourses(ctx context.Context, r io.Reader, format string) (int, error) {
+	courses, err := LoadCourses(r, format)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.courses.UpsertCourses(ctx, courses); err != nil {
+		return 0, err
+	}
+
+	slog.Info("Course catalog imported", "count", len(courses))
+	return len(courses), nil
+}
+
+// catalogEntry is one course as it appears in a catalog file. A course is given
+// either by code or by subject and number.
+type catalogEntry struct {
+	Code          string   `json:"code"`
+	Subject       string   `json:"subject"`
+	Number        string   `json:"number"`
+	Title         string   `json:"title"`
+	Term          string   `json:"term"`
+	CrossListings []string `json:"cross_listings"`
+}
+
+// LoadCourses reads a catalog file and returns its courses with canonical codes.
+//
+// JSON files hold an array of objects with code (or subject and number), title, term
+// and cross_listings. CSV files have a header row naming the same columns, with
+// cross-listings separated by semicolons.
+func LoadCourses(r io.Reader, format string) ([]*models.Course, error) {
+	var entries []catalogEntry
+	var err error
+	switch format {
+	case CatalogFormatJSON:
+		err = json.NewDecoder(r).Decode(&entries)
+	case CatalogFormatCSV:
+		entries, err = readCatalogCSV(r)
+	default:
+		return nil, fmt.Errorf("unsupported catalog format %q (want %s or %s)", format, CatalogFormatCSV, CatalogFormatJSON)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+
+	courses := make([]*models.Course, 0, len(entries))
+	seen := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		course, err := entry.course()
+		if err != nil {
+			return nil, fmt.Errorf("catalog entry %d: %w", i+1, err)
+		}
+		if first, ok := seen[course.Code]; ok {
+			return nil, fmt.Errorf("catalog entry %d: %s is already listed in entry %d", i+1, course.Code, first)
+		}
+		seen[course.Code] = i + 1
+		courses = append(courses, course)
+	}
+
+	return courses, nil
+}
+
+// course validates a catalog entry and canonicalizes its codes
+func (e catalogEntry) course() (*models.Course, error) {
+	raw := e.Code
+	if raw == "" {
+		raw = e.Subject + " " + e.Number
+	}
+	code, err := ParseCourseCode(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	title := strings.TrimSpace(e.Title)
+	if title == "" {
+		return nil, invalidf("title", "%s has no title", code)
+	}
+	if len(title) > 255 {
+		return nil, invalidf("title", "%s title must be 255 characters or less", code)
+	}
+	term := strings.TrimSpace(e.Term)
+	if len(term) > 50 {
+		return nil, invalidf("term", "%s term must be 50 characters or less", code)
+	}
+
+	crossListings := []string{}
+	for _, raw := range e.CrossListings {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		crossListed, err := ParseCourseCode(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s cross-listing: %w", code, err)
+		}
+		if crossListed != code {
+			crossListings = append(crossListings, crossListed.String())
+		}
+	}
+
+	return &models.Course{
+		Code:          code.String(),
+		Subject:       code.Subject,
+		Number:        code.Number,
+		Title:         title,
+		Term:          term,
+		CrossListings: crossListings,
+	}, nil
+}
+
+// readCatalogCSV reads catalog entries from CSV with a header row
+func readCatalogCSV(r io.Reader) ([]catalogEntry, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("missing header row: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("header has no title column")
+	}
+	_, hasCode := columns["code"]
+	_, hasSubject := columns["subject"]
+	_, hasNumber := columns["number"]
+	if !hasCode && !(hasSubject && hasNumber) {
+		return nil, errors.New("header needs a code column or subject and number columns")
+	}
+
+	var entries []catalogEntry
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		entry := catalogEntry{
+			Code:    field("code"),
+			Subject: field("subject"),
+			Number:  field("number"),
+			Title:   field("title"),
+			Term:    field("term"),
+		}
+		if crossListings := field("cross_listings"); crossListings != "" {
+			entry.CrossListings = strings.Split(crossListings, ";")
+		}
+		entries = append(entries, entry)
+	}
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+)
+
+// fakeCourses is an in-memory CourseRepository
+type fakeCourses struct {
+	byCode map[string]*models.Course
+}
+
+func (f *fakeCourses) GetCourse(ctx context.Context, code string) (*models.Course, error) {
+	for _, course := range f.byCode {
+		if course.Code == code || slices.Contains(course.CrossListings, code) {
+			return course, nil
+		}
+	}
+	return nil, repository.ErrCourseNotFound
+}
+
+func (f *fakeCourses) SearchCourses(ctx context.Context, query string, limit int) ([]*models.Course, error) {
+	return nil, nil
+}
+
+func (f *fakeCourses) UpsertCourses(ctx context.Context, courses []*models.Course) error {
+	for _, course := range courses {
+		f.byCode[course.Code] = course
+	}
+	return nil
+}
+
+func TestParseCourseCode(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{raw: "COMP 140", want: "COMP 140"},
+		{raw: "comp140", want: "COMP 140"},
+		{raw: "Comp-140", want: "COMP 140"},
+		{raw: "  elec_220 ", want: "ELEC 220"},
+		{raw: "COMP  140", want: "COMP 140"},
+		{raw: "COMP 14", wantErr: true},
+		{raw: "COMP 1400", wantErr: true},
+		{raw: "CS 140", wantErr: true},
+		{raw: "Intro to Computation", wantErr: true},
+		{raw: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		code, err := ParseCourseCode(tt.raw)
+		if tt.wantErr {
+			if !errors.Is(err, ErrValidation) {
+				t.Errorf("ParseCourseCode(%q) error = %v, want %v", tt.raw, err, ErrValidation)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseCourseCode(%q) error = %v", tt.raw, err)
+			continue
+		}
+		if code.String() != tt.want {
+			t.Errorf("ParseCourseCode(%q) = %q, want %q", tt.raw, code, tt.want)
+		}
+	}
+}
+
+func TestLoadCourses(t *testing.T) {
+	csvCatalog := `code,title,term,cross_listings
+comp140,Computational Thinking,Fall 2025,
+COMP 220,Fundamentals of Computer Engineering,Fall 2025,elec-220;COMP 220
+`
+	jsonCatalog := `[
+		{"subject": "comp", "number": "140", "title": "Computational Thinking", "term": "Fall 2025"},
+		{"code": "COMP 220", "title": "Fundamentals of Computer Engineering", "cross_listings": ["ELEC220"]}
+	]`
+
+	for format, catalog := range map[string]string{CatalogFormatCSV: csvCatalog, CatalogFormatJSON: jsonCatalog} {
+		courses, err := LoadCourses(strings.NewReader(catalog), format)
+		if err != nil {
+			t.Fatalf("LoadCourses(%s) error = %v", format, err)
+		}
+		if len(courses) != 2 {
+			t.Fatalf("LoadCourses(%s) returned %d courses, want 2", format, len(courses))
+		}
+		if c := courses[0]; c.Code != "COMP 140" || c.Subject != "COMP" || c.Number != "140" || c.Title != "Computational Thinking" {
+			t.Errorf("LoadCourses(%s)[0] = %+v", format, c)
+		}
+		if c := courses[1]; !slices.Equal(c.CrossListings, []string{"ELEC 220"}) {
+			t.Errorf("LoadCourses(%s)[1].CrossListings = %v, want [ELEC 220]", format, c.CrossListings)
+		}
+	}
+
+	invalid := map[string]string{
+		"bad code":        "code,title\nCOMP14,Intro\n",
+		"missing title":   "code,title\nCOMP 140,\n",
+		"duplicate":       "code,title\nCOMP 140,Intro\ncomp-140,Intro again\n",
+		"no code columns": "name,title\nCOMP 140,Intro\n",
+	}
+	for name, catalog := range invalid {
+		if _, err := LoadCourses(strings.NewReader(catalog), CatalogFormatCSV); err == nil {
+			t.Errorf("LoadCourses(%s) succeeded, want error", name)
+		}
+	}
+
+	if _, err := LoadCourses(strings.NewReader(csvCatalog), "xml"); err == nil {
+		t.Error("LoadCourses(xml) succeeded, want error")
+	}
+}
+
+func TestResolveCourse(t *testing.T) {
+	ctx := context.Background()
+	courses := &fakeCourses{byCode: map[string]*models.Course{
+		"COMP 220": {Code: "COMP 220", CrossListings: []string{"ELEC 220"}},
+	}}
+
+	tests := []struct {
+		courseID string
+		want     string
+		wantErr  bool
+	}{
+		{courseID: "comp-220", want: "COMP 220"},
+		{courseID: "ELEC 220", want: "COMP 220"},
+		{courseID: "COMP 140", wantErr: true},
+		{courseID: "Intro to Computation", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveCourse(ctx, courses, tt.courseID)
+		if tt.wantErr {
+			if !errors.Is(err, ErrValidation) {
+				t.Errorf("resolveCourse(%q) error = %v, want %v", tt.courseID, err, ErrValidation)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolveCourse(%q) = %q, %v, want %q", tt.courseID, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+)
+
+// anonymousAuthor is shown for notes uploaded before display names were recorded
+const anonymousAuthor = "Rice student"
+
+// BrowseCourseNotes lists notes other students have published for a course, newest
+// first. Pass the NextCursor of the previous page to continue from it.
+func (s *NoteService) BrowseCourseNotes(ctx context.Context, caller Caller, courseID, cursor string, limit int) (*models.CourseNotesPage, error) {
+	if courseID == "" {
+		return nil, invalidf("course_id", "course ID is required")
+	}
+	courseID = CanonicalCourseID(courseID)
+
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply reasonable limits
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	notes, err := s.repo.GetPublishedNotesByCourse(ctx, courseID, caller.UserID, after, limit+1)
+	if err != nil {
+		slog.Error("Failed to browse course notes", "error", err, "courseID", courseID)
+		return nil, fmt.Errorf("failed to get course notes: %w", err)
+	}
+
+	page := &models.CourseNotesPage{Items: make([]*models.CourseNote, 0, min(len(notes), limit))}
+	if len(notes) > limit {
+		notes = notes[:limit]
+		last := notes[limit-1]
+		page.NextCursor = encodeCursor(&repository.Cursor{UploadedAt: last.UploadedAt, ID: last.ID})
+	}
+
+	for _, note := range notes {
+		author := note.AuthorName
+		if author == "" {
+			author = anonymousAuthor
+		}
+		page.Items = append(page.Items, &models.CourseNote{
+			ID:         note.ID,
+			Title:      note.Title,
+			CourseID:   note.CourseID,
+			FileName:   note.FileName,
+			FileSize:   note.FileSize,
+			PageCount:  note.PageCount,
+			Visibility: note.Visibility,
+			AuthorName: author,
+			UploadedAt: note.UploadedAt,
+		})
+	}
+
+	return page, nil
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+)
+
+// Error kinds returned by the services; see models.Error. Handlers map each kind to
+// an HTTP status.
+var (
+	ErrNotFound           = models.ErrNotFound
+	ErrForbidden          = models.ErrForbidden
+	ErrUnauthorized       = models.ErrUnauthorized
+	ErrValidation         = models.ErrValidation
+	ErrConflict           = models.ErrConflict
+	ErrPreconditionFailed = models.ErrPreconditionFailed
+	ErrUpstream           = models.ErrUpstream
+)
+
+// invalidf returns a validation error for field, or for the request as a whole when
+// field is empty; see models.Invalid
+func invalidf(field, format string, args ...any) error {
+	return models.Invalid(field, fmt.Sprintf(format, args...))
+}
+
+// upstreamError reports a failure of a service we depend on, keeping the cause for the
+// logs but showing the client only which service is unavailable
+func upstreamError(service string, err error) error {
+	return &models.Error{
+		Kind:    ErrUpstream,
+		Code:    "upstream_unavailable",
+		Message: service + " is unavailable, please try again later",
+		Err:     err,
+	}
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Caller identifies the signed-in user a request is made for
+type Caller struct {
+	// UserID is the caller's users.id, the key notes are owned by
+	UserID uuid.UUID
+	// Email is the caller's canonical email, which shares are granted to
+	Email string
+}
+
+// Caller returns the user a validated token was issued to
+func (c *JWTClaims) Caller() Caller {
+	userID, _ := uuid.Parse(c.UserID)
+	return Caller{UserID: userID, Email: CanonicalEmail(c.Email)}
+}
+
+// CanonicalEmail returns the form emails are stored and compared in, so that
+// Foo@rice.edu and foo@rice.edu are the same user
+func CanonicalEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
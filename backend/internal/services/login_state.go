@@ -0,0 +1,105 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+)
+
+// LoginStateTTL is how long a user has to complete the Google login after starting it
+const LoginStateTTL = 10 * time.Minute
+
+// ErrInvalidLoginState is returned when the OAuth callback cannot be tied to a login
+// this browser started: the state cookie is missing, forged or expired, or the state
+// or nonce don't match it
+var ErrInvalidLoginState = models.NewError(ErrValidation, "invalid_state", "login session is invalid or has expired, please sign in again")
+
+// LoginAttempt is a started Google login. StateCookie must be stored in the browser
+// and handed back to CompleteGoogleLogin with the callback.
+type LoginAttempt struct {
+	AuthURL     string
+	StateCookie string
+}
+
+// loginState is what the state cookie carries between the redirect to Google and the callback
+type loginState struct {
+	State        string `json:"s"`
+	Nonce        string `json:"n"`
+	CodeVerifier string `json:"v"`
+	ReturnTo     string `json:"r,omitempty"`
+	// ClientChallenge is the frontend's PKCE challenge for the one-time auth code
+	ClientChallenge string `json:"c,omitempty"`
+	ExpiresAt       int64  `json:"e"`
+}
+
+// randomToken returns n random bytes, base64url-encoded
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// stateKey derives the key for signing state cookies, so it never doubles as the JWT key
+func (a *AuthService) stateKey() []byte {
+	mac := hmac.New(sha256.New, a.jwtSecret)
+	mac.Write([]byte("rice-notes oauth state"))
+	return mac.Sum(nil)
+}
+
+// signLoginState serializes and signs a login state as "payload.signature"
+func (a *AuthService) signLoginState(s *loginState) (string, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, a.stateKey())
+	mac.Write([]byte(encoded))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// openLoginState verifies a state cookie and checks it belongs to the callback's state
+func (a *AuthService) openLoginState(cookie, state string) (*loginState, error) {
+	encoded, signature, ok := strings.Cut(cookie, ".")
+	if !ok {
+		return nil, ErrInvalidLoginState
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, ErrInvalidLoginState
+	}
+	mac := hmac.New(sha256.New, a.stateKey())
+	mac.Write([]byte(encoded))
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return nil, ErrInvalidLoginState
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidLoginState
+	}
+	var s loginState
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return nil, ErrInvalidLoginState
+	}
+
+	if time.Now().Unix() > s.ExpiresAt {
+		return nil, ErrInvalidLoginState
+	}
+	if subtle.ConstantTimeCompare([]byte(s.State), []byte(state)) != 1 {
+		return nil, ErrInvalidLoginState
+	}
+
+	return &s, nil
+}
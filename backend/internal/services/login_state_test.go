@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeProvider plays Google: it remembers the nonce and verifier of the last
+// authorization URL and echoes them back like the real token endpoint would
+type fakeProvider struct {
+	state    string
+	nonce    string
+	verifier string
+	idNonce  string
+	// user overrides the profile GetUserInfo returns
+	user *UserInfo
+}
+
+func (f *fakeProvider) GetAuthURL(state, nonce, codeVerifier string) string {
+	f.state, f.nonce, f.verifier = state, nonce, codeVerifier
+	return "https://accounts.google.com/o/oauth2/auth?state=" + state
+}
+
+func (f *fakeProvider) ExchangeCode(ctx context.Context, code, codeVerifier string) (*TokenResult, error) {
+	if codeVerifier != f.verifier {
+		return nil, errors.New("invalid_grant: code verifier mismatch")
+	}
+	nonce := f.nonce
+	if f.idNonce != "" {
+		nonce = f.idNonce
+	}
+	idToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"nonce": nonce}).SignedString([]byte("google"))
+	return &TokenResult{AccessToken: "access", IDToken: idToken}, nil
+}
+
+func (f *fakeProvider) GetUserInfo(ctx context.Context, accessToken string) (*UserInfo, error) {
+	if f.user != nil {
+		user := *f.user
+		return &user, nil
+	}
+	return &UserInfo{Subject: "google-sub-1", Email: "student@rice.edu", Name: "Student"}, nil
+}
+
+func TestGoogleLoginFlow(t *testing.T) {
+	provider := &fakeProvider{}
+	service := NewAuthService(provider, newFakeUsers(), newFakeSessions(), newFakeRevocations(), "test-secret")
+
+	attempt, err := service.BeginGoogleLogin("/notes/123", "")
+	if err != nil {
+		t.Fatalf("BeginGoogleLogin() error = %v", err)
+	}
+	if provider.state == "" || provider.nonce == "" || len(provider.verifier) < 43 {
+		t.Fatalf("BeginGoogleLogin() did not pass state, nonce and verifier to the provider")
+	}
+
+	result, err := service.CompleteGoogleLogin(context.Background(), "code", provider.state, attempt.StateCookie)
+	if err != nil {
+		t.Fatalf("CompleteGoogleLogin() error = %v", err)
+	}
+	if result.JWT == "" || result.ReturnTo != "/notes/123" {
+		t.Errorf("CompleteGoogleLogin() = %+v", result)
+	}
+}
+
+func TestGoogleLoginFlow_Rejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(provider *fakeProvider, state, cookie *string)
+	}{
+		{
+			name:   "missing cookie",
+			tamper: func(p *fakeProvider, state, cookie *string) { *cookie = "" },
+		},
+		{
+			name:   "state from another login",
+			tamper: func(p *fakeProvider, state, cookie *string) { *state = "attacker-state" },
+		},
+		{
+			name: "forged cookie",
+			tamper: func(p *fakeProvider, state, cookie *string) {
+				payload, sig, _ := strings.Cut(*cookie, ".")
+				*cookie = payload + "x." + sig
+			},
+		},
+		{
+			name:   "wrong nonce in ID token",
+			tamper: func(p *fakeProvider, state, cookie *string) { p.idNonce = "replayed" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &fakeProvider{}
+			service := NewAuthService(provider, newFakeUsers(), newFakeSessions(), newFakeRevocations(), "test-secret")
+
+			attempt, err := service.BeginGoogleLogin("", "")
+			if err != nil {
+				t.Fatalf("BeginGoogleLogin() error = %v", err)
+			}
+
+			state, cookie := provider.state, attempt.StateCookie
+			tt.tamper(provider, &state, &cookie)
+
+			if _, err := service.CompleteGoogleLogin(context.Background(), "code", state, cookie); !errors.Is(err, ErrInvalidLoginState) {
+				t.Errorf("CompleteGoogleLogin() error = %v, want ErrInvalidLoginState", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ErrTokenRevoked is returned by ValidateJWT for tokens ended by a logout
+var ErrTokenRevoked = models.NewError(ErrUnauthorized, "token_revoked", "token has been revoked")
+
+// Logout ends one login. The access token described by claims is revoked for the rest
+// of its lifetime, and the refresh tokens of its session are revoked with it. Either
+// argument may be missing, e.g. when the access token has already expired but the
+// refresh cookie is still there.
+func (a *AuthService) Logout(ctx context.Context, claims *JWTClaims, refreshToken string) error {
+	if claims != nil {
+		jti, err := uuid.Parse(claims.ID)
+		if err != nil {
+			return fmt.Errorf("invalid token id: %w", err)
+		}
+		if err := a.revocations.RevokeToken(ctx, jti, claims.Email, claims.ExpiresAt.Time); err != nil {
+			return err
+		}
+
+		if sessionID, err := uuid.Parse(claims.SessionID); err == nil {
+			if err := a.sessions.RevokeSessionFamilyOf(ctx, sessionID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if refreshToken != "" {
+		session, err := a.sessions.GetSessionByTokenHash(ctx, hashRefreshToken(refreshToken))
+		switch {
+		case errors.Is(err, repository.ErrSessionNotFound):
+			// Nothing to revoke
+		case err != nil:
+			return err
+		default:
+			if err := a.sessions.RevokeSessionFamily(ctx, session.FamilyID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if claims != nil {
+		slog.Info("User logged out", "email", claims.Email)
+	}
+	return nil
+}
+
+// LogoutEverywhere ends every login a user has. Bumping the token generation invalidates
+// all access tokens issued so far, and all refresh tokens are revoked so none can be
+// traded for a token in the new generation. Both are keyed by the user's ID, so logins
+// made under an email the user has since changed end too.
+func (a *AuthService) LogoutEverywhere(ctx context.Context, caller Caller) error {
+	if _, err := a.revocations.BumpTokenGeneration(ctx, caller.UserID); err != nil {
+		return err
+	}
+	if err := a.sessions.RevokeUserSessions(ctx, caller.UserID); err != nil {
+		return err
+	}
+
+	slog.Info("User logged out everywhere", "userID", caller.UserID, "email", caller.Email)
+	return nil
+}
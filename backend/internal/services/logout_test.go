@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeRevocations is an in-memory TokenRevocationRepository
+type fakeRevocations struct {
+	revoked     map[uuid.UUID]time.Time
+	generations map[uuid.UUID]int
+}
+
+func newFakeRevocations() *fakeRevocations {
+	return &fakeRevocations{
+		revoked:     make(map[uuid.UUID]time.Time),
+		generations: make(map[uuid.UUID]int),
+	}
+}
+
+func (f *fakeRevocations) RevokeToken(ctx context.Context, jti uuid.UUID, userEmail string, expiresAt time.Time) error {
+	f.revoked[jti] = expiresAt
+	return nil
+}
+
+func (f *fakeRevocations) IsTokenRevoked(ctx context.Context, jti, userID uuid.UUID, generation int) (bool, error) {
+	if expiresAt, ok := f.revoked[jti]; ok && time.Now().Before(expiresAt) {
+		return true, nil
+	}
+	return f.generations[userID] > generation, nil
+}
+
+func (f *fakeRevocations) GetTokenGeneration(ctx context.Context, userID uuid.UUID) (int, error) {
+	return f.generations[userID], nil
+}
+
+func (f *fakeRevocations) BumpTokenGeneration(ctx context.Context, userID uuid.UUID) (int, error) {
+	f.generations[userID]++
+	return f.generations[userID], nil
+}
+
+func TestLogout(t *testing.T) {
+	ctx := context.Background()
+	service := NewAuthService(&fakeProvider{}, newFakeUsers(), newFakeSessions(), newFakeRevocations(), "test-secret")
+
+	login, err := service.startSession(ctx, &UserInfo{Email: "student@rice.edu"})
+	if err != nil {
+		t.Fatalf("startSession() error = %v", err)
+	}
+	other, err := service.startSession(ctx, &UserInfo{Email: "student@rice.edu"})
+	if err != nil {
+		t.Fatalf("startSession() error = %v", err)
+	}
+
+	claims, err := service.ValidateJWT(ctx, login.JWT)
+	if err != nil {
+		t.Fatalf("ValidateJWT() error = %v", err)
+	}
+	if err := service.Logout(ctx, claims, ""); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+
+	if _, err := service.ValidateJWT(ctx, login.JWT); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("ValidateJWT(logged out) error = %v, want ErrTokenRevoked", err)
+	}
+	if _, err := service.RefreshSession(ctx, login.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RefreshSession(logged out) error = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	// Other logins are untouched
+	if _, err := service.ValidateJWT(ctx, other.JWT); err != nil {
+		t.Errorf("ValidateJWT(other session) error = %v", err)
+	}
+}
+
+func TestLogoutEverywhere(t *testing.T) {
+	ctx := context.Background()
+	service := NewAuthService(&fakeProvider{}, newFakeUsers(), newFakeSessions(), newFakeRevocations(), "test-secret")
+
+	userID := uuid.New()
+	first, err := service.startSession(ctx, &UserInfo{Email: "student@rice.edu", UserID: userID})
+	if err != nil {
+		t.Fatalf("startSession() error = %v", err)
+	}
+	// A login from before Google reported a new alias for the account
+	second, err := service.startSession(ctx, &UserInfo{Email: "old.alias@rice.edu", UserID: userID})
+	if err != nil {
+		t.Fatalf("startSession() error = %v", err)
+	}
+	other, err := service.startSession(ctx, &UserInfo{Email: "other@rice.edu", UserID: uuid.New()})
+	if err != nil {
+		t.Fatalf("startSession() error = %v", err)
+	}
+
+	if err := service.LogoutEverywhere(ctx, Caller{UserID: userID, Email: "student@rice.edu"}); err != nil {
+		t.Fatalf("LogoutEverywhere() error = %v", err)
+	}
+
+	for _, login := range []*AuthResult{first, second} {
+		if _, err := service.ValidateJWT(ctx, login.JWT); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("ValidateJWT() error = %v, want ErrTokenRevoked", err)
+		}
+		if _, err := service.RefreshSession(ctx, login.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("RefreshSession() error = %v, want ErrInvalidRefreshToken", err)
+		}
+	}
+
+	// Other users are untouched
+	if _, err := service.ValidateJWT(ctx, other.JWT); err != nil {
+		t.Errorf("ValidateJWT(other user) error = %v", err)
+	}
+
+	// Logging in again issues tokens in the new generation
+	again, err := service.startSession(ctx, &UserInfo{Email: "student@rice.edu", UserID: userID})
+	if err != nil {
+		t.Fatalf("startSession() error = %v", err)
+	}
+	if _, err := service.ValidateJWT(ctx, again.JWT); err != nil {
+		t.Errorf("ValidateJWT(new login) error = %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/infra/jobs"
+	"github.com/angel-romero-f/rice-notes/internal/infra/pdf"
+	"github.com/angel-romero-f/rice-notes/internal/infra/storage"
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+)
+
+// ProcessNoteTimeout bounds one attempt at processing a note's file
+const ProcessNoteTimeout = 2 * time.Minute
+
+// RegisterJobs registers the handlers for the background jobs notes queue
+func (s *NoteService) RegisterJobs(registry *jobs.Registry) {
+	registry.Register(models.JobProcessNote, s.processNote, jobs.Options{
+		Timeout: ProcessNoteTimeout,
+		OnDead:  s.processNoteFailed,
+	})
+}
+
+// processNote runs a JobProcessNote job: it reads a revision of a note's file back
+// from storage, inspects it and indexes its text for search, and the note becomes
+// ready. Files uploaded straight to storage get their page count here, having never
+// passed through this server.
+func (s *NoteService) processNote(ctx context.Context, job *jobs.Job) error {
+	var payload models.ProcessNoteJob
+	if err := job.Decode(&payload); err != nil {
+		return err
+	}
+
+	version, err := s.repo.GetNoteVersion(ctx, payload.NoteID, payload.Version)
+	if errors.Is(err, repository.ErrVersionNotFound) {
+		slog.Info("Skipping processing of deleted note", "noteID", payload.NoteID, "version", payload.Version)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	spool, size, err := s.spoolFile(ctx, version.FilePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+
+	info, err := pdf.Inspect(spool, size)
+	var pdfErr *pdf.Error
+	if errors.As(err, &pdfErr) {
+		return jobs.Permanent(fmt.Errorf("note %s version %d is not a usable PDF: %w", payload.NoteID, payload.Version, err))
+	}
+	if err != nil {
+		return err
+	}
+
+	// A PDF with no recoverable text is still valid
+	text, err := pdf.ExtractText(spool, size)
+	if err != nil {
+		slog.Warn("Failed to extract text from PDF", "error", err, "noteID", payload.NoteID, "version", payload.Version)
+	}
+
+	content := &models.NoteContent{PageCount: info.PageCount, PDFVersion: info.Version, Text: text}
+	if err := s.repo.SetNoteContent(ctx, payload.NoteID, payload.Version, content); err != nil {
+		if errors.Is(err, repository.ErrVersionNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	slog.Info("Note processed", "noteID", payload.NoteID, "version", payload.Version, "pageCount", info.PageCount)
+	return nil
+}
+
+// spoolFile copies a stored file to a temporary file, which the PDF tools need for
+// random access. The caller closes and removes it.
+func (s *NoteService) spoolFile(ctx context.Context, key string) (*os.File, int64, error) {
+	body, err := s.uploader.Open(ctx, key)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return nil, 0, jobs.Permanent(fmt.Errorf("file %s: %w", key, err))
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer body.Close()
+
+	spool, err := os.CreateTemp("", "note-*.pdf")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create spool: %w", err)
+	}
+
+	size, err := io.Copy(spool, body)
+	if err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		return nil, 0, fmt.Errorf("failed to read file %s: %w", key, err)
+	}
+
+	return spool, size, nil
+}
+
+// processNoteFailed marks a note whose processing job gave up. Files that look like
+// a PDF disguising another format are quarantined rather than merely failed.
+func (s *NoteService) processNoteFailed(ctx context.Context, job *jobs.Job, err error) {
+	var payload models.ProcessNoteJob
+	if job.Decode(&payload) != nil {
+		return
+	}
+
+	status, reason := models.NoteStatusFailed, "the file could not be processed"
+	var pdfErr *pdf.Error
+	switch {
+	case errors.As(err, &pdfErr):
+		reason = pdfErr.Message
+		if pdfErr.Code == pdf.CodePolyglot {
+			status = models.NoteStatusQuarantined
+		}
+	case errors.Is(err, storage.ErrObjectNotFound):
+		reason = "the file is missing from storage"
+	}
+
+	if err := s.repo.SetNoteStatus(ctx, payload.NoteID, payload.Version, status, reason); err != nil {
+		slog.Error("Failed to mark note as failed", "error", err, "noteID", payload.NoteID, "status", status)
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/angel-romero-f/rice-notes/internal/infra/jobs"
+	"github.com/angel-romero-f/rice-notes/internal/infra/pdf"
+	"github.com/angel-romero-f/rice-notes/internal/infra/storage"
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/google/uuid"
+)
+
+// fakeNoteRepository serves the notes and versions processing reads and records the
+// content and status it sets; every other method is left unimplemented
+type fakeNoteRepository struct {
+	repository.NoteRepository
+	versions map[int]*models.NoteVersion
+	content  map[int]*models.NoteContent
+	// notes are returned by successive GetNoteByID calls, the last one repeatedly
+	notes        []*models.Note
+	status       string
+	statusReason string
+}
+
+func (f *fakeNoteRepository) GetNoteByID(ctx context.Context, id uuid.UUID) (*models.Note, error) {
+	if len(f.notes) == 0 {
+		return nil, repository.ErrNoteNotFound
+	}
+	note := *f.notes[0]
+	if len(f.notes) > 1 {
+		f.notes = f.notes[1:]
+	}
+	return &note, nil
+}
+
+func (f *fakeNoteRepository) GetNoteVersion(ctx context.Context, noteID uuid.UUID, version int) (*models.NoteVersion, error) {
+	v, ok := f.versions[version]
+	if !ok {
+		return nil, repository.ErrVersionNotFound
+	}
+	return v, nil
+}
+
+func (f *fakeNoteRepository) SetNoteContent(ctx context.Context, noteID uuid.UUID, version int, content *models.NoteContent) error {
+	f.content[version] = content
+	return nil
+}
+
+func (f *fakeNoteRepository) SetNoteStatus(ctx context.Context, noteID uuid.UUID, version int, status, reason string) error {
+	f.status, f.statusReason = status, reason
+	return nil
+}
+
+func TestProcessNote(t *testing.T) {
+	noteID := uuid.New()
+
+	tests := []struct {
+		name          string
+		data          []byte
+		version       int
+		wantErr       bool
+		wantPermanent bool
+		wantPages     int
+	}{
+		{name: "indexes the file", data: onePagePDF(1024), version: 1, wantPages: 1},
+		{name: "deleted note", data: onePagePDF(1024), version: 2},
+		{name: "not a pdf", data: []byte("PK\x03\x04 not a pdf"), version: 1, wantErr: true, wantPermanent: true},
+		{name: "file missing", data: nil, version: 1, wantErr: true, wantPermanent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploader := newMockUploaderWith(t, "notes/u/n/a.pdf", tt.data)
+			repo := &fakeNoteRepository{
+				versions: map[int]*models.NoteVersion{1: {NoteID: noteID, Version: 1, FilePath: "notes/u/n/a.pdf"}},
+				content:  make(map[int]*models.NoteContent),
+			}
+			s := &NoteService{repo: repo, uploader: uploader}
+
+			payload, _ := json.Marshal(models.ProcessNoteJob{NoteID: noteID, Version: tt.version})
+			err := s.processNote(context.Background(), &jobs.Job{Type: models.JobProcessNote, Payload: payload})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processNote() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if jobs.IsPermanent(err) != tt.wantPermanent {
+				t.Errorf("processNote() permanent = %v, want %v", jobs.IsPermanent(err), tt.wantPermanent)
+			}
+
+			content := repo.content[tt.version]
+			if tt.wantPages == 0 {
+				if content != nil {
+					t.Errorf("processNote() set content %+v, want none", content)
+				}
+				return
+			}
+			if content == nil || content.PageCount != tt.wantPages {
+				t.Errorf("processNote() content = %+v, want %d pages", content, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestProcessNoteFailed(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus string
+		wantReason string
+	}{
+		{
+			name:       "not a pdf",
+			err:        jobs.Permanent(fmt.Errorf("inspecting: %w", &pdf.Error{Code: pdf.CodeNotPDF, Message: "file is not a PDF"})),
+			wantStatus: models.NoteStatusFailed,
+			wantReason: "file is not a PDF",
+		},
+		{
+			name:       "polyglot",
+			err:        jobs.Permanent(&pdf.Error{Code: pdf.CodePolyglot, Message: "file is not only a PDF"}),
+			wantStatus: models.NoteStatusQuarantined,
+			wantReason: "file is not only a PDF",
+		},
+		{
+			name:       "file missing",
+			err:        jobs.Permanent(fmt.Errorf("file a.pdf: %w", storage.ErrObjectNotFound)),
+			wantStatus: models.NoteStatusFailed,
+			wantReason: "the file is missing from storage",
+		},
+		{
+			name:       "out of attempts",
+			err:        errors.New("storage unavailable"),
+			wantStatus: models.NoteStatusFailed,
+			wantReason: "the file could not be processed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeNoteRepository{}
+			s := &NoteService{repo: repo}
+
+			payload, _ := json.Marshal(models.ProcessNoteJob{NoteID: uuid.New(), Version: 1})
+			s.processNoteFailed(context.Background(), &jobs.Job{Type: models.JobProcessNote, Payload: payload}, tt.err)
+
+			if repo.status != tt.wantStatus || repo.statusReason != tt.wantReason {
+				t.Errorf("processNoteFailed() set %q (%q), want %q (%q)", repo.status, repo.statusReason, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
+
+// newMockUploaderWith returns a mock uploader holding data under key, or nothing if
+// data is nil
+func newMockUploaderWith(t *testing.T, key string, data []byte) *storage.MockUploader {
+	t.Helper()
+	uploader := storage.NewMockUploader()
+	if data != nil {
+		if err := uploader.Upload(context.Background(), key, bytes.NewReader(data), AllowedContentType, int64(len(data))); err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+	}
+	return uploader
+}
@@ -2,11 +2,12 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"mime/multipart"
-	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/angel-romero-f/rice-notes/internal/infra/storage"
 	"github.com/angel-romero-f/rice-notes/internal/models"
@@ -15,55 +16,120 @@ import (
 )
 
 const (
-	// MaxFileSize is the maximum allowed file size (10MB)
-	MaxFileSize = 10 * 1024 * 1024
 	// AllowedContentType is the only allowed content type
 	AllowedContentType = "application/pdf"
+	// MaxSearchQueryLength is the longest accepted search query
+	MaxSearchQueryLength = 200
+	// DownloadURLExpiration is how long a presigned download URL stays valid
+	DownloadURLExpiration = 5 * time.Minute
+)
+
+var (
+	// ErrNoteNotFound is returned when a note does not exist or the user has no access
+	// to it; the two cases are deliberately indistinguishable
+	ErrNoteNotFound = repository.ErrNoteNotFound
+	// ErrNoteModified is returned when an update's If-Match precondition no longer holds
+	ErrNoteModified = repository.ErrNoteModified
+	// ErrNoteQuarantined is returned when downloading a note whose file processing
+	// found suspicious
+	ErrNoteQuarantined = models.NewError(ErrConflict, "note_quarantined", "this note's file has been quarantined")
+)
+
+// access is the level of access an operation on a note requires
+type access int
+
+const (
+	accessView access = iota
+	accessEdit
+	accessOwner
 )
 
 // NoteService handles note-related business logic
 type NoteService struct {
 	repo     repository.NoteRepository
+	shares   repository.ShareRepository
+	courses  repository.CourseRepository
+	uploads  repository.UploadRepository
 	uploader storage.Uploader
+	// maxFileSize is the largest file accepted, in bytes
+	maxFileSize int64
 }
 
-// NewNoteService creates a new note service instance
-func NewNoteService(repo repository.NoteRepository, uploader storage.Uploader) *NoteService {
+// NewNoteService creates a new note service instance. maxFileSize limits uploads, in
+// bytes; zero means DefaultMaxFileSize.
+func NewNoteService(repo repository.NoteRepository, shares repository.ShareRepository, courses repository.CourseRepository, uploads repository.UploadRepository, uploader storage.Uploader, maxFileSize int64) *NoteService {
+	if maxFileSize <= 0 {
+		maxFileSize = DefaultMaxFileSize
+	}
 	return &NoteService{
-		repo:     repo,
-		uploader: uploader,
+		repo:        repo,
+		shares:      shares,
+		courses:     courses,
+		uploads:     uploads,
+		uploader:    uploader,
+		maxFileSize: maxFileSize,
 	}
 }
 
-// CreateNote creates a new note by uploading a PDF file
-func (s *NoteService) CreateNote(ctx context.Context, userEmail, title, courseID string, file multipart.File, header *multipart.FileHeader) (*models.NoteResponse, error) {
-	slog.Info("Creating new note", "userEmail", userEmail, "title", title, "courseID", courseID, "fileName", header.Filename)
+// MaxFileSize returns the largest file accepted for upload, in bytes
+func (s *NoteService) MaxFileSize() int64 {
+	return s.maxFileSize
+}
+
+// CreateNote creates a new note by uploading a PDF file. The request is validated
+// before the file is read, so the form fields must come first.
+func (s *NoteService) CreateNote(ctx context.Context, caller Caller, req *models.CreateNoteRequest, upload *Upload) (*models.NoteResponse, error) {
+	title, courseID := req.Title, req.CourseID
+	slog.Info("Creating new note", "userEmail", caller.Email, "title", title, "courseID", courseID)
+
+	visibility := req.Visibility
+	if visibility == "" {
+		visibility = models.VisibilityPrivate
+	}
 
 	// Validate inputs
-	if err := s.validateCreateNoteRequest(userEmail, title, courseID, header); err != nil {
+	if err := validateCreateNoteRequest(caller.Email, title, courseID, visibility); err != nil {
+		slog.Warn("Invalid create note request", "error", err)
+		return nil, err
+	}
+	if err := validateFile(upload); err != nil {
+		return nil, err
+	}
+
+	// File the note under the catalog's spelling of the course
+	courseID, err := resolveCourse(ctx, s.courses, courseID)
+	if err != nil {
 		slog.Warn("Invalid create note request", "error", err)
 		return nil, err
 	}
 
 	// Generate UUID for the note
 	noteID := uuid.New()
+	filePath := storage.GenerateFileKey(caller.UserID.String(), noteID.String(), upload.FileName)
+
+	stored, err := s.storeUpload(ctx, filePath, upload)
+	if err != nil {
+		slog.Warn("Failed to store uploaded file", "error", err, "fileName", upload.FileName, "userEmail", caller.Email)
+		return nil, err
+	}
 
 	// Create note model
 	note := &models.Note{
 		ID:          noteID,
-		UserEmail:   userEmail,
+		UserID:      caller.UserID,
+		UserEmail:   caller.Email,
 		Title:       title,
 		CourseID:    courseID,
-		FileName:    header.Filename,
-		FileSize:    header.Size,
-		ContentType: AllowedContentType,
-		FilePath:    storage.GenerateFileKey(userEmail, noteID.String(), header.Filename),
-	}
-
-	// Upload file to S3
-	if err := s.uploader.Upload(ctx, note.FilePath, file, note.ContentType, note.FileSize); err != nil {
-		slog.Error("Failed to upload file to S3", "error", err, "noteID", noteID)
-		return nil, fmt.Errorf("failed to upload file: %w", err)
+		FileName:    upload.FileName,
+		FileSize:    stored.Size,
+		SHA256:      stored.SHA256,
+		ContentType: AllowedContentType, // confirmed by storeUpload above
+		PageCount:   stored.Info.PageCount,
+		PDFVersion:  stored.Info.Version,
+		Visibility:  visibility,
+		FilePath:    filePath,
+		// Text is extracted by the processing job queued with the note
+		Status: models.NoteStatusProcessing,
 	}
 
 	// Save note to database
@@ -83,121 +149,329 @@ func (s *NoteService) CreateNote(ctx context.Context, userEmail, title, courseID
 		CourseID:    note.CourseID,
 		FileName:    note.FileName,
 		FileSize:    note.FileSize,
+		SHA256:      note.SHA256,
 		ContentType: note.ContentType,
+		PageCount:   note.PageCount,
+		PDFVersion:  note.PDFVersion,
+		Visibility:  note.Visibility,
+		Status:      note.Status,
 		UploadedAt:  note.UploadedAt,
 	}
 
-	slog.Info("Note created successfully", "noteID", noteID, "userEmail", userEmail)
+	slog.Info("Note created successfully", "noteID", noteID, "userEmail", caller.Email)
 	return response, nil
 }
 
-// GetNoteByID retrieves a note by its ID
-func (s *NoteService) GetNoteByID(ctx context.Context, noteID uuid.UUID, userEmail string) (*models.Note, error) {
+// GetNoteByID retrieves a note the user owns or that has been shared with them
+func (s *NoteService) GetNoteByID(ctx context.Context, noteID uuid.UUID, caller Caller) (*models.Note, error) {
+	return s.authorize(ctx, noteID, caller, accessView)
+}
+
+// authorize loads a note and checks the user has at least the required access to it.
+// Owners can do anything, editors can view and edit, and viewers can only view.
+// Published notes can also be viewed by anyone in their audience. Notes that aren't
+// ready are only visible to their owner.
+func (s *NoteService) authorize(ctx context.Context, noteID uuid.UUID, caller Caller, required access) (*models.Note, error) {
 	note, err := s.repo.GetNoteByID(ctx, noteID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Ensure the note belongs to the requesting user
-	if note.UserEmail != userEmail {
-		slog.Warn("User attempted to access note they don't own", 
-			"userEmail", userEmail, "noteOwner", note.UserEmail, "noteID", noteID)
-		return nil, fmt.Errorf("note not found")
+	if note.UserID == caller.UserID {
+		return note, nil
+	}
+	if note.Status != models.NoteStatusReady {
+		return nil, ErrNoteNotFound
+	}
+
+	share, err := s.shares.GetShare(ctx, noteID, caller.UserID)
+	if errors.Is(err, repository.ErrShareNotFound) {
+		if required == accessView && note.Visibility == models.VisibilityRice {
+			// Only Rice users can sign in, so a note published to Rice is open to every
+			// caller. Its readers know the author by display name only.
+			note.UserEmail = ""
+			return note, nil
+		}
+		slog.Warn("User attempted to access note they don't own",
+			"userEmail", caller.Email, "noteOwner", note.UserEmail, "noteID", noteID)
+		return nil, ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check note access: %w", err)
+	}
+
+	granted := accessView
+	if share.Role == models.ShareRoleEditor {
+		granted = accessEdit
+	}
+	if granted < required {
+		slog.Warn("User lacks the role for this action",
+			"userEmail", caller.Email, "role", share.Role, "noteID", noteID)
+		return nil, ErrForbidden
 	}
 
 	return note, nil
 }
 
-// GetUserNotes retrieves notes for a user with optional course filtering
-func (s *NoteService) GetUserNotes(ctx context.Context, userEmail, courseID string, limit, offset int) ([]*models.Note, error) {
+// GetNoteDownloadURL returns a short-lived presigned URL for downloading a note's file
+func (s *NoteService) GetNoteDownloadURL(ctx context.Context, noteID uuid.UUID, caller Caller) (*models.DownloadResponse, error) {
+	// Anyone who can view the note may download its file
+	note, err := s.authorize(ctx, noteID, caller, accessView)
+	if err != nil {
+		return nil, err
+	}
+	if note.Status == models.NoteStatusQuarantined {
+		slog.Warn("Refused download of quarantined note", "noteID", noteID, "userEmail", caller.Email)
+		return nil, ErrNoteQuarantined
+	}
+
+	download, err := s.presignDownload(ctx, note.FilePath, note.FileName)
+	if err != nil {
+		slog.Error("Failed to generate download URL", "error", err, "noteID", noteID)
+		return nil, err
+	}
+
+	slog.Info("Download URL generated", "noteID", noteID, "userEmail", caller.Email)
+	return download, nil
+}
+
+// presignDownload creates a short-lived download link for a stored file
+func (s *NoteService) presignDownload(ctx context.Context, filePath, fileName string) (*models.DownloadResponse, error) {
+	expiresAt := time.Now().Add(DownloadURLExpiration)
+	url, err := s.uploader.GetPresignedURL(ctx, filePath, fileName, DownloadURLExpiration)
+	if err != nil {
+		return nil, upstreamError("file storage", err)
+	}
+
+	return &models.DownloadResponse{
+		URL:       url,
+		FileName:  fileName,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// GetUserNotes retrieves a page of a user's notes, optionally only those in a course or
+// carrying some of the user's tags, along with how many notes match in total. Only
+// ready notes are listed unless the filter asks for other statuses.
+func (s *NoteService) GetUserNotes(ctx context.Context, caller Caller, filter *models.NoteFilter, opts *models.NoteListOptions) (*models.NotesPage, error) {
+	query, err := parseNoteListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := normalizeTags(filter.Tags)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range filter.Statuses {
+		if !slices.Contains(models.NoteStatuses, status) {
+			return nil, invalidf("status", "status must be one of %s", strings.Join(models.NoteStatuses, ", "))
+		}
+	}
+	query.Filter = &models.NoteFilter{
+		CourseID:     CanonicalCourseID(filter.CourseID),
+		Tags:         tags,
+		MatchAllTags: filter.MatchAllTags,
+		Statuses:     filter.Statuses,
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	limit := query.Limit
+	query.Limit++
+	notes, err := s.repo.ListNotes(ctx, caller.UserID, query)
+	if err != nil {
+		slog.Error("Failed to get user notes", "error", err, "userEmail", caller.Email)
+		return nil, fmt.Errorf("failed to get notes: %w", err)
+	}
+
+	total, err := s.repo.CountNotes(ctx, caller.UserID, query.Filter)
+	if err != nil {
+		slog.Error("Failed to count user notes", "error", err, "userEmail", caller.Email)
+		return nil, fmt.Errorf("failed to get notes: %w", err)
+	}
+
+	page := &models.NotesPage{Items: notes, Total: total}
+	if len(notes) > limit {
+		page.Items = notes[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = encodeListCursor(query.Sort, query.Descending, &repository.ListCursor{
+			Value: noteSortValue(last, query.Sort),
+			ID:    last.ID,
+		})
+	}
+	if page.Items == nil {
+		page.Items = []*models.Note{}
+	}
+
+	if err := s.attachTags(ctx, caller, page.Items); err != nil {
+		slog.Error("Failed to get note tags", "error", err, "userEmail", caller.Email)
+		return nil, fmt.Errorf("failed to get notes: %w", err)
+	}
+
+	return page, nil
+}
+
+// SearchNotes runs a full-text search over the notes the user can view, optionally
+// within one course: their own, those shared with them and those published to Rice
+func (s *NoteService) SearchNotes(ctx context.Context, caller Caller, query, courseID string, limit, offset int) ([]*models.SearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, invalidf("q", "search query is required")
+	}
+	if len(query) > MaxSearchQueryLength {
+		return nil, invalidf("q", "search query must be %d characters or less", MaxSearchQueryLength)
+	}
+
 	// Apply reasonable limits
 	if limit <= 0 || limit > 100 {
-		limit = 50
+		limit = 20
 	}
 	if offset < 0 {
 		offset = 0
 	}
 
-	var notes []*models.Note
-	var err error
+	results, err := s.repo.SearchNotes(ctx, caller.UserID, query, CanonicalCourseID(courseID), limit, offset)
+	if err != nil {
+		slog.Error("Failed to search notes", "error", err, "userEmail", caller.Email)
+		return nil, fmt.Errorf("failed to search notes: %w", err)
+	}
+
+	return results, nil
+}
 
-	if courseID != "" {
-		notes, err = s.repo.GetNotesByCourse(ctx, userEmail, courseID, limit, offset)
-	} else {
-		notes, err = s.repo.GetNotesByUser(ctx, userEmail, limit, offset)
+// UpdateNote applies a partial update to a note's metadata. Editors may change the
+// title and course; only the owner may change visibility. When ifUnmodifiedAt is
+// set, the update only succeeds if the note's updated_at still equals it.
+func (s *NoteService) UpdateNote(ctx context.Context, noteID uuid.UUID, caller Caller, req *models.UpdateNoteRequest, ifUnmodifiedAt *time.Time) (*models.Note, error) {
+	if req.Title == nil && req.CourseID == nil && req.Visibility == nil {
+		return nil, invalidf("", "at least one of title, course_id or visibility is required")
+	}
+	if req.Title != nil {
+		if err := validateTitle(*req.Title); err != nil {
+			return nil, err
+		}
+	}
+	if req.CourseID != nil {
+		if err := validateCourseID(*req.CourseID); err != nil {
+			return nil, err
+		}
+		courseID, err := resolveCourse(ctx, s.courses, *req.CourseID)
+		if err != nil {
+			return nil, err
+		}
+		req.CourseID = &courseID
+	}
+	if req.Visibility != nil {
+		if err := validateVisibility(*req.Visibility); err != nil {
+			return nil, err
+		}
+	}
+
+	required := accessEdit
+	if req.Visibility != nil {
+		required = accessOwner
+	}
+	if _, err := s.authorize(ctx, noteID, caller, required); err != nil {
+		return nil, err
 	}
 
+	note, err := s.repo.UpdateNote(ctx, noteID, req, ifUnmodifiedAt)
 	if err != nil {
-		slog.Error("Failed to get user notes", "error", err, "userEmail", userEmail)
-		return nil, fmt.Errorf("failed to get notes: %w", err)
+		return nil, err
 	}
 
-	return notes, nil
+	slog.Info("Note updated", "noteID", noteID, "userEmail", caller.Email)
+	return note, nil
 }
 
 // DeleteNote deletes a note and its associated file
-func (s *NoteService) DeleteNote(ctx context.Context, noteID uuid.UUID, userEmail string) error {
-	// First get the note to check ownership and get file path
-	note, err := s.GetNoteByID(ctx, noteID, userEmail)
+func (s *NoteService) DeleteNote(ctx context.Context, noteID uuid.UUID, caller Caller) error {
+	// Only the owner may delete; shares are removed with the note
+	note, err := s.authorize(ctx, noteID, caller, accessOwner)
 	if err != nil {
 		return err
 	}
 
+	// Collect every revision's file before the rows cascade away
+	versions, err := s.repo.GetNoteVersions(ctx, noteID)
+	if err != nil {
+		return fmt.Errorf("failed to delete note: %w", err)
+	}
+	filePaths := []string{note.FilePath}
+	for _, v := range versions {
+		if !slices.Contains(filePaths, v.FilePath) {
+			filePaths = append(filePaths, v.FilePath)
+		}
+	}
+
 	// Delete from database first
-	if err := s.repo.DeleteNote(ctx, noteID, userEmail); err != nil {
+	if err := s.repo.DeleteNote(ctx, noteID, caller.UserID); err != nil {
 		slog.Error("Failed to delete note from database", "error", err, "noteID", noteID)
 		return fmt.Errorf("failed to delete note: %w", err)
 	}
 
-	// Delete file from S3 (best effort - don't fail if this fails)
-	if err := s.uploader.Delete(ctx, note.FilePath); err != nil {
-		slog.Error("Failed to delete file from S3", "error", err, "noteID", noteID, "filePath", note.FilePath)
-		// Don't return error here - the database deletion was successful
+	// Delete files from S3 (best effort - don't fail if this fails)
+	for _, filePath := range filePaths {
+		if err := s.uploader.Delete(ctx, filePath); err != nil {
+			slog.Error("Failed to delete file from S3", "error", err, "noteID", noteID, "filePath", filePath)
+			// Don't return error here - the database deletion was successful
+		}
 	}
 
-	slog.Info("Note deleted successfully", "noteID", noteID, "userEmail", userEmail)
+	slog.Info("Note deleted successfully", "noteID", noteID, "userEmail", caller.Email)
 	return nil
 }
 
-// validateCreateNoteRequest validates the request parameters
-func (s *NoteService) validateCreateNoteRequest(userEmail, title, courseID string, header *multipart.FileHeader) error {
-	if userEmail == "" {
-		return fmt.Errorf("user email is required")
+// validateVisibility checks visibility is one of the supported values. Publishing to
+// a single course isn't offered until there is enrollment data to limit it by.
+func validateVisibility(visibility string) error {
+	switch visibility {
+	case models.VisibilityPrivate, models.VisibilityRice:
+		return nil
+	default:
+		return invalidf("visibility", "visibility must be %s or %s", models.VisibilityPrivate, models.VisibilityRice)
 	}
+}
 
+// validateTitle checks a note title is present and fits its column
+func validateTitle(title string) error {
 	if title == "" {
-		return fmt.Errorf("title is required")
+		return invalidf("title", "title is required")
 	}
 
 	if len(title) > 255 {
-		return fmt.Errorf("title must be 255 characters or less")
+		return invalidf("title", "title must be 255 characters or less")
 	}
 
+	return nil
+}
+
+// validateCourseID checks a course ID is present and fits its column
+func validateCourseID(courseID string) error {
 	if courseID == "" {
-		return fmt.Errorf("course ID is required")
+		return invalidf("course_id", "course ID is required")
 	}
 
 	if len(courseID) > 50 {
-		return fmt.Errorf("course ID must be 50 characters or less")
+		return invalidf("course_id", "course ID must be 50 characters or less")
 	}
 
-	if header == nil {
-		return fmt.Errorf("file is required")
-	}
+	return nil
+}
 
-	if header.Size == 0 {
-		return fmt.Errorf("file cannot be empty")
+// validateCreateNoteRequest validates a new note's metadata
+func validateCreateNoteRequest(userEmail, title, courseID, visibility string) error {
+	if userEmail == "" {
+		return invalidf("", "user email is required")
 	}
 
-	if header.Size > MaxFileSize {
-		return fmt.Errorf("file size must be less than %d bytes", MaxFileSize)
+	if err := validateTitle(title); err != nil {
+		return err
 	}
 
-	// Validate file extension
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	if ext != ".pdf" {
-		return fmt.Errorf("only PDF files are allowed")
+	if err := validateCourseID(courseID); err != nil {
+		return err
 	}
 
-	return nil
+	return validateVisibility(visibility)
 }
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+)
+
+func TestValidateVisibility(t *testing.T) {
+	tests := []struct {
+		visibility string
+		wantErr    bool
+	}{
+		{visibility: models.VisibilityPrivate},
+		{visibility: models.VisibilityRice},
+		// Course-only publishing can't be enforced without enrollment data
+		{visibility: "course", wantErr: true},
+		{visibility: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := validateVisibility(tt.visibility)
+		if !tt.wantErr {
+			if err != nil {
+				t.Errorf("validateVisibility(%q) error = %v", tt.visibility, err)
+			}
+			continue
+		}
+		var validationErr *models.Error
+		if !errors.As(err, &validationErr) || validationErr.Field != "visibility" {
+			t.Errorf("validateVisibility(%q) error = %v, want a visibility field error", tt.visibility, err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ErrShareNotFound is returned when revoking a share that does not exist
+var ErrShareNotFound = repository.ErrShareNotFound
+
+// ShareNote grants another Rice user access to a note, or changes their role if
+// the note is already shared with them. Only the owner may share a note.
+func (s *NoteService) ShareNote(ctx context.Context, noteID uuid.UUID, caller Caller, req *models.ShareNoteRequest) (*models.NoteShare, error) {
+	grantee := CanonicalEmail(req.Email)
+	if grantee == "" {
+		return nil, invalidf("email", "email is required")
+	}
+	if !isRiceEmail(grantee) {
+		return nil, invalidf("email", "notes can only be shared with Rice University emails")
+	}
+	if req.Role != models.ShareRoleViewer && req.Role != models.ShareRoleEditor {
+		return nil, invalidf("role", "role must be %s or %s", models.ShareRoleViewer, models.ShareRoleEditor)
+	}
+
+	note, err := s.authorize(ctx, noteID, caller, accessOwner)
+	if err != nil {
+		return nil, err
+	}
+	if grantee == note.UserEmail {
+		return nil, invalidf("email", "you cannot share a note with yourself")
+	}
+
+	share := &models.NoteShare{
+		NoteID:       noteID,
+		GranteeEmail: grantee,
+		Role:         req.Role,
+		GrantedBy:    caller.Email,
+	}
+	if err := s.shares.UpsertShare(ctx, share); err != nil {
+		return nil, err
+	}
+
+	slog.Info("Note shared", "noteID", noteID, "owner", caller.Email, "grantee", grantee, "role", req.Role)
+	return share, nil
+}
+
+// GetNoteShares lists who a note is shared with. Only the owner may see the list.
+func (s *NoteService) GetNoteShares(ctx context.Context, noteID uuid.UUID, caller Caller) ([]*models.NoteShare, error) {
+	if _, err := s.authorize(ctx, noteID, caller, accessOwner); err != nil {
+		return nil, err
+	}
+
+	shares, err := s.shares.GetSharesByNote(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+// RevokeShare removes a user's access to a note. The owner may revoke any share,
+// and a grantee may remove their own access.
+func (s *NoteService) RevokeShare(ctx context.Context, noteID uuid.UUID, caller Caller, granteeEmail string) error {
+	grantee := CanonicalEmail(granteeEmail)
+
+	required := accessOwner
+	if grantee == caller.Email {
+		required = accessView
+	}
+	if _, err := s.authorize(ctx, noteID, caller, required); err != nil {
+		return err
+	}
+
+	if err := s.shares.DeleteShare(ctx, noteID, grantee); err != nil {
+		return err
+	}
+
+	slog.Info("Share revoked", "noteID", noteID, "revokedBy", caller.Email, "grantee", grantee)
+	return nil
+}
+
+// GetSharedNotes retrieves notes other users have shared with the user
+func (s *NoteService) GetSharedNotes(ctx context.Context, caller Caller, limit, offset int) ([]*models.SharedNote, error) {
+	// Apply reasonable limits
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	notes, err := s.shares.GetNotesSharedWith(ctx, caller.UserID, limit, offset)
+	if err != nil {
+		slog.Error("Failed to get shared notes", "error", err, "userEmail", caller.Email)
+		return nil, fmt.Errorf("failed to get shared notes: %w", err)
+	}
+
+	return notes, nil
+}
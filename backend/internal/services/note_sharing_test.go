@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/google/uuid"
+)
+
+// fakeShareRepository serves shares keyed by grantee user ID; every other method is
+// left unimplemented
+type fakeShareRepository struct {
+	repository.ShareRepository
+	shares map[uuid.UUID]*models.NoteShare
+}
+
+func (f *fakeShareRepository) GetShare(ctx context.Context, noteID, granteeID uuid.UUID) (*models.NoteShare, error) {
+	share, ok := f.shares[granteeID]
+	if !ok || share.NoteID != noteID {
+		return nil, repository.ErrShareNotFound
+	}
+	return share, nil
+}
+
+func TestNoteService_SharesFollowUserID(t *testing.T) {
+	owner := Caller{UserID: uuid.New(), Email: "owner@rice.edu"}
+	granteeID := uuid.New()
+	noteID := uuid.New()
+
+	repo := &fakeNoteRepository{notes: []*models.Note{{
+		ID: noteID, UserID: owner.UserID, UserEmail: owner.Email,
+		Visibility: models.VisibilityPrivate, Status: models.NoteStatusReady,
+	}}}
+	shares := &fakeShareRepository{shares: map[uuid.UUID]*models.NoteShare{
+		granteeID: {NoteID: noteID, GranteeID: granteeID, GranteeEmail: "old.alias@rice.edu", Role: models.ShareRoleViewer},
+	}}
+	service := NewNoteService(repo, shares, nil, nil, nil, 0)
+
+	// The grantee has since signed in under a new alias
+	if _, err := service.GetNoteByID(context.Background(), noteID, Caller{UserID: granteeID, Email: "new.alias@rice.edu"}); err != nil {
+		t.Errorf("GetNoteByID(grantee under new email) error = %v", err)
+	}
+
+	// Someone who now holds the grantee's old email is a different user
+	stranger := Caller{UserID: uuid.New(), Email: "old.alias@rice.edu"}
+	if _, err := service.GetNoteByID(context.Background(), noteID, stranger); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("GetNoteByID(other user with the old email) error = %v, want ErrNoteNotFound", err)
+	}
+}
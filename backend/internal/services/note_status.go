@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/google/uuid"
+)
+
+// MaxNoteStatusWait is the longest a status request may wait for processing
+const MaxNoteStatusWait = time.Minute
+
+// noteStatusPollInterval is how often a waiting status request re-reads the note; a
+// variable so tests can poll faster
+var noteStatusPollInterval = time.Second
+
+// GetNoteStatus reports where a note is in processing. While the note is uploading or
+// processing, it waits up to wait (capped at MaxNoteStatusWait) for that to change, so
+// clients can long-poll instead of asking again and again.
+func (s *NoteService) GetNoteStatus(ctx context.Context, noteID uuid.UUID, caller Caller, wait time.Duration) (*models.NoteStatus, error) {
+	deadline := time.Now().Add(min(wait, MaxNoteStatusWait))
+
+	for {
+		status, err := s.noteStatus(ctx, noteID, caller)
+		if err != nil {
+			return nil, err
+		}
+
+		remaining := time.Until(deadline)
+		if !isPendingStatus(status.Status) || remaining <= 0 {
+			return status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(min(noteStatusPollInterval, remaining)):
+		}
+	}
+}
+
+// noteStatus reads a note's status. A note whose file is still being uploaded only
+// exists as a pending upload, whose ID the note will take.
+func (s *NoteService) noteStatus(ctx context.Context, noteID uuid.UUID, caller Caller) (*models.NoteStatus, error) {
+	note, err := s.authorize(ctx, noteID, caller, accessView)
+	if err == nil {
+		return &models.NoteStatus{ID: note.ID, Status: note.Status, StatusReason: note.StatusReason}, nil
+	}
+	if !errors.Is(err, ErrNoteNotFound) {
+		return nil, err
+	}
+
+	upload, uploadErr := s.uploads.GetUpload(ctx, noteID)
+	if uploadErr != nil || upload.UserID != caller.UserID {
+		return nil, err
+	}
+	return &models.NoteStatus{ID: upload.ID, Status: models.NoteStatusUploading}, nil
+}
+
+// isPendingStatus reports whether a note in status is still on its way to another one
+func isPendingStatus(status string) bool {
+	return status == models.NoteStatusUploading || status == models.NoteStatusProcessing
+}
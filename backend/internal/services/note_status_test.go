@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/google/uuid"
+)
+
+// fakeUploadRepository stores and serves pending uploads; every other method is left unimplemented
+type fakeUploadRepository struct {
+	repository.UploadRepository
+	uploads map[uuid.UUID]*models.PendingUpload
+}
+
+func (f *fakeUploadRepository) CreateUpload(ctx context.Context, upload *models.PendingUpload) error {
+	f.uploads[upload.ID] = upload
+	return nil
+}
+
+func (f *fakeUploadRepository) DeleteExpiredUploads(ctx context.Context) ([]*models.PendingUpload, error) {
+	return nil, nil
+}
+
+func (f *fakeUploadRepository) GetUpload(ctx context.Context, id uuid.UUID) (*models.PendingUpload, error) {
+	upload, ok := f.uploads[id]
+	if !ok {
+		return nil, repository.ErrUploadNotFound
+	}
+	return upload, nil
+}
+
+func TestNoteService_GetNoteStatus(t *testing.T) {
+	defer func(interval time.Duration) { noteStatusPollInterval = interval }(noteStatusPollInterval)
+	noteStatusPollInterval = time.Millisecond
+
+	owner := Caller{UserID: uuid.New(), Email: "owner@rice.edu"}
+	other := Caller{UserID: uuid.New(), Email: "other@rice.edu"}
+	noteID := uuid.New()
+	note := func(status string) *models.Note {
+		return &models.Note{ID: noteID, UserID: owner.UserID, UserEmail: owner.Email, Status: status}
+	}
+
+	tests := []struct {
+		name       string
+		notes      []*models.Note
+		uploads    map[uuid.UUID]*models.PendingUpload
+		caller     Caller
+		wait       time.Duration
+		wantStatus string
+		wantErr    error
+	}{
+		{
+			name:       "ready",
+			notes:      []*models.Note{note(models.NoteStatusReady)},
+			caller:     owner,
+			wantStatus: models.NoteStatusReady,
+		},
+		{
+			name:       "processing without waiting",
+			notes:      []*models.Note{note(models.NoteStatusProcessing), note(models.NoteStatusReady)},
+			caller:     owner,
+			wantStatus: models.NoteStatusProcessing,
+		},
+		{
+			name:       "waits for processing",
+			notes:      []*models.Note{note(models.NoteStatusProcessing), note(models.NoteStatusProcessing), note(models.NoteStatusFailed)},
+			caller:     owner,
+			wait:       time.Second,
+			wantStatus: models.NoteStatusFailed,
+		},
+		{
+			name:       "gives up waiting",
+			notes:      []*models.Note{note(models.NoteStatusProcessing)},
+			caller:     owner,
+			wait:       10 * time.Millisecond,
+			wantStatus: models.NoteStatusProcessing,
+		},
+		{
+			name:       "pending upload",
+			uploads:    map[uuid.UUID]*models.PendingUpload{noteID: {ID: noteID, UserID: owner.UserID}},
+			caller:     owner,
+			wantStatus: models.NoteStatusUploading,
+		},
+		{
+			name:    "someone else's upload",
+			uploads: map[uuid.UUID]*models.PendingUpload{noteID: {ID: noteID, UserID: owner.UserID}},
+			caller:  other,
+			wantErr: ErrNoteNotFound,
+		},
+		{
+			name:    "someone else's processing note",
+			notes:   []*models.Note{note(models.NoteStatusProcessing)},
+			caller:  other,
+			wantErr: ErrNoteNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &NoteService{
+				repo:    &fakeNoteRepository{notes: tt.notes},
+				uploads: &fakeUploadRepository{uploads: tt.uploads},
+			}
+
+			status, err := s.GetNoteStatus(context.Background(), noteID, tt.caller, tt.wait)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetNoteStatus() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetNoteStatus() error = %v", err)
+			}
+			if status.Status != tt.wantStatus {
+				t.Errorf("GetNoteStatus() status = %q, want %q", status.Status, tt.wantStatus)
+			}
+		})
+	}
+}
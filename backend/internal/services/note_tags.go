@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxTagLength is the longest accepted tag
+	MaxTagLength = 50
+	// MaxTagsPerRequest caps how many tags can be added or filtered on at once
+	MaxTagsPerRequest = 20
+)
+
+// ErrTagNotFound is returned when removing a tag the note does not carry
+var ErrTagNotFound = repository.ErrTagNotFound
+
+// tagPattern is the canonical form of a tag
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// normalizeTag returns the canonical form of a tag: lower case, with runs of spaces
+// turned into a hyphen, so "Lecture 12" and "lecture-12" are the same tag
+func normalizeTag(raw string) (string, error) {
+	tag := strings.Join(strings.Fields(strings.ToLower(raw)), "-")
+	if tag == "" {
+		return "", invalidf("tags", "tags cannot be empty")
+	}
+	if len(tag) > MaxTagLength {
+		return "", invalidf("tags", "tags must be %d characters or less", MaxTagLength)
+	}
+	if !tagPattern.MatchString(tag) {
+		return "", invalidf("tags", "tag %q may only contain letters, digits, hyphens and underscores", raw)
+	}
+	return tag, nil
+}
+
+// normalizeTags canonicalizes a list of tags and drops duplicates
+func normalizeTags(raw []string) ([]string, error) {
+	if len(raw) > MaxTagsPerRequest {
+		return nil, invalidf("tags", "at most %d tags can be given at once", MaxTagsPerRequest)
+	}
+
+	tags := make([]string, 0, len(raw))
+	for _, r := range raw {
+		tag, err := normalizeTag(r)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+// AddNoteTags tags one of the user's notes and returns all of its tags
+func (s *NoteService) AddNoteTags(ctx context.Context, noteID uuid.UUID, caller Caller, req *models.AddTagsRequest) ([]string, error) {
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, invalidf("tags", "at least one tag is required")
+	}
+
+	// Tags organize the user's own notes
+	if _, err := s.authorize(ctx, noteID, caller, accessOwner); err != nil {
+		return nil, err
+	}
+
+	all, err := s.repo.AddNoteTags(ctx, noteID, caller.UserID, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Note tagged", "noteID", noteID, "userEmail", caller.Email, "tags", tags)
+	return all, nil
+}
+
+// RemoveNoteTag removes a tag from one of the user's notes
+func (s *NoteService) RemoveNoteTag(ctx context.Context, noteID uuid.UUID, caller Caller, tag string) error {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.authorize(ctx, noteID, caller, accessOwner); err != nil {
+		return err
+	}
+
+	if err := s.repo.RemoveNoteTag(ctx, noteID, caller.UserID, tag); err != nil {
+		return err
+	}
+
+	slog.Info("Note tag removed", "noteID", noteID, "userEmail", caller.Email, "tag", tag)
+	return nil
+}
+
+// GetUserTags lists the user's tags with how many notes carry each
+func (s *NoteService) GetUserTags(ctx context.Context, caller Caller) ([]*models.TagCount, error) {
+	counts, err := s.repo.GetUserTags(ctx, caller.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	return counts, nil
+}
+
+// attachTags fills in the user's tags on listed notes
+func (s *NoteService) attachTags(ctx context.Context, caller Caller, notes []*models.Note) error {
+	if len(notes) == 0 {
+		return nil
+	}
+
+	noteIDs := make([]uuid.UUID, len(notes))
+	for i, note := range notes {
+		noteIDs[i] = note.ID
+	}
+
+	tags, err := s.repo.GetNoteTags(ctx, caller.UserID, noteIDs)
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		note.Tags = tags[note.ID]
+	}
+	return nil
+}
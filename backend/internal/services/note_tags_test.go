@@ -0,0 +1,32 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := normalizeTags([]string{"Midterm", " lecture  12 ", "problem_set", "midterm"})
+	if err != nil {
+		t.Fatalf("normalizeTags() error = %v", err)
+	}
+	if want := []string{"midterm", "lecture-12", "problem_set"}; !slices.Equal(tags, want) {
+		t.Errorf("normalizeTags() = %v, want %v", tags, want)
+	}
+
+	for _, bad := range []string{"", "   ", "-leading", "exam!", "café", strings.Repeat("a", MaxTagLength+1)} {
+		if _, err := normalizeTags([]string{bad}); !errors.Is(err, ErrValidation) {
+			t.Errorf("normalizeTags(%q) error = %v, want %v", bad, err, ErrValidation)
+		}
+	}
+
+	tooMany := make([]string, MaxTagsPerRequest+1)
+	for i := range tooMany {
+		tooMany[i] = "tag"
+	}
+	if _, err := normalizeTags(tooMany); err == nil {
+		t.Error("normalizeTags() accepted more than MaxTagsPerRequest tags")
+	}
+}
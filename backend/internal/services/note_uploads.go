@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/infra/storage"
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	// UploadURLExpiration is how long a presigned upload URL stays valid
+	UploadURLExpiration = 15 * time.Minute
+	// UploadCompletionGrace is how long past its URL's expiry a pending upload can still
+	// be completed, so a file that finishes uploading just before the URL expires is kept
+	UploadCompletionGrace = 10 * time.Minute
+)
+
+var (
+	// ErrUploadNotFound is returned when a pending upload is unknown, belongs to someone
+	// else, was already completed or has expired
+	ErrUploadNotFound = repository.ErrUploadNotFound
+	// ErrUploadIncomplete is returned when completing an upload whose file has not
+	// reached storage yet
+	ErrUploadIncomplete = models.NewError(ErrConflict, "upload_incomplete", "the file has not been uploaded yet")
+	// ErrUploadMismatch is returned when the stored file's size, type or checksum
+	// differs from what the upload was created for
+	ErrUploadMismatch = models.NewError(ErrValidation, "upload_mismatch", "the uploaded file does not match the upload request")
+)
+
+// CreateUpload validates a new note's metadata and returns a presigned URL the client
+// uploads the file to directly, without it passing through this server. The note is
+// created by CompleteUpload once the file is in storage.
+func (s *NoteService) CreateUpload(ctx context.Context, caller Caller, req *models.CreateUploadRequest) (*models.UploadResponse, error) {
+	slog.Info("Creating upload", "userEmail", caller.Email, "title", req.Title, "courseID", req.CourseID, "fileName", req.FileName)
+
+	visibility := req.Visibility
+	if visibility == "" {
+		visibility = models.VisibilityPrivate
+	}
+	checksum := strings.ToLower(req.SHA256)
+
+	if err := validateCreateNoteRequest(caller.Email, req.Title, req.CourseID, visibility); err != nil {
+		slog.Warn("Invalid create upload request", "error", err)
+		return nil, err
+	}
+	if err := s.validateUploadFile(req.FileName, req.FileSize, checksum); err != nil {
+		slog.Warn("Invalid create upload request", "error", err)
+		return nil, err
+	}
+
+	// File the note under the catalog's spelling of the course
+	courseID, err := resolveCourse(ctx, s.courses, req.CourseID)
+	if err != nil {
+		slog.Warn("Invalid create upload request", "error", err)
+		return nil, err
+	}
+
+	// The upload's ID becomes the note's, so the file is stored where the note expects it
+	uploadID := uuid.New()
+	urlExpiresAt := time.Now().Add(UploadURLExpiration)
+	upload := &models.PendingUpload{
+		ID:         uploadID,
+		UserID:     caller.UserID,
+		UserEmail:  caller.Email,
+		Title:      req.Title,
+		CourseID:   courseID,
+		Visibility: visibility,
+		FileName:   req.FileName,
+		FilePath:   storage.GenerateFileKey(caller.UserID.String(), uploadID.String(), req.FileName),
+		FileSize:   req.FileSize,
+		SHA256:     checksum,
+		ExpiresAt:  urlExpiresAt.Add(UploadCompletionGrace),
+	}
+
+	presigned, err := s.uploader.PresignUpload(ctx, upload.FilePath, AllowedContentType, upload.FileSize, upload.SHA256, UploadURLExpiration)
+	if err != nil {
+		slog.Error("Failed to generate upload URL", "error", err, "uploadID", uploadID)
+		return nil, upstreamError("file storage", err)
+	}
+
+	if err := s.uploads.CreateUpload(ctx, upload); err != nil {
+		return nil, err
+	}
+
+	s.purgeExpiredUploads(ctx)
+
+	slog.Info("Upload created", "uploadID", uploadID, "userEmail", caller.Email)
+	return &models.UploadResponse{
+		UploadID:   uploadID,
+		URL:        presigned.URL,
+		Method:     presigned.Method,
+		Headers:    presigned.Headers,
+		ExpiresAt:  urlExpiresAt,
+		CompleteBy: upload.ExpiresAt,
+	}, nil
+}
+
+// CompleteUpload checks the file of a pending upload has arrived in storage with the
+// size, type and checksum it was created for, and creates its note
+func (s *NoteService) CompleteUpload(ctx context.Context, caller Caller, uploadID uuid.UUID) (*models.NoteResponse, error) {
+	upload, err := s.uploads.GetUpload(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.UserID != caller.UserID {
+		slog.Warn("User attempted to complete someone else's upload", "userEmail", caller.Email, "uploadID", uploadID)
+		return nil, ErrUploadNotFound
+	}
+
+	info, err := s.uploader.Stat(ctx, upload.FilePath)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return nil, ErrUploadIncomplete
+	}
+	if err != nil {
+		slog.Error("Failed to check uploaded file", "error", err, "uploadID", uploadID)
+		return nil, upstreamError("file storage", err)
+	}
+
+	// The URL was signed for exactly this file, but a mismatch is still possible with
+	// storage that doesn't enforce it. The client may upload again until it expires.
+	if info.Size != upload.FileSize || info.ContentType != AllowedContentType || info.SHA256 != upload.SHA256 {
+		slog.Warn("Uploaded file does not match its upload", "uploadID", uploadID,
+			"size", info.Size, "contentType", info.ContentType, "sha256", info.SHA256)
+		return nil, ErrUploadMismatch
+	}
+
+	note := &models.Note{
+		ID:          upload.ID,
+		UserID:      upload.UserID,
+		UserEmail:   upload.UserEmail,
+		Title:       upload.Title,
+		CourseID:    upload.CourseID,
+		FileName:    upload.FileName,
+		FilePath:    upload.FilePath,
+		FileSize:    info.Size,
+		SHA256:      info.SHA256,
+		ContentType: info.ContentType,
+		Visibility:  upload.Visibility,
+		// Nothing has inspected the file yet; the processing job queued with the note will
+		Status: models.NoteStatusProcessing,
+	}
+
+	if err := s.uploads.CompleteUpload(ctx, uploadID, note); err != nil {
+		return nil, err
+	}
+
+	slog.Info("Note created from upload", "noteID", note.ID, "userEmail", caller.Email)
+	return &models.NoteResponse{
+		ID:          note.ID,
+		Title:       note.Title,
+		CourseID:    note.CourseID,
+		FileName:    note.FileName,
+		FileSize:    note.FileSize,
+		SHA256:      note.SHA256,
+		ContentType: note.ContentType,
+		Visibility:  note.Visibility,
+		Status:      note.Status,
+		UploadedAt:  note.UploadedAt,
+	}, nil
+}
+
+// validateUploadFile checks the file a client is about to upload directly
+func (s *NoteService) validateUploadFile(fileName string, size int64, checksum string) error {
+	if err := validateFileName("file_name", fileName); err != nil {
+		return err
+	}
+
+	if size <= 0 {
+		return invalidf("file_size", "file cannot be empty")
+	}
+	if size > s.maxFileSize {
+		return invalidf("file_size", "file size must be at most %d bytes", s.maxFileSize)
+	}
+
+	if _, err := hex.DecodeString(checksum); err != nil || len(checksum) != 64 {
+		return invalidf("sha256", "sha256 must be the file's hex-encoded SHA-256 checksum")
+	}
+
+	return nil
+}
+
+// purgeExpiredUploads forgets uploads that were never completed and deletes any file
+// they left in storage. Failures only leave garbage behind, so they are just logged.
+func (s *NoteService) purgeExpiredUploads(ctx context.Context) {
+	expired, err := s.uploads.DeleteExpiredUploads(ctx)
+	if err != nil {
+		slog.Warn("Failed to purge expired uploads", "error", err)
+		return
+	}
+
+	for _, upload := range expired {
+		if _, err := s.uploader.Stat(ctx, upload.FilePath); errors.Is(err, storage.ErrObjectNotFound) {
+			continue
+		}
+		if err := s.uploader.Delete(ctx, upload.FilePath); err != nil {
+			slog.Warn("Failed to delete file of expired upload", "error", err, "uploadID", upload.ID)
+		}
+	}
+
+	if len(expired) > 0 {
+		slog.Info("Purged expired uploads", "count", len(expired))
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/infra/storage"
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestNoteService_CreateUpload_CompletionGrace(t *testing.T) {
+	uploads := &fakeUploadRepository{uploads: make(map[uuid.UUID]*models.PendingUpload)}
+	courses := &fakeCourses{byCode: map[string]*models.Course{"COMP 182": {Code: "COMP 182"}}}
+	service := NewNoteService(nil, nil, courses, uploads, storage.NewMockUploader(), 10<<20)
+
+	before := time.Now()
+	resp, err := service.CreateUpload(context.Background(), Caller{UserID: uuid.New(), Email: "student@rice.edu"}, &models.CreateUploadRequest{
+		Title:    "Lecture 1",
+		CourseID: "COMP 182",
+		FileName: "lecture1.pdf",
+		FileSize: 1024,
+		SHA256:   strings.Repeat("ab", 32),
+	})
+	if err != nil {
+		t.Fatalf("CreateUpload() error = %v", err)
+	}
+
+	if resp.ExpiresAt.Before(before.Add(UploadURLExpiration)) || resp.ExpiresAt.After(time.Now().Add(UploadURLExpiration)) {
+		t.Errorf("ExpiresAt = %v, want the URL's expiry", resp.ExpiresAt)
+	}
+	if got := resp.CompleteBy.Sub(resp.ExpiresAt); got != UploadCompletionGrace {
+		t.Errorf("CompleteBy is %v after ExpiresAt, want %v", got, UploadCompletionGrace)
+	}
+
+	// The pending upload outlives its URL so a last-moment upload can still complete
+	upload := uploads.uploads[resp.UploadID]
+	if upload == nil || !upload.ExpiresAt.Equal(resp.CompleteBy) {
+		t.Errorf("pending upload = %+v, want it to expire at %v", upload, resp.CompleteBy)
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/angel-romero-f/rice-notes/internal/infra/storage"
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ErrVersionNotFound is returned when a note has no revision with the requested number
+var ErrVersionNotFound = repository.ErrVersionNotFound
+
+// ReplaceNoteFile uploads a new revision of a note's PDF and makes it current. The
+// previous revisions stay available through GetNoteVersions.
+func (s *NoteService) ReplaceNoteFile(ctx context.Context, noteID uuid.UUID, caller Caller, upload *Upload) (*models.Note, error) {
+	if err := validateFile(upload); err != nil {
+		return nil, err
+	}
+
+	note, err := s.authorize(ctx, noteID, caller, accessEdit)
+	if err != nil {
+		return nil, err
+	}
+
+	versionID := uuid.New()
+	// Revisions live under the owner's prefix whoever uploads them
+	filePath := storage.GenerateVersionFileKey(note.UserID.String(), noteID.String(), versionID.String(), upload.FileName)
+
+	stored, err := s.storeUpload(ctx, filePath, upload)
+	if err != nil {
+		slog.Warn("Failed to store uploaded file", "error", err, "fileName", upload.FileName, "userEmail", caller.Email)
+		return nil, err
+	}
+
+	version := &models.NoteVersion{
+		ID:          versionID,
+		NoteID:      noteID,
+		FileName:    upload.FileName,
+		FilePath:    filePath,
+		FileSize:    stored.Size,
+		SHA256:      stored.SHA256,
+		ContentType: AllowedContentType, // confirmed by storeUpload above
+		PageCount:   stored.Info.PageCount,
+		PDFVersion:  stored.Info.Version,
+		UploadedBy:  caller.Email,
+	}
+
+	updated, err := s.repo.AddNoteVersion(ctx, version)
+	if err != nil {
+		// Try to clean up uploaded file on database error
+		if deleteErr := s.uploader.Delete(ctx, version.FilePath); deleteErr != nil {
+			slog.Error("Failed to cleanup file after database error", "deleteError", deleteErr, "noteID", noteID)
+		}
+		return nil, err
+	}
+
+	slog.Info("Note file replaced", "noteID", noteID, "version", version.Version, "userEmail", caller.Email)
+	return updated, nil
+}
+
+// GetNoteVersions lists every revision of a note the user can view, newest first
+func (s *NoteService) GetNoteVersions(ctx context.Context, noteID uuid.UUID, caller Caller) ([]*models.NoteVersion, error) {
+	note, err := s.authorize(ctx, noteID, caller, accessView)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := s.repo.GetNoteVersions(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Readers of a published note see it without anyone's email
+	if note.UserEmail == "" {
+		for _, v := range versions {
+			v.UploadedBy = ""
+		}
+	}
+
+	return versions, nil
+}
+
+// GetNoteVersionDownloadURL returns a short-lived presigned URL for one revision of a note
+func (s *NoteService) GetNoteVersionDownloadURL(ctx context.Context, noteID uuid.UUID, caller Caller, version int) (*models.DownloadResponse, error) {
+	note, err := s.authorize(ctx, noteID, caller, accessView)
+	if err != nil {
+		return nil, err
+	}
+	// The note's status is its current revision's
+	if version == note.Version && note.Status == models.NoteStatusQuarantined {
+		return nil, ErrNoteQuarantined
+	}
+
+	v, err := s.repo.GetNoteVersion(ctx, noteID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	download, err := s.presignDownload(ctx, v.FilePath, v.FileName)
+	if err != nil {
+		slog.Error("Failed to generate download URL", "error", err, "noteID", noteID, "version", version)
+		return nil, err
+	}
+
+	slog.Info("Version download URL generated", "noteID", noteID, "version", version, "userEmail", caller.Email)
+	return download, nil
+}
+
+// RestoreNoteVersion makes an older revision current again. It is recorded as a new
+// revision that reuses the old file, so the history is never rewritten.
+func (s *NoteService) RestoreNoteVersion(ctx context.Context, noteID uuid.UUID, caller Caller, version int) (*models.Note, error) {
+	note, err := s.authorize(ctx, noteID, caller, accessEdit)
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := s.repo.GetNoteVersion(ctx, noteID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if old.Version == note.Version {
+		return note, nil
+	}
+
+	restored := *old
+	restored.ID = uuid.New()
+	restored.UploadedBy = caller.Email
+
+	updated, err := s.repo.AddNoteVersion(ctx, &restored)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Note version restored", "noteID", noteID, "from", version, "version", restored.Version, "userEmail", caller.Email)
+	return updated, nil
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultNotesPageSize is how many notes a listing page holds by default
+	DefaultNotesPageSize = 50
+	// MaxNotesPageSize is the largest page of notes a listing returns
+	MaxNotesPageSize = 100
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+var ErrInvalidCursor = models.NewError(ErrValidation, "invalid_cursor", "cursor is invalid")
+
+// encodeCursor turns a keyset position into an opaque token for clients
+func encodeCursor(c *repository.Cursor) string {
+	raw := c.UploadedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a token from encodeCursor. An empty token means the first page.
+func decodeCursor(token string) (*repository.Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	uploadedAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	c := &repository.Cursor{}
+	if c.UploadedAt, err = time.Parse(time.RFC3339Nano, uploadedAt); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.ID, err = uuid.Parse(id); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// encodeListCursor turns a position in a sorted note listing into an opaque token. The
+// sort and order are part of the token so it can't be replayed against another order.
+func encodeListCursor(sort repository.NoteSort, descending bool, c *repository.ListCursor) string {
+	order := "asc"
+	if descending {
+		order = "desc"
+	}
+	// The value goes last since titles may contain the separator
+	raw := string(sort) + "|" + order + "|" + c.ID.String() + "|" + c.Value
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeListCursor parses a token from encodeListCursor for the given sort and order.
+// An empty token means the first page.
+func decodeListCursor(token string, sort repository.NoteSort, descending bool) (*repository.ListCursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), "|", 4)
+	if len(parts) != 4 {
+		return nil, ErrInvalidCursor
+	}
+	order := "asc"
+	if descending {
+		order = "desc"
+	}
+	if parts[0] != string(sort) || parts[1] != order {
+		return nil, ErrInvalidCursor
+	}
+
+	c := &repository.ListCursor{Value: parts[3]}
+	if c.ID, err = uuid.Parse(parts[2]); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	// Reject values the database would fail to cast
+	switch sort {
+	case repository.SortUploadedAt:
+		_, err = time.Parse(time.RFC3339Nano, c.Value)
+	case repository.SortFileSize:
+		_, err = strconv.ParseInt(c.Value, 10, 64)
+	}
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// noteSortValue returns a note's value for the sort column, as stored in a cursor
+func noteSortValue(note *models.Note, sort repository.NoteSort) string {
+	switch sort {
+	case repository.SortTitle:
+		return note.Title
+	case repository.SortFileSize:
+		return strconv.FormatInt(note.FileSize, 10)
+	case repository.SortCourseID:
+		return note.CourseID
+	default:
+		return note.UploadedAt.UTC().Format(time.RFC3339Nano)
+	}
+}
+
+// parseNoteListOptions validates a listing's sort, order, cursor and page size. Newest
+// uploads come first by default; the other sorts default to ascending.
+func parseNoteListOptions(opts *models.NoteListOptions) (*repository.NoteListQuery, error) {
+	sort := repository.NoteSort(opts.Sort)
+	switch sort {
+	case "":
+		sort = repository.SortUploadedAt
+	case repository.SortUploadedAt, repository.SortTitle, repository.SortFileSize, repository.SortCourseID:
+	default:
+		return nil, invalidf("sort", "sort must be one of uploaded_at, title, file_size or course_id")
+	}
+
+	var descending bool
+	switch opts.Order {
+	case "":
+		descending = sort == repository.SortUploadedAt
+	case "asc":
+	case "desc":
+		descending = true
+	default:
+		return nil, invalidf("order", "order must be asc or desc")
+	}
+
+	limit := opts.Limit
+	if limit == 0 {
+		limit = DefaultNotesPageSize
+	}
+	if limit < 0 || limit > MaxNotesPageSize {
+		return nil, invalidf("limit", "limit must be between 1 and %d", MaxNotesPageSize)
+	}
+
+	after, err := decodeListCursor(opts.Cursor, sort, descending)
+	if err != nil {
+		return nil, err
+	}
+
+	return &repository.NoteListQuery{Sort: sort, Descending: descending, After: after, Limit: limit}, nil
+}
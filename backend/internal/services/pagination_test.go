@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := &repository.Cursor{
+		UploadedAt: time.Date(2024, 9, 3, 14, 5, 6, 123456000, time.UTC),
+		ID:         uuid.New(),
+	}
+
+	got, err := decodeCursor(encodeCursor(want))
+	if err != nil {
+		t.Fatalf("decodeCursor() error = %v", err)
+	}
+	if !got.UploadedAt.Equal(want.UploadedAt) || got.ID != want.ID {
+		t.Errorf("decodeCursor() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	if c, err := decodeCursor(""); err != nil || c != nil {
+		t.Errorf("decodeCursor(\"\") = %v, %v, want nil, nil", c, err)
+	}
+
+	for _, token := range []string{"not base64!", "bm8tc2VwYXJhdG9y", "eHx5"} {
+		if _, err := decodeCursor(token); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("decodeCursor(%q) error = %v, want ErrInvalidCursor", token, err)
+		}
+	}
+}
+
+func TestListCursorRoundTrip(t *testing.T) {
+	want := &repository.ListCursor{Value: "Midterm | review", ID: uuid.New()}
+
+	token := encodeListCursor(repository.SortTitle, false, want)
+	got, err := decodeListCursor(token, repository.SortTitle, false)
+	if err != nil {
+		t.Fatalf("decodeListCursor() error = %v", err)
+	}
+	if *got != *want {
+		t.Errorf("decodeListCursor() = %+v, want %+v", got, want)
+	}
+
+	// A cursor only continues the listing it came from
+	if _, err := decodeListCursor(token, repository.SortTitle, true); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("decodeListCursor(other order) error = %v, want ErrInvalidCursor", err)
+	}
+	if _, err := decodeListCursor(token, repository.SortCourseID, false); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("decodeListCursor(other sort) error = %v, want ErrInvalidCursor", err)
+	}
+
+	// Values the database couldn't cast are rejected up front
+	bad := encodeListCursor(repository.SortFileSize, false, &repository.ListCursor{Value: "big", ID: uuid.New()})
+	if _, err := decodeListCursor(bad, repository.SortFileSize, false); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("decodeListCursor(bad file size) error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestParseNoteListOptions(t *testing.T) {
+	query, err := parseNoteListOptions(&models.NoteListOptions{})
+	if err != nil {
+		t.Fatalf("parseNoteListOptions() error = %v", err)
+	}
+	if query.Sort != repository.SortUploadedAt || !query.Descending || query.Limit != DefaultNotesPageSize || query.After != nil {
+		t.Errorf("parseNoteListOptions() defaults = %+v", query)
+	}
+
+	query, err = parseNoteListOptions(&models.NoteListOptions{Sort: "title", Limit: 10})
+	if err != nil {
+		t.Fatalf("parseNoteListOptions() error = %v", err)
+	}
+	if query.Sort != repository.SortTitle || query.Descending || query.Limit != 10 {
+		t.Errorf("parseNoteListOptions(title) = %+v, want ascending by title", query)
+	}
+
+	for _, opts := range []*models.NoteListOptions{
+		{Sort: "author"},
+		{Order: "up"},
+		{Limit: MaxNotesPageSize + 1},
+	} {
+		if _, err := parseNoteListOptions(opts); !errors.Is(err, ErrValidation) {
+			t.Errorf("parseNoteListOptions(%+v) error = %v, want %v", opts, err, ErrValidation)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	// AccessTokenTTL is how long an access JWT is valid; clients renew it with a refresh token
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL is how long a refresh token stays usable if it is never rotated
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens
+	ErrInvalidRefreshToken = models.NewError(ErrUnauthorized, "invalid_refresh_token", "session has expired, please sign in again")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented
+	// again; the whole session family has been revoked in response
+	ErrRefreshTokenReused = models.NewError(ErrUnauthorized, "invalid_refresh_token", "refresh token reuse detected, please sign in again")
+)
+
+// hashRefreshToken returns the form refresh tokens are stored in, so a database leak
+// does not hand out working tokens
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newSession creates a session in familyID for userInfo and returns it with its
+// plaintext refresh token
+func newSession(familyID uuid.UUID, userInfo *UserInfo) (*models.Session, string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	return &models.Session{
+		ID:          uuid.New(),
+		FamilyID:    familyID,
+		UserID:      userInfo.UserID,
+		UserEmail:   userInfo.Email,
+		UserName:    userInfo.Name,
+		UserPicture: userInfo.Picture,
+		TokenHash:   hashRefreshToken(token),
+		ExpiresAt:   time.Now().Add(RefreshTokenTTL),
+	}, token, nil
+}
+
+// startSession begins a new session family at login
+func (a *AuthService) startSession(ctx context.Context, userInfo *UserInfo) (*AuthResult, error) {
+	session, refreshToken, err := newSession(uuid.New(), userInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.sessions.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return a.issueTokens(ctx, session, refreshToken, userInfo)
+}
+
+// issueTokens builds the result for a session: a new access JWT plus its refresh token
+func (a *AuthService) issueTokens(ctx context.Context, session *models.Session, refreshToken string, userInfo *UserInfo) (*AuthResult, error) {
+	generation, err := a.revocations.GetTokenGeneration(ctx, userInfo.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	jwtToken, expiresAt, err := a.generateJWT(userInfo, session.ID, generation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate JWT: %w", err)
+	}
+
+	return &AuthResult{
+		Email:        userInfo.Email,
+		Name:         userInfo.Name,
+		Picture:      userInfo.Picture,
+		JWT:          jwtToken,
+		ExpiresAt:    expiresAt,
+		RefreshToken: refreshToken,
+		SessionID:    session.ID,
+	}, nil
+}
+
+// RefreshSession exchanges a refresh token for a new access token and a new refresh
+// token. Each refresh token works once: presenting one that was already rotated means
+// it was copied, so every token in its family is revoked.
+func (a *AuthService) RefreshSession(ctx context.Context, refreshToken string) (*AuthResult, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := a.sessions.GetSessionByTokenHash(ctx, hashRefreshToken(refreshToken))
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if session.RevokedAt != nil {
+		slog.Warn("Revoked refresh token presented", "familyID", session.FamilyID, "email", session.UserEmail)
+		return nil, ErrInvalidRefreshToken
+	}
+	if session.RotatedAt != nil {
+		return nil, a.revokeReusedFamily(ctx, session)
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Tokens carry the user's current profile, which may have changed since login
+	userInfo, err := a.userInfo(ctx, session.UserID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	next, nextToken, err := newSession(session.FamilyID, userInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.sessions.RotateSession(ctx, session.ID, next); err != nil {
+		if errors.Is(err, repository.ErrSessionRotated) {
+			// Another request rotated this token first
+			return nil, a.revokeReusedFamily(ctx, session)
+		}
+		return nil, err
+	}
+
+	slog.Info("Session refreshed", "email", session.UserEmail, "familyID", session.FamilyID)
+	return a.issueTokens(ctx, next, nextToken, userInfo)
+}
+
+// revokeReusedFamily revokes a session family after one of its rotated tokens was reused
+func (a *AuthService) revokeReusedFamily(ctx context.Context, session *models.Session) error {
+	slog.Warn("Refresh token reuse detected, revoking session family",
+		"familyID", session.FamilyID, "email", session.UserEmail)
+
+	if err := a.sessions.RevokeSessionFamily(ctx, session.FamilyID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// userInfo loads a stored user in the form tokens are issued from
+func (a *AuthService) userInfo(ctx context.Context, userID uuid.UUID) (*UserInfo, error) {
+	user, err := a.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserInfo{
+		Subject: user.GoogleSub,
+		Email:   user.Email,
+		Name:    user.Name,
+		Picture: user.Picture,
+		UserID:  user.ID,
+	}, nil
+}
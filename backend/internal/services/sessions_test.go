@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/google/uuid"
+)
+
+// fakeSessions is an in-memory SessionRepository
+type fakeSessions struct {
+	byHash map[string]*models.Session
+	codes  map[string]*models.AuthCode
+}
+
+func newFakeSessions() *fakeSessions {
+	return &fakeSessions{
+		byHash: make(map[string]*models.Session),
+		codes:  make(map[string]*models.AuthCode),
+	}
+}
+
+func (f *fakeSessions) CreateSession(ctx context.Context, session *models.Session) error {
+	session.CreatedAt = time.Now()
+	f.byHash[session.TokenHash] = session
+	return nil
+}
+
+func (f *fakeSessions) GetSessionByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	session, ok := f.byHash[tokenHash]
+	if !ok {
+		return nil, repository.ErrSessionNotFound
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (f *fakeSessions) RotateSession(ctx context.Context, oldID uuid.UUID, next *models.Session) error {
+	for _, session := range f.byHash {
+		if session.ID == oldID {
+			if session.RotatedAt != nil || session.RevokedAt != nil {
+				return repository.ErrSessionRotated
+			}
+			now := time.Now()
+			session.RotatedAt = &now
+			return f.CreateSession(ctx, next)
+		}
+	}
+	return repository.ErrSessionRotated
+}
+
+func (f *fakeSessions) RevokeSessionFamily(ctx context.Context, familyID uuid.UUID) error {
+	now := time.Now()
+	for _, session := range f.byHash {
+		if session.FamilyID == familyID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (f *fakeSessions) RevokeSessionFamilyOf(ctx context.Context, sessionID uuid.UUID) error {
+	for _, session := range f.byHash {
+		if session.ID == sessionID {
+			return f.RevokeSessionFamily(ctx, session.FamilyID)
+		}
+	}
+	return nil
+}
+
+func (f *fakeSessions) RevokeUserSessions(ctx context.Context, userID uuid.UUID) error {
+	now := time.Now()
+	for _, session := range f.byHash {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (f *fakeSessions) CreateAuthCode(ctx context.Context, code *models.AuthCode) error {
+	code.CreatedAt = time.Now()
+	f.codes[code.CodeHash] = code
+	return nil
+}
+
+func (f *fakeSessions) ConsumeAuthCode(ctx context.Context, codeHash string) (*models.AuthCode, error) {
+	code, ok := f.codes[codeHash]
+	if !ok || time.Now().After(code.ExpiresAt) {
+		return nil, repository.ErrAuthCodeNotFound
+	}
+	delete(f.codes, codeHash)
+	for _, session := range f.byHash {
+		if session.ID == code.SessionID {
+			code.UserID = session.UserID
+		}
+	}
+	return code, nil
+}
+
+func TestRefreshSession(t *testing.T) {
+	ctx := context.Background()
+	users := newFakeUsers()
+	service := NewAuthService(&fakeProvider{}, users, newFakeSessions(), newFakeRevocations(), "test-secret")
+	user, err := users.UpsertUser(ctx, &models.User{GoogleSub: "sub-1", Email: "student@rice.edu", Name: "Student"})
+	if err != nil {
+		t.Fatalf("UpsertUser() error = %v", err)
+	}
+
+	login, err := service.startSession(ctx, &UserInfo{UserID: user.ID, Email: user.Email, Name: user.Name})
+	if err != nil {
+		t.Fatalf("startSession() error = %v", err)
+	}
+
+	// The user's email changes at Google between logins; the session follows the account
+	if _, err := users.UpsertUser(ctx, &models.User{GoogleSub: "sub-1", Email: "sam.student@rice.edu", Name: "Student"}); err != nil {
+		t.Fatalf("UpsertUser() error = %v", err)
+	}
+
+	refreshed, err := service.RefreshSession(ctx, login.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshSession() error = %v", err)
+	}
+	if refreshed.RefreshToken == login.RefreshToken || refreshed.JWT == "" {
+		t.Fatal("RefreshSession() should rotate the refresh token and issue a new JWT")
+	}
+
+	claims, err := service.ValidateJWT(ctx, refreshed.JWT)
+	if err != nil {
+		t.Fatalf("ValidateJWT() error = %v", err)
+	}
+	if claims.Caller().UserID != user.ID || claims.Email != "sam.student@rice.edu" || claims.Name != "Student" {
+		t.Errorf("refreshed claims = %+v", claims)
+	}
+
+	// Replaying the first token is treated as theft and kills the family
+	if _, err := service.RefreshSession(ctx, login.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("RefreshSession(reused) error = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := service.RefreshSession(ctx, refreshed.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RefreshSession(after revocation) error = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestRefreshSession_Invalid(t *testing.T) {
+	ctx := context.Background()
+	sessions := newFakeSessions()
+	service := NewAuthService(&fakeProvider{}, newFakeUsers(), sessions, newFakeRevocations(), "test-secret")
+
+	if _, err := service.RefreshSession(ctx, "unknown"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RefreshSession(unknown) error = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	login, err := service.startSession(ctx, &UserInfo{Email: "student@rice.edu"})
+	if err != nil {
+		t.Fatalf("startSession() error = %v", err)
+	}
+	sessions.byHash[hashRefreshToken(login.RefreshToken)].ExpiresAt = time.Now().Add(-time.Minute)
+
+	if _, err := service.RefreshSession(ctx, login.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RefreshSession(expired) error = %v, want ErrInvalidRefreshToken", err)
+	}
+}
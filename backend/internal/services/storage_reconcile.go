@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/infra/storage"
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/google/uuid"
+)
+
+// DefaultReconcileGrace is how old a file or row must be before reconciliation
+// touches it. Files are stored before the rows that refer to them are written, so
+// anything younger may belong to a request still in flight.
+const DefaultReconcileGrace = 24 * time.Hour
+
+// ReconcileOptions tune a StorageReconciler run
+type ReconcileOptions struct {
+	// Grace skips files and rows younger than this
+	Grace time.Duration
+	// DryRun reports what is out of step without deleting anything
+	DryRun bool
+}
+
+// ReconcileReport describes what a reconciliation found and did
+type ReconcileReport struct {
+	// OrphanedObjects are stored files no row refers to
+	OrphanedObjects []storage.ObjectSummary
+	// MissingFiles are note revisions whose file is gone from storage
+	MissingFiles []*models.FileReference
+	// Deleted counts the files and rows deleted; always zero in a dry run
+	Deleted int
+	// Failed counts the deletions that failed, which the next run will retry
+	Failed int
+}
+
+// StorageReconciler finds what deleting notes and failed uploads leave behind: files
+// in storage that no row refers to, and rows whose file is gone
+type StorageReconciler struct {
+	files    repository.FileRepository
+	uploader storage.Uploader
+}
+
+// NewStorageReconciler creates a new storage reconciler
+func NewStorageReconciler(files repository.FileRepository, uploader storage.Uploader) *StorageReconciler {
+	return &StorageReconciler{
+		files:    files,
+		uploader: uploader,
+	}
+}
+
+// Reconcile compares the files stored under storage.NotesPrefix with the rows that
+// refer to them and, unless opts.DryRun is set, deletes the files without rows and
+// the rows without files. A note whose current file is missing is deleted whole,
+// leaving the files of its other revisions for the next run to find. Pending uploads
+// count as references, but their files are expected to be missing.
+func (r *StorageReconciler) Reconcile(ctx context.Context, opts ReconcileOptions) (*ReconcileReport, error) {
+	if opts.Grace < 0 {
+		return nil, invalidf("grace", "grace period must not be negative")
+	}
+	cutoff := time.Now().Add(-opts.Grace)
+
+	// Read the rows before listing storage: a file stored after this point is younger
+	// than the grace period, so its row being missing here does no harm
+	refs, err := r.files.ListFileReferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		referenced[ref.Key] = true
+	}
+
+	report := &ReconcileReport{}
+	stored := make(map[string]bool, len(refs))
+	err = r.uploader.List(ctx, storage.NotesPrefix, func(object storage.ObjectSummary) error {
+		stored[object.Key] = true
+		if !referenced[object.Key] && object.LastModified.Before(cutoff) {
+			report.OrphanedObjects = append(report.OrphanedObjects, object)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stored files: %w", err)
+	}
+
+	for _, ref := range refs {
+		if !ref.Pending && !stored[ref.Key] && ref.CreatedAt.Before(cutoff) {
+			report.MissingFiles = append(report.MissingFiles, ref)
+		}
+	}
+
+	slog.Info("Storage reconciled", "orphanedObjects", len(report.OrphanedObjects), "missingFiles", len(report.MissingFiles), "dryRun", opts.DryRun)
+	if opts.DryRun {
+		return report, nil
+	}
+
+	for _, object := range report.OrphanedObjects {
+		if err := r.uploader.Delete(ctx, object.Key); err != nil {
+			slog.Error("Failed to delete orphaned file", "error", err, "key", object.Key)
+			report.Failed++
+			continue
+		}
+		report.Deleted++
+	}
+
+	r.deleteMissingFiles(ctx, report)
+	return report, nil
+}
+
+// deleteMissingFiles deletes the rows in report.MissingFiles. Older revisions of a
+// note that is deleted whole go with it, so they are skipped.
+func (r *StorageReconciler) deleteMissingFiles(ctx context.Context, report *ReconcileReport) {
+	deletedNotes := make(map[uuid.UUID]bool)
+	for _, ref := range report.MissingFiles {
+		if ref.Current {
+			deletedNotes[ref.NoteID] = true
+		}
+	}
+
+	for _, ref := range report.MissingFiles {
+		if !ref.Current && deletedNotes[ref.NoteID] {
+			continue
+		}
+
+		err := r.files.DeleteFileReference(ctx, ref)
+		if errors.Is(err, repository.ErrNoteNotFound) {
+			// Deleted or given a new revision since it was listed
+			continue
+		}
+		if err != nil {
+			report.Failed++
+			continue
+		}
+		report.Deleted++
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/infra/storage"
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/google/uuid"
+)
+
+// fakeFileRepository serves a fixed set of file references and records deletions
+type fakeFileRepository struct {
+	refs    []*models.FileReference
+	deleted []*models.FileReference
+}
+
+func (f *fakeFileRepository) ListFileReferences(ctx context.Context) ([]*models.FileReference, error) {
+	return f.refs, nil
+}
+
+func (f *fakeFileRepository) DeleteFileReference(ctx context.Context, ref *models.FileReference) error {
+	f.deleted = append(f.deleted, ref)
+	return nil
+}
+
+var _ repository.FileRepository = (*fakeFileRepository)(nil)
+
+func TestStorageReconciler_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	uploader, err := storage.NewLocalUploader(root, "http://localhost:8080/files", []byte("test-secret"))
+	if err != nil {
+		t.Fatalf("NewLocalUploader() error = %v", err)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	store := func(key string, modified time.Time) {
+		t.Helper()
+		if err := uploader.Upload(ctx, key, strings.NewReader("%PDF-1.7"), AllowedContentType, 8); err != nil {
+			t.Fatalf("Upload(%q) error = %v", key, err)
+		}
+		if err := os.Chtimes(filepath.Join(root, filepath.FromSlash(key)), modified, modified); err != nil {
+			t.Fatalf("Chtimes(%q) error = %v", key, err)
+		}
+	}
+
+	store("notes/u/kept/a.pdf", old)
+	store("notes/u/orphan/a.pdf", old)
+	store("notes/u/uploading/a.pdf", old)
+	store("notes/u/fresh/a.pdf", time.Now())
+	store("other/a.pdf", old)
+
+	kept, missing, partly, uploading := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	refs := []*models.FileReference{
+		{Key: "notes/u/kept/a.pdf", NoteID: kept, Version: 1, Current: true, CreatedAt: old},
+		{Key: "notes/u/missing/a.pdf", NoteID: missing, Version: 1, CreatedAt: old},
+		{Key: "notes/u/missing/b.pdf", NoteID: missing, Version: 2, Current: true, CreatedAt: old},
+		{Key: "notes/u/partly/a.pdf", NoteID: partly, Version: 1, CreatedAt: old},
+		{Key: "notes/u/kept/a.pdf", NoteID: partly, Version: 2, Current: true, CreatedAt: old},
+		{Key: "notes/u/new/a.pdf", NoteID: uuid.New(), Version: 1, Current: true, CreatedAt: time.Now()},
+		{Key: "notes/u/uploading/a.pdf", NoteID: uploading, Pending: true, CreatedAt: old},
+		{Key: "notes/u/uploading/b.pdf", NoteID: uuid.New(), Pending: true, CreatedAt: old},
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		files := &fakeFileRepository{refs: refs}
+		report, err := NewStorageReconciler(files, uploader).Reconcile(ctx, ReconcileOptions{Grace: DefaultReconcileGrace, DryRun: true})
+		if err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+
+		var orphaned []string
+		for _, object := range report.OrphanedObjects {
+			orphaned = append(orphaned, object.Key)
+		}
+		if want := []string{"notes/u/orphan/a.pdf"}; !reflect.DeepEqual(orphaned, want) {
+			t.Errorf("Reconcile() orphaned objects = %v, want %v", orphaned, want)
+		}
+		if want := []*models.FileReference{refs[1], refs[2], refs[3]}; !reflect.DeepEqual(report.MissingFiles, want) {
+			t.Errorf("Reconcile() missing files = %+v, want %+v", report.MissingFiles, want)
+		}
+
+		if report.Deleted != 0 || len(files.deleted) != 0 {
+			t.Errorf("Reconcile() deleted %d, want nothing in a dry run", report.Deleted)
+		}
+		if _, err := uploader.Stat(ctx, "notes/u/orphan/a.pdf"); err != nil {
+			t.Errorf("Stat() of orphaned file after dry run error = %v", err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		files := &fakeFileRepository{refs: refs}
+		report, err := NewStorageReconciler(files, uploader).Reconcile(ctx, ReconcileOptions{Grace: DefaultReconcileGrace})
+		if err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+
+		// The missing note goes whole, taking its older revision with it
+		if want := []*models.FileReference{refs[2], refs[3]}; !reflect.DeepEqual(files.deleted, want) {
+			t.Errorf("Reconcile() deleted rows %+v, want %+v", files.deleted, want)
+		}
+		if report.Deleted != 3 || report.Failed != 0 {
+			t.Errorf("Reconcile() deleted %d, failed %d, want 3 and 0", report.Deleted, report.Failed)
+		}
+
+		for key, want := range map[string]bool{
+			"notes/u/orphan/a.pdf":    false,
+			"notes/u/kept/a.pdf":      true,
+			"notes/u/uploading/a.pdf": true,
+			"notes/u/fresh/a.pdf":     true,
+			"other/a.pdf":             true,
+		} {
+			_, err := uploader.Stat(ctx, key)
+			if exists := err == nil; exists != want {
+				t.Errorf("after Reconcile() %s exists = %v, want %v", key, exists, want)
+			}
+		}
+	})
+
+	t.Run("negative grace", func(t *testing.T) {
+		_, err := NewStorageReconciler(&fakeFileRepository{}, uploader).Reconcile(ctx, ReconcileOptions{Grace: -time.Hour})
+		if !errors.Is(err, ErrValidation) {
+			t.Errorf("Reconcile() error = %v, want %v", err, ErrValidation)
+		}
+	})
+}
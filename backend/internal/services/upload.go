@@ -0,0 +1,155 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/angel-romero-f/rice-notes/internal/infra/pdf"
+)
+
+// DefaultMaxFileSize is the largest file accepted when no limit is configured (10MB)
+const DefaultMaxFileSize = 10 * 1024 * 1024
+
+// Upload is a file as it arrives from the client. Body is read exactly once, so the
+// file is never held in memory as a whole.
+type Upload struct {
+	FileName string
+	Body     io.Reader
+}
+
+// storedUpload describes an upload once it has been checked and stored
+type storedUpload struct {
+	Size   int64
+	SHA256 string
+	Info   *pdf.Info
+}
+
+// validateFile checks an upload is present and named like a PDF
+func validateFile(upload *Upload) error {
+	if upload == nil || upload.Body == nil {
+		return invalidf("file", "file is required")
+	}
+	return validateFileName("file", upload.FileName)
+}
+
+// validateFileName checks a file is named like a PDF, with no path or control
+// characters in the name; field names the request field it came from
+func validateFileName(field, fileName string) error {
+	if strings.ContainsAny(fileName, `/\`) || strings.IndexFunc(fileName, unicode.IsControl) >= 0 {
+		return invalidf(field, "file name must not contain path separators or control characters")
+	}
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if ext != ".pdf" {
+		return invalidf(field, "only PDF files are allowed")
+	}
+	return nil
+}
+
+// storeUpload streams an upload into storage under key. The size limit and the PDF
+// header are checked and the file is hashed as it streams; a copy is spooled to a
+// temporary file so the whole document can be inspected once it has arrived. A file
+// that fails inspection is deleted from storage again. Its text is extracted later,
+// by the JobProcessNote job queued with the note.
+func (s *NoteService) storeUpload(ctx context.Context, key string, upload *Upload) (*storedUpload, error) {
+	body := &uploadReader{r: upload.Body, limit: s.maxFileSize}
+
+	// Reject anything that isn't a PDF before sending a byte to storage
+	head := make([]byte, pdf.HeaderSearchWindow)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, body.failure(err)
+	}
+	if n == 0 {
+		return nil, invalidf("file", "file cannot be empty")
+	}
+	head = head[:n]
+	if err := pdf.CheckHeader(head); err != nil {
+		return nil, err
+	}
+
+	spool, err := os.CreateTemp("", "upload-*.pdf")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload spool: %w", err)
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+
+	hash := sha256.New()
+	stream := io.TeeReader(io.MultiReader(bytes.NewReader(head), body), io.MultiWriter(hash, spool))
+
+	if err := s.uploader.Upload(ctx, key, stream, AllowedContentType, -1); err != nil {
+		if body.err != nil {
+			return nil, body.failure(body.err)
+		}
+		slog.Error("Failed to upload file to storage", "error", err, "key", key)
+		return nil, upstreamError("file storage", err)
+	}
+
+	stored := &storedUpload{Size: body.n, SHA256: hex.EncodeToString(hash.Sum(nil))}
+
+	// The extension alone proves nothing, so check the bytes really are a PDF
+	stored.Info, err = pdf.Inspect(spool, stored.Size)
+	if err != nil {
+		if deleteErr := s.uploader.Delete(ctx, key); deleteErr != nil {
+			slog.Error("Failed to delete rejected file", "deleteError", deleteErr, "key", key)
+		}
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+// errFileTooLarge stops an upload once it passes the size limit
+var errFileTooLarge = errors.New("file too large")
+
+// uploadReader counts the bytes read from an upload, stops at the size limit and
+// remembers why reading failed, so storage errors caused by the client can be told
+// apart from storage failing
+type uploadReader struct {
+	r     io.Reader
+	n     int64
+	limit int64
+	err   error
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	if u.err != nil {
+		return 0, u.err
+	}
+
+	// Read one byte past the limit so a file of exactly the limit is allowed
+	if remaining := u.limit - u.n + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := u.r.Read(p)
+	u.n += int64(n)
+	if u.n > u.limit {
+		u.err = errFileTooLarge
+		return 0, u.err
+	}
+	if err != nil && err != io.EOF {
+		u.err = err
+	}
+	return n, err
+}
+
+// failure reports why reading the upload failed
+func (u *uploadReader) failure(err error) error {
+	if errors.Is(err, errFileTooLarge) {
+		return invalidf("file", "file size must be at most %d bytes", u.limit)
+	}
+	return fmt.Errorf("failed to read uploaded file: %w", err)
+}
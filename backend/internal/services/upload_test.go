@@ -0,0 +1,168 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/infra/pdf"
+	"github.com/angel-romero-f/rice-notes/internal/infra/storage"
+	"github.com/angel-romero-f/rice-notes/internal/models"
+)
+
+// onePagePDF builds a minimal well-formed PDF, padded with a comment to size bytes
+// or more
+func onePagePDF(size int) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	if pad := size - 400; pad > 0 {
+		fmt.Fprintf(&buf, "%%%s\n", strings.Repeat("x", pad))
+	}
+	offsets := make([]int, len(objects))
+	for i, body := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// stored reports whether the mock uploader holds a file under key
+func stored(uploader *storage.MockUploader, key string) bool {
+	_, err := uploader.GetPresignedURL(context.Background(), key, "a.pdf", time.Minute)
+	return err == nil
+}
+
+func TestStoreUpload(t *testing.T) {
+	valid := onePagePDF(64 << 10)
+
+	tests := []struct {
+		name       string
+		data       []byte
+		limit      int64
+		wantCode   string
+		wantField  string
+		wantStored bool
+	}{
+		{name: "valid", data: valid, limit: 1 << 20, wantStored: true},
+		{name: "exactly the limit", data: valid, limit: int64(len(valid)), wantStored: true},
+		{name: "over the limit", data: valid, limit: int64(len(valid)) - 1, wantField: "file"},
+		{name: "empty", data: nil, limit: 1 << 20, wantField: "file"},
+		{name: "not a pdf", data: []byte("PK\x03\x04 not a pdf"), limit: 1 << 20, wantCode: pdf.CodeNotPDF},
+		{name: "malformed", data: valid[:len(valid)/2], limit: 1 << 20, wantCode: pdf.CodeMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploader := storage.NewMockUploader()
+			s := &NoteService{uploader: uploader, maxFileSize: tt.limit}
+			key := "notes/u/n/a.pdf"
+
+			got, err := s.storeUpload(context.Background(), key, &Upload{FileName: "a.pdf", Body: bytes.NewReader(tt.data)})
+
+			if stored(uploader, key) != tt.wantStored {
+				t.Errorf("storeUpload() left file stored = %v, want %v", !tt.wantStored, tt.wantStored)
+			}
+
+			switch {
+			case tt.wantField != "":
+				var validationErr *models.Error
+				if !errors.As(err, &validationErr) || validationErr.Field != tt.wantField {
+					t.Fatalf("storeUpload() error = %v, want a validation error for %s", err, tt.wantField)
+				}
+				return
+			case tt.wantCode != "":
+				var pdfErr *pdf.Error
+				if !errors.As(err, &pdfErr) || pdfErr.Code != tt.wantCode {
+					t.Fatalf("storeUpload() error = %v, want code %s", err, tt.wantCode)
+				}
+				return
+			case err != nil:
+				t.Fatalf("storeUpload() error = %v", err)
+			}
+
+			sum := sha256.Sum256(tt.data)
+			if got.SHA256 != hex.EncodeToString(sum[:]) {
+				t.Errorf("storeUpload() SHA256 = %s, want %x", got.SHA256, sum)
+			}
+			if got.Size != int64(len(tt.data)) {
+				t.Errorf("storeUpload() Size = %d, want %d", got.Size, len(tt.data))
+			}
+			if got.Info.PageCount != 1 {
+				t.Errorf("storeUpload() PageCount = %d, want 1", got.Info.PageCount)
+			}
+		})
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	body := strings.NewReader("%PDF-1.7")
+	tests := []struct {
+		upload  *Upload
+		wantErr bool
+	}{
+		{upload: &Upload{FileName: "Lecture.PDF", Body: body}},
+		{upload: &Upload{FileName: "lecture.docx", Body: body}, wantErr: true},
+		{upload: &Upload{FileName: "lecture.pdf"}, wantErr: true},
+		{upload: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := validateFile(tt.upload); (err != nil) != tt.wantErr {
+			t.Errorf("validateFile(%+v) error = %v, wantErr %v", tt.upload, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateUploadFile(t *testing.T) {
+	s := &NoteService{maxFileSize: 1 << 20}
+	checksum := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		fileName  string
+		size      int64
+		checksum  string
+		wantField string
+	}{
+		{fileName: "lecture.pdf", size: 1 << 20, checksum: checksum},
+		{fileName: "lecture.docx", size: 1024, checksum: checksum, wantField: "file_name"},
+		{fileName: "../../other-user/lecture.pdf", size: 1024, checksum: checksum, wantField: "file_name"},
+		{fileName: `..\lecture.pdf`, size: 1024, checksum: checksum, wantField: "file_name"},
+		{fileName: "lecture\n.pdf", size: 1024, checksum: checksum, wantField: "file_name"},
+		{fileName: "lecture.pdf", size: 0, checksum: checksum, wantField: "file_size"},
+		{fileName: "lecture.pdf", size: 1<<20 + 1, checksum: checksum, wantField: "file_size"},
+		{fileName: "lecture.pdf", size: 1024, checksum: checksum[:62], wantField: "sha256"},
+		{fileName: "lecture.pdf", size: 1024, checksum: strings.Repeat("zz", 32), wantField: "sha256"},
+	}
+
+	for _, tt := range tests {
+		err := s.validateUploadFile(tt.fileName, tt.size, tt.checksum)
+		if tt.wantField == "" {
+			if err != nil {
+				t.Errorf("validateUploadFile(%q, %d, %q) error = %v", tt.fileName, tt.size, tt.checksum, err)
+			}
+			continue
+		}
+		var validationErr *models.Error
+		if !errors.As(err, &validationErr) || validationErr.Field != tt.wantField {
+			t.Errorf("validateUploadFile(%q, %d, %q) error = %v, want a validation error for %s", tt.fileName, tt.size, tt.checksum, err, tt.wantField)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+)
+
+// ErrUserNotFound is returned when the signed-in user has no users row
+var ErrUserNotFound = repository.ErrUserNotFound
+
+// UserService handles user profile operations
+type UserService struct {
+	users repository.UserRepository
+}
+
+// NewUserService creates a new UserService instance
+func NewUserService(users repository.UserRepository) *UserService {
+	return &UserService{users: users}
+}
+
+// GetCurrentUser returns the profile of the signed-in user
+func (s *UserService) GetCurrentUser(ctx context.Context, caller Caller) (*models.User, error) {
+	return s.users.GetUserByID(ctx, caller.UserID)
+}
+
+// UpdateCurrentUser changes the signed-in user's profile fields
+func (s *UserService) UpdateCurrentUser(ctx context.Context, caller Caller, req *models.UpdateUserRequest) (*models.User, error) {
+	if req.DisplayName != nil {
+		displayName := strings.TrimSpace(*req.DisplayName)
+		if err := validateDisplayName(displayName); err != nil {
+			return nil, err
+		}
+		req.DisplayName = &displayName
+	}
+
+	return s.users.UpdateUser(ctx, caller.UserID, req)
+}
+
+// validateDisplayName checks a display name; an empty one clears it
+func validateDisplayName(displayName string) error {
+	if utf8.RuneCountInString(displayName) > 100 {
+		return invalidf("display_name", "display name must be 100 characters or less")
+	}
+
+	for _, r := range displayName {
+		if unicode.IsControl(r) {
+			return invalidf("display_name", "display name contains invalid characters")
+		}
+	}
+
+	return nil
+}
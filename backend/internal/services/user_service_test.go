@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angel-romero-f/rice-notes/internal/models"
+	"github.com/angel-romero-f/rice-notes/internal/repository"
+	"github.com/google/uuid"
+)
+
+// fakeUsers is an in-memory UserRepository
+type fakeUsers struct {
+	byEmail map[string]*models.User
+}
+
+func newFakeUsers() *fakeUsers {
+	return &fakeUsers{byEmail: make(map[string]*models.User)}
+}
+
+func (f *fakeUsers) UpsertUser(ctx context.Context, user *models.User) (*models.User, error) {
+	now := time.Now()
+	var stored *models.User
+	for _, existing := range f.byEmail {
+		if user.GoogleSub != "" && existing.GoogleSub == user.GoogleSub {
+			stored = existing
+		}
+	}
+	if stored == nil {
+		if existing, ok := f.byEmail[user.Email]; ok {
+			if existing.GoogleSub != "" && existing.GoogleSub != user.GoogleSub {
+				return nil, repository.ErrEmailTaken
+			}
+			stored = existing
+		} else {
+			stored = &models.User{ID: uuid.New(), CreatedAt: now}
+		}
+	}
+	delete(f.byEmail, stored.Email)
+	stored.Email = user.Email
+	f.byEmail[user.Email] = stored
+	if user.GoogleSub != "" {
+		stored.GoogleSub = user.GoogleSub
+	}
+	stored.Name, stored.Picture, stored.LastLoginAt = user.Name, user.Picture, &now
+	copied := *stored
+	return &copied, nil
+}
+
+func (f *fakeUsers) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	for _, user := range f.byEmail {
+		if user.ID == id {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrUserNotFound
+}
+
+func (f *fakeUsers) UpdateUser(ctx context.Context, id uuid.UUID, update *models.UpdateUserRequest) (*models.User, error) {
+	for _, user := range f.byEmail {
+		if user.ID == id {
+			if update.DisplayName != nil {
+				user.DisplayName = update.DisplayName
+				if *update.DisplayName == "" {
+					user.DisplayName = nil
+				}
+			}
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrUserNotFound
+}
+
+func TestGoogleLoginRecordsUser(t *testing.T) {
+	provider := &fakeProvider{}
+	users := newFakeUsers()
+	service := NewAuthService(provider, users, newFakeSessions(), newFakeRevocations(), "test-secret")
+
+	attempt, err := service.BeginGoogleLogin("", "")
+	if err != nil {
+		t.Fatalf("BeginGoogleLogin() error = %v", err)
+	}
+	if _, err := service.CompleteGoogleLogin(context.Background(), "code", provider.state, attempt.StateCookie); err != nil {
+		t.Fatalf("CompleteGoogleLogin() error = %v", err)
+	}
+
+	user, ok := users.byEmail["student@rice.edu"]
+	if !ok {
+		t.Fatal("CompleteGoogleLogin() did not record the user")
+	}
+	if user.Name != "Student" || user.LastLoginAt == nil {
+		t.Errorf("recorded user = %+v", user)
+	}
+}
+
+func TestGoogleLoginKeysUserBySubject(t *testing.T) {
+	ctx := context.Background()
+	provider := &fakeProvider{}
+	users := newFakeUsers()
+	service := NewAuthService(provider, users, newFakeSessions(), newFakeRevocations(), "test-secret")
+
+	login := func(user *UserInfo) (*JWTClaims, error) {
+		t.Helper()
+		provider.user = user
+		attempt, err := service.BeginGoogleLogin("", "")
+		if err != nil {
+			t.Fatalf("BeginGoogleLogin() error = %v", err)
+		}
+		result, err := service.CompleteGoogleLogin(ctx, "code", provider.state, attempt.StateCookie)
+		if err != nil {
+			return nil, err
+		}
+		return service.ValidateJWT(ctx, result.JWT)
+	}
+
+	first, err := login(&UserInfo{Subject: "sub-1", Email: "Student@Rice.edu", Name: "Student"})
+	if err != nil {
+		t.Fatalf("first login error = %v", err)
+	}
+	if first.Email != "student@rice.edu" {
+		t.Errorf("Email = %q, want it canonicalized", first.Email)
+	}
+
+	// A changed email is the same account as long as Google's subject matches
+	renamed, err := login(&UserInfo{Subject: "sub-1", Email: "sam.student@rice.edu", Name: "Student"})
+	if err != nil {
+		t.Fatalf("renamed login error = %v", err)
+	}
+	if renamed.Caller().UserID != first.Caller().UserID {
+		t.Errorf("UserID changed from %v to %v after an email change", first.UserID, renamed.UserID)
+	}
+
+	// Another Google account can't take over the email
+	if _, err := login(&UserInfo{Subject: "sub-2", Email: "sam.student@rice.edu", Name: "Imposter"}); !errors.Is(err, repository.ErrEmailTaken) {
+		t.Errorf("login with another subject error = %v, want ErrEmailTaken", err)
+	}
+
+	if _, err := login(&UserInfo{Email: "student@rice.edu"}); err == nil {
+		t.Error("login without a subject succeeded, want error")
+	}
+}
+
+func TestUpdateCurrentUser(t *testing.T) {
+	ctx := context.Background()
+	users := newFakeUsers()
+	service := NewUserService(users)
+	stored, err := users.UpsertUser(ctx, &models.User{GoogleSub: "sub-1", Email: "student@rice.edu", Name: "Student"})
+	if err != nil {
+		t.Fatalf("UpsertUser() error = %v", err)
+	}
+	caller := Caller{UserID: stored.ID, Email: stored.Email}
+
+	name := "  Sammy  "
+	user, err := service.UpdateCurrentUser(ctx, caller, &models.UpdateUserRequest{DisplayName: &name})
+	if err != nil {
+		t.Fatalf("UpdateCurrentUser() error = %v", err)
+	}
+	if user.DisplayName == nil || *user.DisplayName != "Sammy" {
+		t.Errorf("DisplayName = %v, want Sammy", user.DisplayName)
+	}
+
+	// Omitted fields are left alone
+	user, err = service.UpdateCurrentUser(ctx, caller, &models.UpdateUserRequest{})
+	if err != nil {
+		t.Fatalf("UpdateCurrentUser() error = %v", err)
+	}
+	if user.DisplayName == nil || *user.DisplayName != "Sammy" {
+		t.Errorf("DisplayName = %v, want it unchanged", user.DisplayName)
+	}
+
+	empty := ""
+	user, err = service.UpdateCurrentUser(ctx, caller, &models.UpdateUserRequest{DisplayName: &empty})
+	if err != nil {
+		t.Fatalf("UpdateCurrentUser() error = %v", err)
+	}
+	if user.DisplayName != nil {
+		t.Errorf("DisplayName = %v, want it cleared", *user.DisplayName)
+	}
+
+	for _, bad := range []string{strings.Repeat("a", 101), "tab\tname"} {
+		if _, err := service.UpdateCurrentUser(ctx, caller, &models.UpdateUserRequest{DisplayName: &bad}); !errors.Is(err, ErrValidation) {
+			t.Errorf("UpdateCurrentUser(%q) error = %v, want %v", bad, err, ErrValidation)
+		}
+	}
+
+	if _, err := service.GetCurrentUser(ctx, Caller{UserID: uuid.New(), Email: "nobody@rice.edu"}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetCurrentUser(unknown) error = %v, want ErrUserNotFound", err)
+	}
+}
@@ -1,44 +0,0 @@
--- Migration: 001_create_notes_table
--- Description: Create notes table for storing PDF file metadata
-
--- +migrate Up
-CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
-
-CREATE TABLE notes (
-    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
-    user_email VARCHAR(255) NOT NULL,
-    title VARCHAR(255) NOT NULL,
-    course_id VARCHAR(50) NOT NULL,
-    file_name VARCHAR(255) NOT NULL,
-    file_path VARCHAR(500) NOT NULL,
-    file_size BIGINT NOT NULL,
-    content_type VARCHAR(100) NOT NULL DEFAULT 'application/pdf',
-    uploaded_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-);
-
--- Create indexes for better query performance
-CREATE INDEX idx_notes_user_email ON notes(user_email);
-CREATE INDEX idx_notes_course_id ON notes(course_id);
-CREATE INDEX idx_notes_user_course ON notes(user_email, course_id);
-CREATE INDEX idx_notes_uploaded_at ON notes(uploaded_at);
-
--- Create function to auto-update updated_at column
-CREATE OR REPLACE FUNCTION update_updated_at_column()
-RETURNS TRIGGER AS $$
-BEGIN
-    NEW.updated_at = CURRENT_TIMESTAMP;
-    RETURN NEW;
-END;
-$$ language 'plpgsql';
-
--- Create trigger to automatically update updated_at
-CREATE TRIGGER update_notes_updated_at 
-    BEFORE UPDATE ON notes 
-    FOR EACH ROW 
-    EXECUTE FUNCTION update_updated_at_column();
-
--- +migrate Down
-DROP TRIGGER IF EXISTS update_notes_updated_at ON notes;
-DROP FUNCTION IF EXISTS update_updated_at_column();
-DROP TABLE IF EXISTS notes;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE notes DROP COLUMN IF EXISTS pdf_version;
+ALTER TABLE notes DROP COLUMN IF EXISTS page_count;
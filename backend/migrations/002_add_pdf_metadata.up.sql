@@ -0,0 +1,4 @@
+-- Page count and PDF version are recorded when an upload passes validation.
+-- Rows created before validation existed keep NULLs.
+ALTER TABLE notes ADD COLUMN page_count INTEGER;
+ALTER TABLE notes ADD COLUMN pdf_version VARCHAR(10);
@@ -0,0 +1,10 @@
+-- Text extracted from each uploaded PDF, searchable together with the title
+ALTER TABLE notes ADD COLUMN content_text TEXT NOT NULL DEFAULT '';
+
+-- Titles rank above body text
+ALTER TABLE notes ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(content_text, '')), 'B')
+) STORED;
+
+CREATE INDEX idx_notes_search_vector ON notes USING GIN (search_vector);
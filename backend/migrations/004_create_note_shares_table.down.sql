@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS update_note_shares_updated_at ON note_shares;
+DROP INDEX IF EXISTS idx_note_shares_grantee_email;
+DROP TABLE IF EXISTS note_shares;
@@ -0,0 +1,17 @@
+-- Grants access to a note for another Rice user. Emails are stored lowercased.
+CREATE TABLE note_shares (
+    note_id UUID NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+    grantee_email VARCHAR(255) NOT NULL,
+    role VARCHAR(10) NOT NULL CHECK (role IN ('viewer', 'editor')),
+    granted_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (note_id, grantee_email)
+);
+
+CREATE INDEX idx_note_shares_grantee_email ON note_shares(grantee_email);
+
+CREATE TRIGGER update_note_shares_updated_at 
+    BEFORE UPDATE ON note_shares 
+    FOR EACH ROW 
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_notes_course_published;
+ALTER TABLE notes DROP COLUMN IF EXISTS author_name;
+ALTER TABLE notes DROP COLUMN IF EXISTS visibility;
@@ -0,0 +1,11 @@
+-- Who besides the owner and explicit shares can see a note: nobody (private),
+-- students taking the course (course), or any Rice user (rice)
+ALTER TABLE notes ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'private'
+    CHECK (visibility IN ('private', 'course', 'rice'));
+
+-- Display name shown in course listings instead of the uploader's email
+ALTER TABLE notes ADD COLUMN author_name VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Keyset pagination over a course's published notes, newest first
+CREATE INDEX idx_notes_course_published ON notes(course_id, uploaded_at DESC, id DESC)
+    WHERE visibility <> 'private';
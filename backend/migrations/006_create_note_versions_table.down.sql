@@ -0,0 +1,2 @@
+ALTER TABLE notes DROP COLUMN IF EXISTS current_version;
+DROP TABLE IF EXISTS note_versions;
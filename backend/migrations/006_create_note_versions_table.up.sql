@@ -0,0 +1,26 @@
+-- Every revision of a note's file, including the current one. Restoring an old
+-- revision appends a new revision pointing at the same stored file.
+CREATE TABLE note_versions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    note_id UUID NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    file_path TEXT NOT NULL,
+    file_size BIGINT NOT NULL,
+    content_type VARCHAR(100) NOT NULL DEFAULT 'application/pdf',
+    page_count INTEGER,
+    pdf_version VARCHAR(10),
+    content_text TEXT NOT NULL DEFAULT '',
+    uploaded_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (note_id, version)
+);
+
+ALTER TABLE notes ADD COLUMN current_version INTEGER NOT NULL DEFAULT 1;
+
+-- Existing notes become revision 1 of themselves
+INSERT INTO note_versions (note_id, version, file_name, file_path, file_size, content_type,
+    page_count, pdf_version, content_text, uploaded_by, created_at)
+SELECT id, 1, file_name, file_path, file_size, content_type,
+    page_count, pdf_version, content_text, user_email, COALESCE(uploaded_at, NOW())
+FROM notes;
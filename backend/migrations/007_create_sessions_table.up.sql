@@ -0,0 +1,17 @@
+-- One row per refresh token. Rotating a token marks it rotated and inserts its
+-- successor in the same family; presenting a rotated token again revokes the family.
+CREATE TABLE sessions (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL,
+    user_email VARCHAR(255) NOT NULL,
+    user_name VARCHAR(255) NOT NULL DEFAULT '',
+    user_picture TEXT NOT NULL DEFAULT '',
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    rotated_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_sessions_family_id ON sessions(family_id);
+CREATE INDEX idx_sessions_user_email ON sessions(user_email);
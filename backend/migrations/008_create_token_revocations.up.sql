@@ -0,0 +1,18 @@
+-- Access tokens revoked before they expire, keyed by their jti. A row only needs to
+-- live as long as the token would have, so expired rows are purged.
+CREATE TABLE revoked_tokens (
+    jti UUID PRIMARY KEY,
+    user_email VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
+
+-- Per-user token generation. Every access token carries the generation it was issued
+-- in; bumping it ("log out everywhere") invalidates all of the user's tokens at once.
+CREATE TABLE user_token_generations (
+    user_email VARCHAR(255) PRIMARY KEY,
+    generation INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
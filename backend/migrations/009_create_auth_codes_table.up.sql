@@ -0,0 +1,15 @@
+-- One-time codes handed to the frontend after login in place of the JWT. Each is
+-- redeemed once, within a minute, by the client holding the PKCE verifier for
+-- client_challenge.
+CREATE TABLE auth_codes (
+    code_hash CHAR(64) PRIMARY KEY,
+    session_id UUID NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    user_email VARCHAR(255) NOT NULL,
+    user_name VARCHAR(255) NOT NULL DEFAULT '',
+    user_picture TEXT NOT NULL DEFAULT '',
+    client_challenge VARCHAR(128) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_auth_codes_expires_at ON auth_codes(expires_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_notes_user_id;
+ALTER TABLE notes DROP COLUMN IF EXISTS user_id;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,34 @@
+-- Everyone who has signed in, upserted at each login
+CREATE TABLE users (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    google_sub VARCHAR(255) UNIQUE,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    picture TEXT NOT NULL DEFAULT '',
+    display_name VARCHAR(100),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_login_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER update_users_updated_at
+    BEFORE UPDATE ON users
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Users who uploaded notes before this table existed; their Google subject is
+-- filled in the next time they sign in
+INSERT INTO users (email, created_at)
+SELECT user_email, MIN(uploaded_at)
+FROM notes
+GROUP BY user_email
+ON CONFLICT (email) DO NOTHING;
+
+-- Notes reference their owner's row; user_email stays for compatibility
+ALTER TABLE notes ADD COLUMN user_id UUID REFERENCES users(id);
+
+UPDATE notes SET user_id = users.id
+FROM users
+WHERE users.email = notes.user_email;
+
+CREATE INDEX idx_notes_user_id ON notes(user_id);
@@ -0,0 +1,5 @@
+-- Emails stay canonical and merged users stay merged; only the constraints are undone
+ALTER TABLE sessions DROP COLUMN IF EXISTS user_id;
+DROP INDEX IF EXISTS idx_notes_user_course_id;
+ALTER TABLE notes ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_canonical;
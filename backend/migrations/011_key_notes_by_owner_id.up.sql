@@ -0,0 +1,64 @@
+-- Emails are compared in canonical (trimmed, lower-case) form from now on. Users whose
+-- rows differ only by case are merged into one, preferring the row Google has signed in.
+CREATE TEMP TABLE user_merges ON COMMIT DROP AS
+SELECT id,
+       FIRST_VALUE(id) OVER (
+           PARTITION BY LOWER(TRIM(email))
+           ORDER BY (google_sub IS NULL), last_login_at DESC NULLS LAST, created_at
+       ) AS keep_id
+FROM users;
+
+UPDATE notes SET user_id = user_merges.keep_id
+FROM user_merges
+WHERE notes.user_id = user_merges.id AND user_merges.id <> user_merges.keep_id;
+
+DELETE FROM users
+USING user_merges
+WHERE users.id = user_merges.id AND user_merges.id <> user_merges.keep_id;
+
+UPDATE users SET email = LOWER(TRIM(email)) WHERE email <> LOWER(TRIM(email));
+ALTER TABLE users ADD CONSTRAINT users_email_canonical CHECK (email = LOWER(TRIM(email)));
+
+UPDATE notes SET user_email = LOWER(TRIM(user_email)) WHERE user_email <> LOWER(TRIM(user_email));
+UPDATE note_versions SET uploaded_by = LOWER(TRIM(uploaded_by)) WHERE uploaded_by <> LOWER(TRIM(uploaded_by));
+UPDATE note_shares SET granted_by = LOWER(TRIM(granted_by)) WHERE granted_by <> LOWER(TRIM(granted_by));
+UPDATE sessions SET user_email = LOWER(TRIM(user_email)) WHERE user_email <> LOWER(TRIM(user_email));
+UPDATE revoked_tokens SET user_email = LOWER(TRIM(user_email)) WHERE user_email <> LOWER(TRIM(user_email));
+UPDATE auth_codes SET user_email = LOWER(TRIM(user_email)) WHERE user_email <> LOWER(TRIM(user_email));
+
+-- Token generations of merged spellings keep the highest, so nothing is un-revoked
+INSERT INTO user_token_generations (user_email, generation)
+SELECT LOWER(TRIM(user_email)), MAX(generation)
+FROM user_token_generations
+WHERE user_email <> LOWER(TRIM(user_email))
+GROUP BY LOWER(TRIM(user_email))
+ON CONFLICT (user_email) DO UPDATE
+SET generation = GREATEST(user_token_generations.generation, EXCLUDED.generation);
+
+DELETE FROM user_token_generations WHERE user_email <> LOWER(TRIM(user_email));
+
+-- Every note owner and session holder gets a users row, then the owner ID column is
+-- backfilled and required. Ownership is decided by user_id; user_email stays for
+-- compatibility.
+INSERT INTO users (email, created_at)
+SELECT user_email, MIN(uploaded_at) FROM notes GROUP BY user_email
+ON CONFLICT (email) DO NOTHING;
+
+INSERT INTO users (email, created_at)
+SELECT user_email, MIN(created_at) FROM sessions GROUP BY user_email
+ON CONFLICT (email) DO NOTHING;
+
+UPDATE notes SET user_id = users.id
+FROM users
+WHERE notes.user_id IS NULL AND users.email = notes.user_email;
+
+ALTER TABLE notes ALTER COLUMN user_id SET NOT NULL;
+CREATE INDEX idx_notes_user_course_id ON notes(user_id, course_id);
+
+ALTER TABLE sessions ADD COLUMN user_id UUID REFERENCES users(id) ON DELETE CASCADE;
+
+UPDATE sessions SET user_id = users.id
+FROM users
+WHERE users.email = sessions.user_email;
+
+ALTER TABLE sessions ALTER COLUMN user_id SET NOT NULL;
@@ -0,0 +1,2 @@
+-- Normalized course IDs on notes are left as they are
+DROP TABLE IF EXISTS courses;
@@ -0,0 +1,29 @@
+-- The course catalog notes are filed under. Codes are canonical ("COMP 140"): a
+-- four-letter subject, one space and a three-digit number.
+CREATE TABLE courses (
+    code VARCHAR(8) PRIMARY KEY,
+    subject VARCHAR(4) NOT NULL,
+    number VARCHAR(3) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    term VARCHAR(50) NOT NULL DEFAULT '',
+    -- Canonical codes of the same course offered under other subjects
+    cross_listings TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT courses_code_canonical CHECK (
+        subject ~ '^[A-Z]{4}$' AND number ~ '^[0-9]{3}$' AND code = subject || ' ' || number
+    )
+);
+
+CREATE INDEX idx_courses_cross_listings ON courses USING GIN (cross_listings);
+
+CREATE TRIGGER update_courses_updated_at
+    BEFORE UPDATE ON courses
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Bring notes filed under "comp140" or "COMP-140" in line with the catalog's spelling
+UPDATE notes
+SET course_id = UPPER(regexp_replace(course_id, '^\s*([A-Za-z]{4})[\s_-]*([0-9]{3})\s*$', '\1 \2'))
+WHERE course_id ~ '^\s*[A-Za-z]{4}[\s_-]*[0-9]{3}\s*$'
+  AND course_id <> UPPER(regexp_replace(course_id, '^\s*([A-Za-z]{4})[\s_-]*([0-9]{3})\s*$', '\1 \2'));
@@ -0,0 +1,13 @@
+-- Free-form labels users put on their notes ("midterm", "lecture-12"). Tags are
+-- stored in canonical form: lower case, with hyphens for spaces.
+CREATE TABLE note_tags (
+    note_id UUID NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tag VARCHAR(50) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (note_id, user_id, tag),
+    CONSTRAINT note_tags_tag_canonical CHECK (tag ~ '^[a-z0-9][a-z0-9_-]*$')
+);
+
+-- Filtering a user's notes by tag and counting their tags
+CREATE INDEX idx_note_tags_user_tag ON note_tags(user_id, tag);
@@ -0,0 +1,5 @@
+CREATE INDEX IF NOT EXISTS idx_notes_user_course_id ON notes(user_id, course_id);
+DROP INDEX IF EXISTS idx_notes_user_course_id_id;
+DROP INDEX IF EXISTS idx_notes_user_file_size_id;
+DROP INDEX IF EXISTS idx_notes_user_title_id;
+DROP INDEX IF EXISTS idx_notes_user_uploaded_at_id;
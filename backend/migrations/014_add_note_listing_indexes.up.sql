@@ -0,0 +1,9 @@
+-- Keyset pagination over a user's notes walks (user_id, sort column, id) in either
+-- direction; one index per sort keeps every page an index range scan
+CREATE INDEX idx_notes_user_uploaded_at_id ON notes(user_id, uploaded_at, id);
+CREATE INDEX idx_notes_user_title_id ON notes(user_id, title, id);
+CREATE INDEX idx_notes_user_file_size_id ON notes(user_id, file_size, id);
+
+-- Supersedes idx_notes_user_course_id for course-sorted pages
+CREATE INDEX idx_notes_user_course_id_id ON notes(user_id, course_id, id);
+DROP INDEX IF EXISTS idx_notes_user_course_id;
@@ -0,0 +1,2 @@
+ALTER TABLE note_versions DROP COLUMN IF EXISTS sha256;
+ALTER TABLE notes DROP COLUMN IF EXISTS sha256;
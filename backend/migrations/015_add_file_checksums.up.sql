@@ -0,0 +1,4 @@
+-- SHA-256 of each stored file, computed while the upload streams in.
+-- Files uploaded before checksums were recorded keep NULLs.
+ALTER TABLE notes ADD COLUMN sha256 CHAR(64);
+ALTER TABLE note_versions ADD COLUMN sha256 CHAR(64);
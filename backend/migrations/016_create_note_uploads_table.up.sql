@@ -0,0 +1,20 @@
+-- Notes whose file the client is uploading straight to storage through a presigned
+-- URL. Completing the upload turns the row into a note with the same ID; rows left
+-- uncompleted past expires_at are purged together with any file they uploaded.
+CREATE TABLE note_uploads (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_email VARCHAR(255) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    course_id VARCHAR(50) NOT NULL,
+    visibility VARCHAR(10) NOT NULL CHECK (visibility IN ('private', 'course', 'rice')),
+    author_name VARCHAR(255) NOT NULL DEFAULT '',
+    file_name VARCHAR(255) NOT NULL,
+    file_path TEXT NOT NULL,
+    file_size BIGINT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_note_uploads_expires_at ON note_uploads(expires_at);
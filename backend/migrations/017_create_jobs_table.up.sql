@@ -0,0 +1,19 @@
+-- Background jobs, claimed by workers with FOR UPDATE SKIP LOCKED. A claimed job is
+-- leased until locked_until; if its worker dies the lease lapses and the job is
+-- picked up again. Finished jobs are deleted; jobs out of attempts stay as 'dead'.
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Workers look for due jobs, and for running jobs whose lease has lapsed
+CREATE INDEX idx_jobs_pending_run_at ON jobs(run_at) WHERE status = 'pending';
+CREATE INDEX idx_jobs_running_locked_until ON jobs(locked_until) WHERE status = 'running';
@@ -0,0 +1,2 @@
+ALTER TABLE notes DROP COLUMN IF EXISTS status_reason;
+ALTER TABLE notes DROP COLUMN IF EXISTS status;
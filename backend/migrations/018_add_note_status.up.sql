@@ -0,0 +1,5 @@
+-- Where each note's current file is in processing, and why processing failed or
+-- quarantined it. Notes from before background processing are ready.
+ALTER TABLE notes ADD COLUMN status VARCHAR(12) NOT NULL DEFAULT 'ready'
+    CHECK (status IN ('uploading', 'processing', 'ready', 'failed', 'quarantined'));
+ALTER TABLE notes ADD COLUMN status_reason TEXT NOT NULL DEFAULT '';
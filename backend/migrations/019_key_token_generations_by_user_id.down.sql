@@ -0,0 +1,9 @@
+ALTER TABLE user_token_generations ADD COLUMN user_email VARCHAR(255);
+
+UPDATE user_token_generations SET user_email = users.email
+FROM users
+WHERE users.id = user_token_generations.user_id;
+
+ALTER TABLE user_token_generations DROP CONSTRAINT user_token_generations_pkey;
+ALTER TABLE user_token_generations DROP COLUMN user_id;
+ALTER TABLE user_token_generations ADD PRIMARY KEY (user_email);
@@ -0,0 +1,14 @@
+-- Token generations follow the user's ID, not an email that can change when Google
+-- reports a new alias for the same account
+ALTER TABLE user_token_generations ADD COLUMN user_id UUID REFERENCES users(id) ON DELETE CASCADE;
+
+UPDATE user_token_generations SET user_id = users.id
+FROM users
+WHERE users.email = user_token_generations.user_email;
+
+-- Every token carries a user ID, so a generation without a user guards nothing
+DELETE FROM user_token_generations WHERE user_id IS NULL;
+
+ALTER TABLE user_token_generations DROP CONSTRAINT user_token_generations_pkey;
+ALTER TABLE user_token_generations DROP COLUMN user_email;
+ALTER TABLE user_token_generations ADD PRIMARY KEY (user_id);
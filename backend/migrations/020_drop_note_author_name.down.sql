@@ -0,0 +1,6 @@
+ALTER TABLE note_uploads ADD COLUMN author_name VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE notes ADD COLUMN author_name VARCHAR(255) NOT NULL DEFAULT '';
+
+UPDATE notes SET author_name = COALESCE(users.display_name, users.name)
+FROM users
+WHERE users.id = notes.user_id;
@@ -0,0 +1,4 @@
+-- Course listings name authors from their user row, so renaming yourself is seen on
+-- every note; the copy taken at upload time went stale
+ALTER TABLE notes DROP COLUMN author_name;
+ALTER TABLE note_uploads DROP COLUMN author_name;
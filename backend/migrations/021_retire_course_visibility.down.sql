@@ -0,0 +1,6 @@
+-- Notes moved from course to private stay private; which they were isn't recorded
+ALTER TABLE note_uploads DROP CONSTRAINT note_uploads_visibility_check;
+ALTER TABLE note_uploads ADD CONSTRAINT note_uploads_visibility_check CHECK (visibility IN ('private', 'course', 'rice'));
+
+ALTER TABLE notes DROP CONSTRAINT notes_visibility_check;
+ALTER TABLE notes ADD CONSTRAINT notes_visibility_check CHECK (visibility IN ('private', 'course', 'rice'));
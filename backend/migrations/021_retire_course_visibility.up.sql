@@ -0,0 +1,11 @@
+-- Course-only publishing is withdrawn until there is enrollment data to decide who
+-- takes a course. Notes published to a course go back to private rather than out to
+-- all of Rice, so nothing becomes visible to more people than its owner chose.
+UPDATE notes SET visibility = 'private' WHERE visibility = 'course';
+UPDATE note_uploads SET visibility = 'private' WHERE visibility = 'course';
+
+ALTER TABLE notes DROP CONSTRAINT notes_visibility_check;
+ALTER TABLE notes ADD CONSTRAINT notes_visibility_check CHECK (visibility IN ('private', 'rice'));
+
+ALTER TABLE note_uploads DROP CONSTRAINT note_uploads_visibility_check;
+ALTER TABLE note_uploads ADD CONSTRAINT note_uploads_visibility_check CHECK (visibility IN ('private', 'rice'));
@@ -0,0 +1,14 @@
+ALTER TABLE note_shares ADD COLUMN grantee_email VARCHAR(255);
+
+UPDATE note_shares SET grantee_email = users.email
+FROM users
+WHERE users.id = note_shares.grantee_user_id;
+
+ALTER TABLE note_shares ALTER COLUMN grantee_email SET NOT NULL;
+
+DROP INDEX idx_note_shares_grantee_user_id;
+ALTER TABLE note_shares DROP CONSTRAINT note_shares_pkey;
+ALTER TABLE note_shares DROP COLUMN grantee_user_id;
+ALTER TABLE note_shares ADD PRIMARY KEY (note_id, grantee_email);
+
+CREATE INDEX idx_note_shares_grantee_email ON note_shares(grantee_email);
@@ -0,0 +1,21 @@
+-- Shares follow the grantee's user ID, not an email that can change when Google
+-- reports a new alias for the same account. Grantees who have never signed in get a
+-- user row without a Google subject, which they claim when they first sign in.
+INSERT INTO users (email)
+SELECT DISTINCT grantee_email FROM note_shares
+ON CONFLICT (email) DO NOTHING;
+
+ALTER TABLE note_shares ADD COLUMN grantee_user_id UUID REFERENCES users(id) ON DELETE CASCADE;
+
+UPDATE note_shares SET grantee_user_id = users.id
+FROM users
+WHERE users.email = note_shares.grantee_email;
+
+ALTER TABLE note_shares ALTER COLUMN grantee_user_id SET NOT NULL;
+
+ALTER TABLE note_shares DROP CONSTRAINT note_shares_pkey;
+DROP INDEX idx_note_shares_grantee_email;
+ALTER TABLE note_shares DROP COLUMN grantee_email;
+ALTER TABLE note_shares ADD PRIMARY KEY (note_id, grantee_user_id);
+
+CREATE INDEX idx_note_shares_grantee_user_id ON note_shares(grantee_user_id);
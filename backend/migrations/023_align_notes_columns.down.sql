@@ -0,0 +1,4 @@
+-- Course codes are normalized to "DEPT 123" (012), which fits the original size
+ALTER TABLE notes
+    ALTER COLUMN course_id TYPE VARCHAR(20),
+    ALTER COLUMN content_type TYPE VARCHAR(50);
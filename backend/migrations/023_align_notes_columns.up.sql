@@ -0,0 +1,12 @@
+-- Hand-provisioned databases were built from copies of 001 that disagreed on these
+-- columns; widen them to the sizes the rest of the schema (note_uploads, note_versions)
+-- already uses, so every database ends up the same whichever copy it started from.
+ALTER TABLE notes
+    ALTER COLUMN course_id TYPE VARCHAR(50),
+    ALTER COLUMN content_type TYPE VARCHAR(100),
+    ALTER COLUMN file_path TYPE TEXT;
+
+-- Some of those copies also indexed (user_email, course_id). Owner lookups go by
+-- user_id since 011 and idx_notes_user_course_id_id serves them, so drop it where it
+-- exists rather than create it everywhere.
+DROP INDEX IF EXISTS idx_notes_user_course;
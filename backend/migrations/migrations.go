@@ -1 +1,483 @@
+// Package migrations embeds the SQL schema migrations and applies them to
+// PostgreSQL, tracking what has run in the schema_migrations table.
 package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// advisoryLockKey identifies the Postgres advisory lock held while migrating so
+// that several server instances starting together don't race each other
+const advisoryLockKey int64 = 0x72696365_6e6f7465 // "ricenote"
+
+// ErrUnversioned is returned by Up for a database that has a notes table but no
+// recorded migrations. It was provisioned by hand, and someone has to say how far
+// before it is adopted; see Baseline.
+var ErrUnversioned = errors.New("database has a notes table but no recorded migrations; " +
+	"adopt it with `server migrate baseline [version]` first")
+
+// fileNamePattern matches migration files such as 001_create_notes_table.up.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status describes whether a migration has been applied
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// Modified is set when the applied checksum no longer matches the embedded SQL
+	Modified bool
+	// Missing is set when the database records a version with no embedded SQL
+	Missing bool
+}
+
+// Load parses the migrations in fsys, ordered by version. Every version must
+// have both an up and a down file.
+func Load(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		contents, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(contents)
+		} else {
+			m.Down = string(contents)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", m.Version, m.Name)
+		}
+		sum := sha256.Sum256([]byte(m.Up))
+		m.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Runner applies migrations to a database
+type Runner struct {
+	db         *pgxpool.Pool
+	migrations []*Migration
+}
+
+// NewRunner creates a runner for the embedded migrations
+func NewRunner(db *pgxpool.Pool) (*Runner, error) {
+	if db == nil {
+		return nil, errors.New("database connection is required")
+	}
+
+	migrations, err := Load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Runner{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// appliedMigration is a row of the schema_migrations table
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Up applies all pending migrations in order and returns the ones it applied
+func (r *Runner) Up(ctx context.Context) ([]*Migration, error) {
+	var applied []*Migration
+
+	err := r.withLock(ctx, func(conn *pgxpool.Conn) error {
+		done, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if err := r.verify(done); err != nil {
+			return err
+		}
+
+		// Running 001 over a database that predates migrations would fail half way, and
+		// guessing how far it was hand-migrated could skip or repeat a change
+		if len(done) == 0 {
+			unversioned, err := hasNotesTable(ctx, conn)
+			if err != nil {
+				return err
+			}
+			if unversioned {
+				return ErrUnversioned
+			}
+		}
+
+		for _, m := range r.migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			if err := r.apply(ctx, conn, m); err != nil {
+				return err
+			}
+			applied = append(applied, m)
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// Baseline records every migration up to and including version as applied without
+// running it, for a database whose schema was created by hand. Later migrations, 023
+// among them, then bring its columns in line. It refuses to run on a database that
+// already has migrations recorded.
+func (r *Runner) Baseline(ctx context.Context, version int64) ([]*Migration, error) {
+	var baselined []*Migration
+
+	err := r.withLock(ctx, func(conn *pgxpool.Conn) error {
+		done, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(done) > 0 {
+			return errors.New("migrations have already been recorded; baseline only adopts an unmigrated database")
+		}
+
+		baselined, err = r.baseline(ctx, conn, version)
+		return err
+	})
+
+	return baselined, err
+}
+
+// baseline records the migrations up to version as applied, in a single transaction
+func (r *Runner) baseline(ctx context.Context, conn *pgxpool.Conn, version int64) ([]*Migration, error) {
+	baselined, err := r.through(version)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		for _, m := range baselined {
+			_, err := tx.Exec(ctx,
+				`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+				m.Version, m.Name, m.Checksum)
+			if err != nil {
+				return fmt.Errorf("failed to record migration %d_%s: %w", m.Version, m.Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Baselined migrations", "version", version, "count", len(baselined))
+	return baselined, nil
+}
+
+// through returns the migrations up to and including version, which must exist
+func (r *Runner) through(version int64) ([]*Migration, error) {
+	for i, m := range r.migrations {
+		if m.Version == version {
+			return r.migrations[:i+1], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown migration version %d", version)
+}
+
+// hasNotesTable reports whether the notes table exists
+func hasNotesTable(ctx context.Context, conn *pgxpool.Conn) (bool, error) {
+	var exists bool
+	if err := conn.QueryRow(ctx, `SELECT to_regclass('notes') IS NOT NULL`).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check for notes table: %w", err)
+	}
+	return exists, nil
+}
+
+// Down rolls back the most recently applied steps migrations and returns them
+func (r *Runner) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("steps must be positive, got %d", steps)
+	}
+
+	var reverted []*Migration
+
+	err := r.withLock(ctx, func(conn *pgxpool.Conn) error {
+		done, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(r.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := r.migrations[i]
+			if _, ok := done[m.Version]; !ok {
+				continue
+			}
+			if err := r.revert(ctx, conn, m); err != nil {
+				return err
+			}
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Redo rolls back the latest applied migration and applies it again
+func (r *Runner) Redo(ctx context.Context) (*Migration, error) {
+	var redone *Migration
+
+	err := r.withLock(ctx, func(conn *pgxpool.Conn) error {
+		done, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(r.migrations) - 1; i >= 0; i-- {
+			if _, ok := done[r.migrations[i].Version]; ok {
+				redone = r.migrations[i]
+				break
+			}
+		}
+		if redone == nil {
+			return errors.New("no applied migrations to redo")
+		}
+
+		if err := r.revert(ctx, conn, redone); err != nil {
+			return err
+		}
+		return r.apply(ctx, conn, redone)
+	})
+
+	return redone, err
+}
+
+// Status reports every known migration and whether it has been applied
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	conn, err := r.db.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if err := r.ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	done, err := r.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int64]bool, len(r.migrations))
+	statuses := make([]Status, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		known[m.Version] = true
+		status := Status{Version: m.Version, Name: m.Name}
+		if row, ok := done[m.Version]; ok {
+			appliedAt := row.appliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.Modified = row.checksum != m.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+
+	for version, row := range done {
+		if known[version] {
+			continue
+		}
+		appliedAt := row.appliedAt
+		statuses = append(statuses, Status{
+			Version:   version,
+			Name:      row.name,
+			Applied:   true,
+			AppliedAt: &appliedAt,
+			Missing:   true,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory lock
+func (r *Runner) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := r.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	slog.Debug("Waiting for migration lock")
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", advisoryLockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Use a fresh context so the lock is released even if ctx was cancelled
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", advisoryLockKey); err != nil {
+			slog.Error("Failed to release migration lock", "error", err)
+		}
+	}()
+
+	if err := r.ensureTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// ensureTable creates the schema_migrations table if it doesn't exist
+func (r *Runner) ensureTable(ctx context.Context, conn *pgxpool.Conn) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`
+
+	if _, err := conn.Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// applied returns the rows of schema_migrations keyed by version
+func (r *Runner) applied(ctx context.Context, conn *pgxpool.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.Query(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var version int64
+		var row appliedMigration
+		if err := rows.Scan(&version, &row.name, &row.checksum, &row.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		done[version] = row
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating applied migrations: %w", err)
+	}
+
+	return done, nil
+}
+
+// verify refuses to migrate when an applied migration was edited after the fact
+func (r *Runner) verify(done map[int64]appliedMigration) error {
+	for _, m := range r.migrations {
+		row, ok := done[m.Version]
+		if ok && row.checksum != m.Checksum {
+			return fmt.Errorf("migration %d_%s was modified after it was applied (checksum %s, expected %s)",
+				m.Version, m.Name, m.Checksum, row.checksum)
+		}
+	}
+	return nil
+}
+
+// apply runs a migration's up SQL and records it in a single transaction
+func (r *Runner) apply(ctx context.Context, conn *pgxpool.Conn, m *Migration) error {
+	slog.Info("Applying migration", "version", m.Version, "name", m.Name)
+
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, m.Up); err != nil {
+			return fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+
+		_, err := tx.Exec(ctx,
+			`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+			m.Version, m.Name, m.Checksum)
+		if err != nil {
+			return fmt.Errorf("failed to record migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		return nil
+	})
+}
+
+// revert runs a migration's down SQL and removes its record in a single transaction
+func (r *Runner) revert(ctx context.Context, conn *pgxpool.Conn, m *Migration) error {
+	slog.Info("Reverting migration", "version", m.Version, "name", m.Name)
+
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, m.Down); err != nil {
+			return fmt.Errorf("rollback of %d_%s failed: %w", m.Version, m.Name, err)
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+			return fmt.Errorf("failed to unrecord migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,139 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad_Embedded(t *testing.T) {
+	migrations, err := Load(files)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("Load() returned no migrations")
+	}
+
+	for i, m := range migrations {
+		if i > 0 && m.Version <= migrations[i-1].Version {
+			t.Errorf("migrations not strictly ordered: %d after %d", m.Version, migrations[i-1].Version)
+		}
+		if m.Checksum == "" {
+			t.Errorf("migration %d has no checksum", m.Version)
+		}
+	}
+}
+
+// TestLoad_ReleasedUnchanged guards migrations that databases were provisioned from
+// before the runner existed; changing one breaks every database that applied it, so
+// schema changes belong in a new migration
+func TestLoad_ReleasedUnchanged(t *testing.T) {
+	released := map[int64]string{
+		1: "001116b7f0e5ce7de5d68a19920a96810969452f52ebbb2a122d95ca6c4b9869",
+	}
+
+	migrations, err := Load(files)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	for _, m := range migrations {
+		if want, ok := released[m.Version]; ok && m.Checksum != want {
+			t.Errorf("migration %03d_%s checksum = %s, want %s", m.Version, m.Name, m.Checksum, want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     fstest.MapFS
+		wantErr   string
+		wantNames []string
+	}{
+		{
+			name: "orders by version",
+			files: fstest.MapFS{
+				"010_second.up.sql":   {Data: []byte("SELECT 2;")},
+				"010_second.down.sql": {Data: []byte("SELECT -2;")},
+				"002_first.up.sql":    {Data: []byte("SELECT 1;")},
+				"002_first.down.sql":  {Data: []byte("SELECT -1;")},
+			},
+			wantNames: []string{"first", "second"},
+		},
+		{
+			name: "missing down file",
+			files: fstest.MapFS{
+				"001_only_up.up.sql": {Data: []byte("SELECT 1;")},
+			},
+			wantErr: "must have both up and down files",
+		},
+		{
+			name: "unexpected file name",
+			files: fstest.MapFS{
+				"001_create_notes_table.sql": {Data: []byte("SELECT 1;")},
+			},
+			wantErr: "invalid migration file name",
+		},
+		{
+			name: "conflicting names for one version",
+			files: fstest.MapFS{
+				"001_a.up.sql":   {Data: []byte("SELECT 1;")},
+				"001_b.down.sql": {Data: []byte("SELECT -1;")},
+			},
+			wantErr: "conflicting names",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := Load(tt.files)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if len(migrations) != len(tt.wantNames) {
+				t.Fatalf("Load() returned %d migrations, want %d", len(migrations), len(tt.wantNames))
+			}
+			for i, name := range tt.wantNames {
+				if migrations[i].Name != name {
+					t.Errorf("migration %d name = %q, want %q", i, migrations[i].Name, name)
+				}
+			}
+		})
+	}
+}
+
+func TestRunner_Through(t *testing.T) {
+	migrations, err := Load(files)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	r := &Runner{migrations: migrations}
+
+	got, err := r.through(1)
+	if err != nil {
+		t.Fatalf("through(1) error = %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "create_notes_table" {
+		t.Errorf("through(1) = %v, want only 001_create_notes_table", got)
+	}
+
+	last := migrations[len(migrations)-1].Version
+	if got, err := r.through(last); err != nil || len(got) != len(migrations) {
+		t.Errorf("through(%d) returned %d migrations, error %v; want all %d", last, len(got), err, len(migrations))
+	}
+
+	if _, err := r.through(last + 1); err == nil {
+		t.Errorf("through(%d) of an unknown version should fail", last+1)
+	}
+}
@@ -64,8 +64,8 @@ export default function DashboardPage() {
     try {
       setNotesLoading(true)
       setNotesError(null)
-      const notes = await fetchNotes()
-      setUserNotes(notes)
+      const page = await fetchNotes()
+      setUserNotes(page.items)
     } catch (error) {
       setNotesError(error instanceof Error ? error.message : 'Failed to load notes')
       console.error('Failed to load notes:', error)
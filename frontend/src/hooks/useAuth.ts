@@ -29,6 +29,42 @@ const removeToken = () => {
   localStorage.removeItem(TOKEN_KEY)
 }
 
+const API_URL = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080'
+
+// PKCE verifier binding the one-time login code to this browser tab
+const VERIFIER_KEY = 'rice_notes_pkce_verifier'
+
+const base64Url = (bytes: Uint8Array): string =>
+  btoa(String.fromCharCode(...bytes)).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '')
+
+const createChallenge = async (): Promise<string> => {
+  const verifier = base64Url(crypto.getRandomValues(new Uint8Array(32)))
+  sessionStorage.setItem(VERIFIER_KEY, verifier)
+  const digest = await crypto.subtle.digest('SHA-256', new TextEncoder().encode(verifier))
+  return base64Url(new Uint8Array(digest))
+}
+
+// Trade the one-time code from the login redirect for an access token
+const exchangeCode = async (code: string): Promise<string> => {
+  const verifier = sessionStorage.getItem(VERIFIER_KEY)
+  sessionStorage.removeItem(VERIFIER_KEY)
+  if (!verifier) {
+    throw new Error('Login was started in another tab, please sign in again')
+  }
+
+  const response = await fetch(`${API_URL}/api/auth/token`, {
+    method: 'POST',
+    credentials: 'include',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ code, code_verifier: verifier })
+  })
+  if (!response.ok) {
+    throw new Error('Login expired, please sign in again')
+  }
+  const data: { access_token: string } = await response.json()
+  return data.access_token
+}
+
 export function useAuth() {
   const [authState, setAuthState] = useState<AuthState>({
     user: null,
@@ -43,14 +79,14 @@ export function useAuth() {
     try {
       setAuthState(prev => ({ ...prev, isLoading: true, error: null }))
       
-      // Check if we have a token from URL (OAuth callback)
+      // Check if we have a one-time login code from the URL (OAuth callback)
       const urlParams = new URLSearchParams(window.location.search)
-      const tokenFromUrl = urlParams.get('token')
-      
-      if (tokenFromUrl) {
-        // Store token and clean URL
-        setToken(tokenFromUrl)
+      const codeFromUrl = urlParams.get('code')
+
+      if (codeFromUrl) {
+        // Clean the URL first so the code never lingers in history
         window.history.replaceState({}, document.title, window.location.pathname)
+        setToken(await exchangeCode(codeFromUrl))
       }
 
       // Check if we have a stored token
@@ -85,15 +121,24 @@ export function useAuth() {
     }
   }, [apiGet])
 
-  const login = useCallback(() => {
-    // Redirect to Google OAuth login endpoint
-    window.location.href = `${process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080'}/api/auth/google`
+  const login = useCallback(async () => {
+    // Redirect to Google OAuth login endpoint, asking for a one-time code bound to this tab
+    const challenge = await createChallenge()
+    window.location.href = `${API_URL}/api/auth/google?code_challenge=${challenge}&code_challenge_method=S256`
   }, [])
 
   const logout = useCallback(async () => {
     try {
       setAuthState(prev => ({ ...prev, isLoading: true }))
-      
+
+      // Revoke the token and session server-side; a failure here must not keep the user signed in
+      const token = getToken()
+      await fetch(`${API_URL}/api/auth/logout`, {
+        method: 'POST',
+        credentials: 'include',
+        headers: token ? { Authorization: `Bearer ${token}` } : {}
+      }).catch(() => undefined)
+
       // Clear the stored token
       removeToken()
       
@@ -11,11 +11,15 @@ export interface Note {
   course_id: string
   file_name: string
   uploaded_at: string
+  // New uploads are 'processing' until the server has checked the file
+  status: 'uploading' | 'processing' | 'ready' | 'failed' | 'quarantined'
+  status_reason?: string
 }
 
 export interface ApiError {
   error: string
   message: string
+  field?: string
 }
 
 export interface UploadProgress {
@@ -34,10 +38,11 @@ export async function uploadNote(
   onProgress?: (progress: UploadProgress) => void
 ): Promise<Note> {
   return new Promise((resolve, reject) => {
+    // The server streams the file as it arrives, so the fields must come first
     const formData = new FormData()
-    formData.append('file', file)
     formData.append('title', title)
     formData.append('course_id', courseId)
+    formData.append('file', file)
 
     const xhr = new XMLHttpRequest()
 
@@ -90,13 +95,83 @@ export async function uploadNote(
   })
 }
 
+export interface CatalogCourse {
+  code: string
+  subject: string
+  number: string
+  title: string
+  term?: string
+  cross_listings: string[]
+}
+
+/**
+ * Search the course catalog by code or title, for autocomplete
+ */
+export async function searchCourses(query: string, limit = 10): Promise<CatalogCourse[]> {
+  const url = new URL(`${API_BASE_URL}/api/courses`)
+  url.searchParams.append('q', query)
+  url.searchParams.append('limit', String(limit))
+
+  const headers: Record<string, string> = {}
+
+  // Add Authorization header if we have a token
+  const token = getToken()
+  if (token) {
+    headers.Authorization = `Bearer ${token}`
+  }
+
+  const response = await fetch(url.toString(), {
+    method: 'GET',
+    credentials: 'include', // fallback for cookies
+    headers
+  })
+
+  if (!response.ok) {
+    const errorData: ApiError = await response.json().catch(() => ({ 
+      error: 'unknown_error', 
+      message: `HTTP ${response.status}` 
+    }))
+    throw new Error(errorData.message || errorData.error)
+  }
+
+  return response.json()
+}
+
+export type NoteSort = 'uploaded_at' | 'title' | 'file_size' | 'course_id'
+
+export interface NotesPage {
+  items: Note[]
+  next_cursor?: string
+  total: number
+}
+
+export interface FetchNotesOptions {
+  courseId?: string
+  sort?: NoteSort
+  order?: 'asc' | 'desc'
+  cursor?: string
+  limit?: number
+}
+
 /**
- * Fetch user's notes
+ * Fetch a page of the user's notes; pass next_cursor back as cursor for the next page
  */
-export async function fetchNotes(courseId?: string): Promise<Note[]> {
+export async function fetchNotes(options: FetchNotesOptions = {}): Promise<NotesPage> {
   const url = new URL(`${API_BASE_URL}/api/notes`)
-  if (courseId) {
-    url.searchParams.append('course_id', courseId)
+  if (options.courseId) {
+    url.searchParams.append('course_id', options.courseId)
+  }
+  if (options.sort) {
+    url.searchParams.append('sort', options.sort)
+  }
+  if (options.order) {
+    url.searchParams.append('order', options.order)
+  }
+  if (options.cursor) {
+    url.searchParams.append('cursor', options.cursor)
+  }
+  if (options.limit) {
+    url.searchParams.append('limit', String(options.limit))
   }
 
   const headers: Record<string, string> = {
@@ -167,20 +242,13 @@ export async function getNoteDownloadUrl(noteId: string): Promise<string> {
     headers.Authorization = `Bearer ${token}`
   }
 
-  const response = await fetch(`${API_BASE_URL}/api/notes/${noteId}`, {
+  // Ask for JSON: cross-origin redirects are opaque to fetch
+  const response = await fetch(`${API_BASE_URL}/api/notes/${noteId}/download?mode=json`, {
     method: 'GET',
     credentials: 'include', // fallback for cookies
-    redirect: 'manual', // Don't follow redirects automatically
     headers
   })
 
-  if (response.status === 302) {
-    const location = response.headers.get('Location')
-    if (location) {
-      return location
-    }
-  }
-
   if (!response.ok) {
     const errorData: ApiError = await response.json().catch(() => ({ 
       error: 'unknown_error', 
@@ -189,7 +257,11 @@ export async function getNoteDownloadUrl(noteId: string): Promise<string> {
     throw new Error(errorData.message || errorData.error)
   }
 
-  throw new Error('No redirect URL found')
+  const data: { url?: string } = await response.json()
+  if (!data.url) {
+    throw new Error('No download URL found')
+  }
+  return data.url
 }
 
 /**